@@ -1,3 +1,11 @@
 module tuxmonteiro/terraform-provider-eksctl/v2
 
 go 1.18
+
+require (
+	github.com/armon/circbuf v0.0.0-20190214190532-5111143e8da2
+	github.com/mitchellh/go-linereader v0.0.0-20190213213312-1b945b3263eb
+	github.com/rs/xid v1.6.0
+	golang.org/x/xerrors v0.0.0-20240903120638-7835f813f4da
+	gopkg.in/yaml.v3 v3.0.1
+)
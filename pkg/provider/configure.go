@@ -1,6 +1,8 @@
 package provider
 
 import (
+	"context"
+
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/tfsdk"
@@ -8,14 +10,22 @@ import (
 
 type ProviderInstance struct {
 	AWSSession *session.Session
+
+	provider *schema.Provider
+}
+
+// StopContext returns the context that is canceled when Terraform is interrupted, so that resources can cancel eksctl runs
+func (p *ProviderInstance) StopContext() context.Context {
+	return p.provider.StopContext()
 }
 
-func providerConfigure() func(*schema.ResourceData) (interface{}, error) {
+func providerConfigure(p *schema.Provider) func(*schema.ResourceData) (interface{}, error) {
 	return func(d *schema.ResourceData) (interface{}, error) {
 		s := tfsdk.AWSSessionFromResourceData(&tfsdk.Resource{d})
 
 		return &ProviderInstance{
 			AWSSession: s,
+			provider:   p,
 		}, nil
 	}
 }
@@ -14,17 +14,25 @@ import (
 func Provider() terraform.ResourceProvider {
 
 	// The actual provider
-	return &schema.Provider{
+	p := &schema.Provider{
 		Schema: map[string]*schema.Schema{
 			tfsdk.KeyAssumeRole: tfsdk.SchemaAssumeRole(),
 		},
 		ResourcesMap: map[string]*schema.Resource{
 			"eksctl_cluster":                cluster.ResourceCluster(),
+			"eksctl_cluster_deployment":     cluster.ResourceClusterDeployment(),
 			"eksctl_nodegroup":              nodegroup.Resource(),
 			"eksctl_iamserviceaccount":      iamserviceaccount.Resource(),
+			"eksctl_access_entry":           cluster.ResourceAccessEntry(),
 			"eksctl_courier_alb":            courier.ResourceALB(),
 			"eksctl_courier_route53_record": courier.ResourceRoute53Record(),
 		},
-		ConfigureFunc: providerConfigure(),
+		DataSourcesMap: map[string]*schema.Resource{
+			"eksctl_cluster": cluster.DataSourceCluster(),
+		},
 	}
+
+	p.ConfigureFunc = providerConfigure(p)
+
+	return p
 }
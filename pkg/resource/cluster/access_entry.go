@@ -0,0 +1,510 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/api"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/tfsdk"
+	"gopkg.in/yaml.v3"
+)
+
+// KeyAccessEntry is the EKS access entries of the cluster, which grant IAM principals access to the cluster
+// without the aws-auth configmap
+const KeyAccessEntry = "access_entry"
+
+const (
+	KeyPrincipalARN       = "principal_arn"
+	KeyKubernetesUsername = "kubernetes_username"
+	KeyKubernetesGroups   = "kubernetes_groups"
+	KeyAccessPolicy       = "access_policy"
+	KeyPolicyARN          = "policy_arn"
+	KeyNamespaces         = "namespaces"
+)
+
+// Authentication modes of EKS clusters, which can only be changed in this order
+const (
+	AuthenticationModeConfigMap       = "CONFIG_MAP"
+	AuthenticationModeAPIAndConfigMap = "API_AND_CONFIG_MAP"
+	AuthenticationModeAPI             = "API"
+)
+
+var authenticationModes = []string{AuthenticationModeConfigMap, AuthenticationModeAPIAndConfigMap, AuthenticationModeAPI}
+
+// accessEntry is an item of accessConfig.accessEntries in cluster.yaml, and of the output of `eksctl get accessentry`
+type accessEntry struct {
+	PrincipalARN       string         `json:"principalARN" yaml:"principalARN"`
+	KubernetesUsername string         `json:"kubernetesUsername,omitempty" yaml:"kubernetesUsername,omitempty"`
+	KubernetesGroups   []string       `json:"kubernetesGroups,omitempty" yaml:"kubernetesGroups,omitempty"`
+	AccessPolicies     []accessPolicy `json:"accessPolicies,omitempty" yaml:"accessPolicies,omitempty"`
+}
+
+type accessPolicy struct {
+	PolicyARN   string      `json:"policyARN" yaml:"policyARN"`
+	AccessScope accessScope `json:"accessScope" yaml:"accessScope"`
+}
+
+type accessScope struct {
+	Type       string   `json:"type" yaml:"type"`
+	Namespaces []string `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+}
+
+// accessEntrySchema is the attributes of both an access_entry block and the eksctl_access_entry resource
+func accessEntrySchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		KeyPrincipalARN: {
+			Type:     schema.TypeString,
+			Required: true,
+		},
+		KeyKubernetesUsername: {
+			Type:     schema.TypeString,
+			Optional: true,
+		},
+		KeyKubernetesGroups: {
+			Type:     schema.TypeSet,
+			Optional: true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		// access_policy associates an EKS access policy like AmazonEKSClusterAdminPolicy with the principal,
+		// either cluster-wide or in the namespaces
+		KeyAccessPolicy: {
+			Type:     schema.TypeSet,
+			Optional: true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					KeyPolicyARN: {
+						Type:     schema.TypeString,
+						Required: true,
+					},
+					KeyNamespaces: {
+						Type:     schema.TypeSet,
+						Optional: true,
+						Elem: &schema.Schema{
+							Type: schema.TypeString,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func accessEntryResource() *schema.Resource {
+	return &schema.Resource{
+		Schema: accessEntrySchema(),
+	}
+}
+
+// accessEntryFromValue reads an access_entry block, or the attributes of an eksctl_access_entry resource
+func accessEntryFromValue(m map[string]interface{}) accessEntry {
+	e := accessEntry{
+		PrincipalARN:     fmt.Sprintf("%v", m[KeyPrincipalARN]),
+		KubernetesGroups: sortedStrings(m[KeyKubernetesGroups]),
+	}
+
+	e.KubernetesUsername, _ = m[KeyKubernetesUsername].(string)
+
+	for _, v := range listOf(m[KeyAccessPolicy]) {
+		p, _ := v.(map[string]interface{})
+
+		policy := accessPolicy{
+			PolicyARN:   fmt.Sprintf("%v", p[KeyPolicyARN]),
+			AccessScope: accessScope{Type: "cluster"},
+		}
+
+		if ns := sortedStrings(p[KeyNamespaces]); len(ns) > 0 {
+			policy.AccessScope = accessScope{Type: "namespace", Namespaces: ns}
+		}
+
+		e.AccessPolicies = append(e.AccessPolicies, policy)
+	}
+
+	sort.SliceStable(e.AccessPolicies, func(i, j int) bool {
+		return e.AccessPolicies[i].PolicyARN < e.AccessPolicies[j].PolicyARN
+	})
+
+	return e
+}
+
+// value returns the access entry in the form of an access_entry block
+func (e accessEntry) value() map[string]interface{} {
+	policies := []interface{}{}
+
+	for _, p := range e.AccessPolicies {
+		policies = append(policies, map[string]interface{}{
+			KeyPolicyARN:  p.PolicyARN,
+			KeyNamespaces: interfaces(p.AccessScope.Namespaces),
+		})
+	}
+
+	return map[string]interface{}{
+		KeyPrincipalARN:       e.PrincipalARN,
+		KeyKubernetesUsername: e.KubernetesUsername,
+		KeyKubernetesGroups:   interfaces(e.KubernetesGroups),
+		KeyAccessPolicy:       policies,
+	}
+}
+
+// accessEntriesFromSet reads the access_entry blocks
+func accessEntriesFromSet(v interface{}) []accessEntry {
+	var entries []accessEntry
+
+	for _, item := range listOf(v) {
+		if m, ok := item.(map[string]interface{}); ok {
+			entries = append(entries, accessEntryFromValue(m))
+		}
+	}
+
+	return entries
+}
+
+// validateAccessEntry fails on the access entries EKS rejects
+func validateAccessEntry(e accessEntry) error {
+	for _, g := range e.KubernetesGroups {
+		// EKS reserves the system: groups. The cluster admins mapped to system:masters in aws-auth need an access policy instead.
+		if strings.HasPrefix(g, "system:") {
+			return fmt.Errorf("%s: kubernetes group %q of %s is reserved by EKS. Associate an access policy like arn:aws:eks::aws:cluster-access-policy/AmazonEKSClusterAdminPolicy instead",
+				KeyAccessEntry, g, e.PrincipalARN)
+		}
+	}
+
+	for _, p := range e.AccessPolicies {
+		if !strings.HasPrefix(p.PolicyARN, "arn:aws") || !strings.Contains(p.PolicyARN, ":cluster-access-policy/") {
+			return fmt.Errorf("%s: %s of %s isn't an EKS access policy ARN like arn:aws:eks::aws:cluster-access-policy/AmazonEKSViewPolicy",
+				KeyAccessEntry, p.PolicyARN, e.PrincipalARN)
+		}
+	}
+
+	return nil
+}
+
+// validateAccessEntries fails on the access_entry blocks EKS rejects, or can't be created due to the authentication mode in the spec
+func validateAccessEntries(d api.Getter) error {
+	entries := accessEntriesFromSet(d.Get(KeyAccessEntry))
+	if len(entries) == 0 {
+		return nil
+	}
+
+	seen := map[string]bool{}
+
+	for _, e := range entries {
+		if seen[e.PrincipalARN] {
+			return fmt.Errorf("%s: principal %s can't have more than one access entry", KeyAccessEntry, e.PrincipalARN)
+		}
+
+		seen[e.PrincipalARN] = true
+
+		if err := validateAccessEntry(e); err != nil {
+			return err
+		}
+	}
+
+	spec, _ := d.Get(KeySpec).(string)
+
+	c := EksctlClusterConfig{Rest: map[string]interface{}{}}
+
+	if err := yaml.Unmarshal([]byte(spec), &c); err != nil {
+		// The spec is validated on its own
+		return nil
+	}
+
+	if authenticationMode(&c) == AuthenticationModeConfigMap {
+		return fmt.Errorf("%s requires accessConfig.authenticationMode to be %s or %s, but it's %s",
+			KeyAccessEntry, AuthenticationModeAPIAndConfigMap, AuthenticationModeAPI, AuthenticationModeConfigMap)
+	}
+
+	return nil
+}
+
+// authenticationMode returns accessConfig.authenticationMode of the cluster.yaml, if any
+func authenticationMode(c *EksctlClusterConfig) string {
+	ac, _ := c.Rest["accessConfig"].(map[string]interface{})
+
+	mode, _ := ac["authenticationMode"].(string)
+
+	return mode
+}
+
+// authenticationModeOperations returns the operation to change the authentication mode, if changed.
+// EKS can switch from CONFIG_MAP to API_AND_CONFIG_MAP and then to API, one step at a time and never back.
+func authenticationModeOperations(old, new *EksctlClusterConfig) ([]string, error) {
+	oldMode, newMode := authenticationMode(old), authenticationMode(new)
+
+	if newMode == "" || oldMode == newMode {
+		return nil, nil
+	}
+
+	// Clusters without the authentication mode in cluster.yaml are assumed to be in CONFIG_MAP, which is the mode of clusters predating access entries
+	from := AuthenticationModeConfigMap
+	if oldMode != "" {
+		from = oldMode
+	}
+
+	if indexOf(authenticationModes, newMode) < indexOf(authenticationModes, from) {
+		return nil, fmt.Errorf("accessConfig.authenticationMode can't be changed from %s to %s, as EKS supports only %s. Revert the change, or recreate the cluster",
+			from, newMode, strings.Join(authenticationModes, " to "))
+	}
+
+	if indexOf(authenticationModes, newMode) > indexOf(authenticationModes, from)+1 {
+		return nil, fmt.Errorf("accessConfig.authenticationMode can't be changed from %s to %s at once, as EKS supports only %s. "+
+			"Change it to %s and run `terraform apply` first",
+			from, newMode, strings.Join(authenticationModes, " to "), AuthenticationModeAPIAndConfigMap)
+	}
+
+	return []string{OpUpdateAuthenticationMode}, nil
+}
+
+func indexOf(ss []string, s string) int {
+	for i, v := range ss {
+		if v == s {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// observedAccessEntries returns the live access entries of the configured principals to be stored as access_entry,
+// so that Terraform shows the drift. The entries created by EKS for the nodes and the cluster creator are ignored.
+func observedAccessEntries(live []accessEntry, configured []accessEntry) []interface{} {
+	byARN := map[string]accessEntry{}
+
+	for _, e := range live {
+		byARN[e.PrincipalARN] = e
+	}
+
+	var r []interface{}
+
+	for _, c := range configured {
+		e, ok := byARN[c.PrincipalARN]
+		if !ok {
+			continue
+		}
+
+		// EKS defaults the username to the one derived from the principal, which shouldn't be shown as a diff
+		if c.KubernetesUsername == "" || e.KubernetesUsername == "" {
+			e.KubernetesUsername = c.KubernetesUsername
+		}
+
+		sort.Strings(e.KubernetesGroups)
+
+		sort.SliceStable(e.AccessPolicies, func(i, j int) bool {
+			return e.AccessPolicies[i].PolicyARN < e.AccessPolicies[j].PolicyARN
+		})
+
+		r = append(r, e.value())
+	}
+
+	return r
+}
+
+// accessEntryChanges returns the principals of the access entries to delete, and the access entries to create.
+// Changed entries are deleted before being created again, as eksctl can't update access entries.
+func accessEntryChanges(old, new []accessEntry) ([]string, []accessEntry) {
+	key := func(e accessEntry) string {
+		bs, _ := json.Marshal(e)
+
+		return string(bs)
+	}
+
+	oldKeys, newKeys := map[string]bool{}, map[string]bool{}
+
+	for _, e := range old {
+		oldKeys[key(e)] = true
+	}
+
+	for _, e := range new {
+		newKeys[key(e)] = true
+	}
+
+	var deleted []string
+
+	for _, e := range old {
+		if !newKeys[key(e)] {
+			deleted = append(deleted, e.PrincipalARN)
+		}
+	}
+
+	var created []accessEntry
+
+	for _, e := range new {
+		if !oldKeys[key(e)] {
+			created = append(created, e)
+		}
+	}
+
+	sort.Strings(deleted)
+
+	return deleted, created
+}
+
+// accessEntryConfig returns the cluster.yaml for `eksctl create accessentry`
+func accessEntryConfig(clusterName, region string, entries []accessEntry) ([]byte, error) {
+	config := map[string]interface{}{
+		"apiVersion": DefaultAPIVersion,
+		"kind":       "ClusterConfig",
+		"metadata": map[string]interface{}{
+			"name":   clusterName,
+			"region": region,
+		},
+		"accessConfig": map[string]interface{}{
+			"accessEntries": entries,
+		},
+	}
+
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(config); err != nil {
+		return nil, fmt.Errorf("encoding access entries: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func runCreateAccessEntries(ctx *sdk.Context, d api.Getter, clusterName string, entries []accessEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	region, _ := tfsdk.GetAWSRegionAndProfile(d)
+
+	config, err := accessEntryConfig(clusterName, region, entries)
+	if err != nil {
+		return err
+	}
+
+	cmd, err := newEksctlCommandFromResourceWithProfile(d, "create", "accessentry", "-f", "-")
+	if err != nil {
+		return fmt.Errorf("creating create accessentry command: %w", err)
+	}
+
+	cmd.Stdin = bytes.NewReader(config)
+
+	if _, err := ctx.Run(cmd); err != nil {
+		return fmt.Errorf("running create accessentry command: %w\n\nCLUSTER CONFIG:\n%s", err, string(config))
+	}
+
+	return nil
+}
+
+func runDeleteAccessEntries(ctx *sdk.Context, d api.Getter, clusterName string, principalARNs []string) error {
+	for _, arn := range principalARNs {
+		cmd, err := newEksctlCommandFromResourceWithRegionAndProfile(d, "delete", "accessentry", "--cluster", clusterName, "--principal-arn", arn)
+		if err != nil {
+			return fmt.Errorf("creating delete accessentry command: %w", err)
+		}
+
+		if _, err := ctx.Run(cmd); err != nil {
+			return fmt.Errorf("deleting access entry of %s: %w", arn, err)
+		}
+	}
+
+	return nil
+}
+
+func runGetAccessEntries(ctx *sdk.Context, d api.Getter, clusterName string) ([]accessEntry, error) {
+	cmd, err := newEksctlCommandFromResourceWithRegionAndProfile(d, "get", "accessentry", "--cluster", clusterName, "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("creating get accessentry command: %w", err)
+	}
+
+	res, err := ctx.Run(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("running get accessentry: %w", err)
+	}
+
+	return parseAccessEntries(res.Output)
+}
+
+func parseAccessEntries(output string) ([]accessEntry, error) {
+	// eksctl prints nothing but logs when there are no access entries
+	if !strings.HasPrefix(strings.TrimSpace(output), "[") {
+		log.Printf("[DEBUG] no access entries found in eksctl output: %s", output)
+
+		return nil, nil
+	}
+
+	var entries []accessEntry
+
+	if err := json.Unmarshal([]byte(output), &entries); err != nil {
+		return nil, fmt.Errorf("parsing accessentry: %w", err)
+	}
+
+	return entries, nil
+}
+
+// readAccessEntries stores the live access entries of the principals in access_entry blocks as access_entry
+func readAccessEntries(ctx *sdk.Context, d api.ReadWrite, clusterName string) error {
+	configured := accessEntriesFromSet(d.Get(KeyAccessEntry))
+
+	// Clusters in the CONFIG_MAP authentication mode fail `eksctl get accessentry`
+	if len(configured) == 0 {
+		return nil
+	}
+
+	live, err := runGetAccessEntries(ctx, d, clusterName)
+	if err != nil {
+		return err
+	}
+
+	if err := d.Set(KeyAccessEntry, observedAccessEntries(live, configured)); err != nil {
+		return fmt.Errorf("setting %s: %w", KeyAccessEntry, err)
+	}
+
+	return nil
+}
+
+func createAccessEntries(ctx *sdk.Context, d api.Getter, clusterName string) error {
+	if err := runCreateAccessEntries(ctx, d, clusterName, accessEntriesFromSet(d.Get(KeyAccessEntry))); err != nil {
+		return fmt.Errorf("creating access entries: %w", err)
+	}
+
+	return nil
+}
+
+// listOf returns the items of a TypeSet or TypeList value
+func listOf(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case *schema.Set:
+		return v.List()
+	case []interface{}:
+		return v
+	}
+
+	return nil
+}
+
+func sortedStrings(v interface{}) []string {
+	var ss []string
+
+	for _, item := range listOf(v) {
+		if s, ok := item.(string); ok && s != "" {
+			ss = append(ss, s)
+		}
+	}
+
+	sort.Strings(ss)
+
+	return ss
+}
+
+func interfaces(ss []string) []interface{} {
+	r := []interface{}{}
+
+	for _, s := range ss {
+		r = append(r, s)
+	}
+
+	return r
+}
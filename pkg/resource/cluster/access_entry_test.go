@@ -0,0 +1,185 @@
+package cluster
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	adminPolicyARN = "arn:aws:eks::aws:cluster-access-policy/AmazonEKSClusterAdminPolicy"
+	viewPolicyARN  = "arn:aws:eks::aws:cluster-access-policy/AmazonEKSViewPolicy"
+)
+
+func TestAccessEntryFromValue(t *testing.T) {
+	got := accessEntryFromValue(map[string]interface{}{
+		KeyPrincipalARN:       "arn:aws:iam::123456789012:role/dev",
+		KeyKubernetesUsername: "dev",
+		KeyKubernetesGroups:   []interface{}{"viewers", "developers"},
+		KeyAccessPolicy: []interface{}{
+			map[string]interface{}{KeyPolicyARN: viewPolicyARN, KeyNamespaces: []interface{}{"b", "a"}},
+			map[string]interface{}{KeyPolicyARN: adminPolicyARN},
+		},
+	})
+
+	want := accessEntry{
+		PrincipalARN:       "arn:aws:iam::123456789012:role/dev",
+		KubernetesUsername: "dev",
+		KubernetesGroups:   []string{"developers", "viewers"},
+		AccessPolicies: []accessPolicy{
+			{PolicyARN: adminPolicyARN, AccessScope: accessScope{Type: "cluster"}},
+			{PolicyARN: viewPolicyARN, AccessScope: accessScope{Type: "namespace", Namespaces: []string{"a", "b"}}},
+		},
+	}
+
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("unexpected access entry: want (-), got (+)\n%s", d)
+	}
+}
+
+func TestObservedAccessEntries(t *testing.T) {
+	live, err := parseAccessEntries(`[
+  {"principalARN": "arn:aws:iam::123456789012:role/eksctl-ng1-NodeInstanceRole", "kubernetesGroups": ["system:nodes"]},
+  {"principalARN": "arn:aws:iam::123456789012:role/dev", "kubernetesUsername": "arn:aws:sts::123456789012:assumed-role/dev/{{SessionName}}",
+   "kubernetesGroups": ["viewers", "developers"], "accessPolicies": [{"policyARN": "` + viewPolicyARN + `", "accessScope": {"type": "namespace", "namespaces": ["dev"]}}]}
+]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	configured := []accessEntry{
+		{PrincipalARN: "arn:aws:iam::123456789012:role/dev", KubernetesGroups: []string{"developers"}},
+		{PrincipalARN: "arn:aws:iam::123456789012:role/deleted"},
+	}
+
+	want := []interface{}{
+		map[string]interface{}{
+			KeyPrincipalARN:       "arn:aws:iam::123456789012:role/dev",
+			KeyKubernetesUsername: "",
+			KeyKubernetesGroups:   []interface{}{"developers", "viewers"},
+			KeyAccessPolicy: []interface{}{
+				map[string]interface{}{KeyPolicyARN: viewPolicyARN, KeyNamespaces: []interface{}{"dev"}},
+			},
+		},
+	}
+
+	if d := cmp.Diff(want, observedAccessEntries(live, configured)); d != "" {
+		t.Errorf("unexpected access entries: want (-), got (+)\n%s", d)
+	}
+}
+
+func TestParseAccessEntries_NoEntries(t *testing.T) {
+	entries, err := parseAccessEntries("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("unexpected access entries: %v", entries)
+	}
+}
+
+func TestAccessEntryChanges(t *testing.T) {
+	a := accessEntry{PrincipalARN: "arn:aws:iam::123456789012:role/a", KubernetesGroups: []string{"viewers"}}
+	b := accessEntry{PrincipalARN: "arn:aws:iam::123456789012:role/b"}
+	c := accessEntry{PrincipalARN: "arn:aws:iam::123456789012:role/c"}
+
+	changedA := a
+	changedA.KubernetesGroups = []string{"developers"}
+
+	deleted, created := accessEntryChanges([]accessEntry{a, b}, []accessEntry{changedA, c})
+
+	if d := cmp.Diff([]string{a.PrincipalARN, b.PrincipalARN}, deleted); d != "" {
+		t.Errorf("unexpected deleted principals: want (-), got (+)\n%s", d)
+	}
+
+	if d := cmp.Diff([]accessEntry{changedA, c}, created); d != "" {
+		t.Errorf("unexpected created access entries: want (-), got (+)\n%s", d)
+	}
+}
+
+func TestValidateAccessEntry(t *testing.T) {
+	testcases := []struct {
+		name  string
+		entry accessEntry
+		err   string
+	}{
+		{
+			name:  "valid",
+			entry: accessEntry{PrincipalARN: "arn:aws:iam::123456789012:role/admin", AccessPolicies: []accessPolicy{{PolicyARN: adminPolicyARN}}},
+		},
+		{
+			name:  "system:masters",
+			entry: accessEntry{PrincipalARN: "arn:aws:iam::123456789012:role/admin", KubernetesGroups: []string{"system:masters"}},
+			err:   "AmazonEKSClusterAdminPolicy",
+		},
+		{
+			name:  "iam policy",
+			entry: accessEntry{PrincipalARN: "arn:aws:iam::123456789012:role/admin", AccessPolicies: []accessPolicy{{PolicyARN: "arn:aws:iam::aws:policy/AdministratorAccess"}}},
+			err:   "isn't an EKS access policy ARN",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateAccessEntry(tc.entry)
+
+			switch {
+			case tc.err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+				t.Errorf("expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestAuthenticationModeOperations(t *testing.T) {
+	config := func(mode string) *EksctlClusterConfig {
+		c := &EksctlClusterConfig{Rest: map[string]interface{}{}}
+
+		if mode != "" {
+			c.Rest["accessConfig"] = map[string]interface{}{"authenticationMode": mode}
+		}
+
+		return c
+	}
+
+	if ops, err := authenticationModeOperations(config(""), config(AuthenticationModeAPIAndConfigMap)); err != nil || len(ops) != 1 {
+		t.Errorf("expected %s, got %v, %v", OpUpdateAuthenticationMode, ops, err)
+	}
+
+	if ops, err := authenticationModeOperations(config(AuthenticationModeAPI), config("")); err != nil || len(ops) != 0 {
+		t.Errorf("expected no operation, got %v, %v", ops, err)
+	}
+
+	if _, err := authenticationModeOperations(config(AuthenticationModeAPI), config(AuthenticationModeConfigMap)); err == nil {
+		t.Error("expected error for switching back to CONFIG_MAP")
+	}
+
+	if ops, err := authenticationModeOperations(config(AuthenticationModeAPIAndConfigMap), config(AuthenticationModeAPI)); err != nil || len(ops) != 1 {
+		t.Errorf("expected %s, got %v, %v", OpUpdateAuthenticationMode, ops, err)
+	}
+
+	for _, old := range []string{"", AuthenticationModeConfigMap} {
+		if _, err := authenticationModeOperations(config(old), config(AuthenticationModeAPI)); err == nil {
+			t.Errorf("expected error for switching from %q to API without API_AND_CONFIG_MAP", old)
+		}
+	}
+}
+
+func TestParseAccessEntryID(t *testing.T) {
+	region, clusterName, arn, err := parseAccessEntryID(accessEntryID("us-east-2", "mycluster", "arn:aws:iam::123456789012:role/admin"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if d := cmp.Diff([]string{"us-east-2", "mycluster", "arn:aws:iam::123456789012:role/admin"}, []string{region, clusterName, arn}); d != "" {
+		t.Errorf("unexpected ID parts: want (-), got (+)\n%s", d)
+	}
+
+	if _, _, _, err := parseAccessEntryID("mycluster:admin"); err == nil {
+		t.Error("expected error for malformed ID")
+	}
+}
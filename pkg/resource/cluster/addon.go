@@ -0,0 +1,307 @@
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+	"gopkg.in/yaml.v3"
+)
+
+const KeyAddon = "addon"
+
+// AddonVersionLatest lets eksctl install the latest version of the add-on compatible with the cluster.
+// Add-ons of this version are updated along with the control plane.
+const AddonVersionLatest = "latest"
+
+// legacyAddonComponents maps the `eksctl utils update-*` operations to the EKS add-ons that manage the same components.
+// The operations are skipped for components managed as add-ons.
+var legacyAddonComponents = map[string]string{
+	OpUpdateKubeProxy: "kube-proxy",
+	OpUpdateAWSNode:   "vpc-cni",
+	OpUpdateCoreDNS:   "coredns",
+}
+
+// AddonBlock is an `addon` block, merged into the addons in the cluster.yaml by renderClusterConfig
+type AddonBlock struct {
+	Name                  string
+	Version               string
+	ServiceAccountRoleARN string
+	ConfigurationValues   string
+	ResolveConflicts      string
+}
+
+func addonSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				// version is either a version like `v1.8.7-eksbuild.1` or `latest`.
+				// When omitted, eksctl installs the default version for the cluster.
+				"version": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				"service_account_role_arn": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				// configuration_values is the JSON or YAML configuration of the add-on
+				"configuration_values": {
+					Type:     schema.TypeString,
+					Optional: true,
+					ValidateFunc: func(v interface{}, k string) ([]string, []error) {
+						var values interface{}
+
+						if err := yaml.Unmarshal([]byte(v.(string)), &values); err != nil {
+							return nil, []error{fmt.Errorf("%q: parsing as JSON or YAML: %w", k, err)}
+						}
+
+						return nil, nil
+					},
+				},
+				// resolve_conflicts tells EKS what to do when the add-on's Kubernetes resources have been modified out-of-band
+				"resolve_conflicts": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					ValidateFunc: validation.StringInSlice([]string{"none", "overwrite", "preserve"}, false),
+				},
+			},
+		},
+	}
+}
+
+func readAddonBlocks(v interface{}) []AddonBlock {
+	var blocks []AddonBlock
+
+	items, _ := v.([]interface{})
+
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		blocks = append(blocks, AddonBlock{
+			Name:                  m["name"].(string),
+			Version:               m["version"].(string),
+			ServiceAccountRoleARN: m["service_account_role_arn"].(string),
+			ConfigurationValues:   m["configuration_values"].(string),
+			ResolveConflicts:      m["resolve_conflicts"].(string),
+		})
+	}
+
+	return blocks
+}
+
+// toSpec returns the add-on in the form of an item of addons in the cluster.yaml
+func (a AddonBlock) toSpec() map[string]interface{} {
+	spec := map[string]interface{}{
+		"name": a.Name,
+	}
+
+	for k, v := range map[string]string{
+		"version":               a.Version,
+		"serviceAccountRoleARN": a.ServiceAccountRoleARN,
+		"configurationValues":   a.ConfigurationValues,
+		"resolveConflicts":      a.ResolveConflicts,
+	} {
+		if v != "" {
+			spec[k] = v
+		}
+	}
+
+	return spec
+}
+
+// mergeAddonBlocks appends the add-ons declared in `addon` blocks to the ones declared in the spec
+func mergeAddonBlocks(spec map[string]interface{}, blocks []AddonBlock) error {
+	names := map[string]bool{}
+
+	for _, name := range namesInList(spec["addons"], "name") {
+		names[name] = true
+	}
+
+	for _, a := range blocks {
+		if names[a.Name] {
+			return fmt.Errorf("%s %q: add-on with the same name is already declared", KeyAddon, a.Name)
+		}
+
+		names[a.Name] = true
+
+		items, _ := spec["addons"].([]interface{})
+
+		spec["addons"] = append(items, a.toSpec())
+	}
+
+	return nil
+}
+
+// addons returns the addons entries in the cluster.yaml keyed by their names
+func addons(c *EksctlClusterConfig) map[string]interface{} {
+	return itemsByName(c.Rest["addons"])
+}
+
+func addonNames(c *EksctlClusterConfig) []string {
+	names := namesInList(c.Rest["addons"], "name")
+
+	sort.Strings(names)
+
+	return names
+}
+
+// updatedAddons returns the names of existing add-ons whose version or any other settings have changed
+func updatedAddons(old, new *EksctlClusterConfig) []string {
+	oldAddons := addons(old)
+
+	var names []string
+
+	for name, a := range addons(new) {
+		if o, ok := oldAddons[name]; ok && !reflect.DeepEqual(o, a) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// unpinnedAddons returns the names of existing add-ons whose version is `latest` or omitted,
+// which are updated at every step of a Kubernetes version upgrade.
+// Otherwise add-ons with the version omitted would be left at the versions for the previous Kubernetes version,
+// as `eksctl update addon` keeps the installed version when none is given.
+func unpinnedAddons(old, new *EksctlClusterConfig) []string {
+	oldAddons := addons(old)
+
+	var names []string
+
+	for name, a := range addons(new) {
+		if _, ok := oldAddons[name]; !ok {
+			continue
+		}
+
+		if m, _ := a.(map[string]interface{}); m["version"] == nil || m["version"] == "" || m["version"] == AddonVersionLatest {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// addonUpdateConfig returns the cluster.yaml for `eksctl update addon` to update the add-ons.
+// When version is given, metadata.version is set to it, so that an intermediate step of a version upgrade
+// updates the add-ons for the version of the step rather than the final one.
+// Add-ons with the version omitted are updated to `latest` in that case.
+func addonUpdateConfig(clusterConfig []byte, names []string, version string) ([]byte, error) {
+	config, err := clusterConfigWithItems(clusterConfig, "addons", names)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == "" {
+		return config, nil
+	}
+
+	config, err = clusterConfigWithVersion(config, version)
+	if err != nil {
+		return nil, fmt.Errorf("setting version %s to cluster.yaml: %w", version, err)
+	}
+
+	config, err = clusterConfigWithLatestAddons(config)
+	if err != nil {
+		return nil, fmt.Errorf("setting add-on versions to cluster.yaml: %w", err)
+	}
+
+	return config, nil
+}
+
+// clusterConfigWithLatestAddons returns the cluster.yaml with the version of add-ons without one set to `latest`
+func clusterConfigWithLatestAddons(clusterConfig []byte) ([]byte, error) {
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(clusterConfig, &doc); err != nil {
+		return nil, fmt.Errorf("parsing cluster.yaml: %w", err)
+	}
+
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("parsing cluster.yaml: expected a mapping at the top-level")
+	}
+
+	if seq := mappingValue(doc.Content[0], "addons"); seq != nil && seq.Kind == yaml.SequenceNode {
+		for _, item := range seq.Content {
+			if item.Kind != yaml.MappingNode {
+				continue
+			}
+
+			if v := mappingValue(item, "version"); v != nil {
+				if v.Value == "" {
+					v.Value = AddonVersionLatest
+					v.Tag = "!!str"
+				}
+
+				continue
+			}
+
+			item.Content = append(item.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"},
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: AddonVersionLatest},
+			)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("encoding cluster.yaml: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// listAddons returns the names of the add-ons installed in the cluster
+func listAddons(ctx *sdk.Context, clusterName string) (map[string]bool, error) {
+	svc := eks.New(ctx.Session())
+
+	names := map[string]bool{}
+
+	var nextToken *string
+
+	for {
+		res, err := svc.ListAddons(&eks.ListAddonsInput{
+			ClusterName: aws.String(clusterName),
+			NextToken:   nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("listing add-ons: %w", err)
+		}
+
+		for _, n := range res.Addons {
+			names[aws.StringValue(n)] = true
+		}
+
+		nextToken = res.NextToken
+
+		if nextToken == nil {
+			break
+		}
+	}
+
+	return names, nil
+}
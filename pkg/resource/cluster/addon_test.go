@@ -0,0 +1,122 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMergeAddonBlocks(t *testing.T) {
+	spec := map[string]interface{}{}
+
+	if err := yaml.Unmarshal([]byte(`
+addons:
+- name: vpc-cni
+`), spec); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := []AddonBlock{
+		{
+			Name:                  "coredns",
+			Version:               "latest",
+			ServiceAccountRoleARN: "arn:aws:iam::123456789012:role/coredns",
+			ConfigurationValues:   `{"replicaCount": 3}`,
+			ResolveConflicts:      "overwrite",
+		},
+	}
+
+	if err := mergeAddonBlocks(spec, blocks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := yaml.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `addons:
+    - name: vpc-cni
+    - configurationValues: '{"replicaCount": 3}'
+      name: coredns
+      resolveConflicts: overwrite
+      serviceAccountRoleARN: arn:aws:iam::123456789012:role/coredns
+      version: latest
+`
+
+	if d := cmp.Diff(want, string(got)); d != "" {
+		t.Errorf("unexpected spec: want (-), got (+)\n%s", d)
+	}
+
+	if err := mergeAddonBlocks(spec, []AddonBlock{{Name: "vpc-cni"}}); err == nil {
+		t.Error("expected error for the duplicate add-on name")
+	}
+}
+
+func TestAddonUpdateConfig(t *testing.T) {
+	config := `metadata:
+  name: mycluster
+  version: "1.20"
+addons:
+- name: coredns
+  version: latest
+- name: vpc-cni
+  version: latest
+`
+
+	got, err := addonUpdateConfig([]byte(config), []string{"coredns"}, "1.18")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var c struct {
+		Metadata map[string]interface{}   `yaml:"metadata"`
+		Addons   []map[string]interface{} `yaml:"addons"`
+	}
+
+	if err := yaml.Unmarshal(got, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := c.Metadata["version"]; v != "1.18" {
+		t.Errorf("unexpected version: want 1.18, got %v", v)
+	}
+
+	if d := cmp.Diff([]map[string]interface{}{{"name": "coredns", "version": "latest"}}, c.Addons); d != "" {
+		t.Errorf("unexpected addons: want (-), got (+)\n%s", d)
+	}
+}
+
+func TestAddonUpdateConfig_Unversioned(t *testing.T) {
+	config := `metadata:
+  name: mycluster
+  version: "1.20"
+addons:
+- name: kube-proxy
+- name: coredns
+  version: v1.8.0-eksbuild.1
+`
+
+	got, err := addonUpdateConfig([]byte(config), []string{"kube-proxy", "coredns"}, "1.18")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var c struct {
+		Addons []map[string]interface{} `yaml:"addons"`
+	}
+
+	if err := yaml.Unmarshal(got, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []map[string]interface{}{
+		{"name": "kube-proxy", "version": "latest"},
+		{"name": "coredns", "version": "v1.8.0-eksbuild.1"},
+	}
+
+	if d := cmp.Diff(want, c.Addons); d != "" {
+		t.Errorf("unexpected addons: want (-), got (+)\n%s", d)
+	}
+}
@@ -0,0 +1,222 @@
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/courier"
+)
+
+var metricSchema = &courier.MetricSchema{
+	Min:        "min",
+	Max:        "max",
+	Interval:   "interval",
+	Address:    "address",
+	Query:      "query",
+	AWSProfile: "aws_profile",
+	AWSRegion:  "aws_region",
+}
+
+func metricsSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"provider": {
+					Type:         schema.TypeString,
+					Required:     true,
+					ValidateFunc: validation.StringInSlice([]string{"datadog", "cloudwatch"}, false),
+				},
+				"address": {
+					Type:     schema.TypeString,
+					Optional: true,
+					Default:  "",
+				},
+				"query": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"max": {
+					Type:     schema.TypeFloat,
+					Optional: true,
+				},
+				"min": {
+					Type:     schema.TypeFloat,
+					Optional: true,
+				},
+				"interval": {
+					Type:     schema.TypeString,
+					Optional: true,
+					Default:  "1m",
+				},
+				"aws_region": {
+					Type:     schema.TypeString,
+					Optional: true,
+					Default:  "",
+				},
+				"aws_profile": {
+					Type:     schema.TypeString,
+					Optional: true,
+					Default:  "",
+				},
+			},
+		},
+	}
+}
+
+func albAttachmentSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"node_group_name": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"weight": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      100,
+					ValidateFunc: validation.IntBetween(0, 100),
+				},
+				// We specify listener rather than alb, so that we can reuse any listener that is created out-of-band
+				"listener_arn": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"protocol": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      "http",
+					ValidateFunc: validation.StringInSlice([]string{"http", "https"}, true),
+				},
+				"node_port": {
+					Type:     schema.TypeInt,
+					Required: true,
+				},
+				// alb_attachment manages only one alb listener rule. This specifies the priority of the only rule
+				"priority": {
+					Type:     schema.TypeInt,
+					Required: true,
+				},
+				"hosts": {
+					Type:     schema.TypeSet,
+					Optional: true,
+					Set:      schema.HashString,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+				"path_patterns": {
+					Type:     schema.TypeSet,
+					Optional: true,
+					Set:      schema.HashString,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+				"methods": {
+					Type:     schema.TypeSet,
+					Optional: true,
+					Set:      schema.HashString,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+				"source_ips": {
+					Type:     schema.TypeSet,
+					Optional: true,
+					Set:      schema.HashString,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+				// headers maps each HTTP header name to the value that the http-header condition matches against
+				"headers": {
+					Type:     schema.TypeMap,
+					Optional: true,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+				"querystrings": {
+					Type:     schema.TypeMap,
+					Optional: true,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+				KeyMetrics: metricsSchema(),
+			},
+		},
+	}
+}
+
+func readMetrics(raw []interface{}) ([]courier.Metric, error) {
+	metrics, err := courier.LoadMetrics(raw, metricSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	// Terraform reads an unset `max` as 0, which would fail every analysis.
+	for i := range metrics {
+		if m := metrics[i].Max; m != nil && *m == 0 {
+			metrics[i].Max = nil
+		}
+	}
+
+	return metrics, nil
+}
+
+func readALBAttachments(raw []interface{}) ([]courier.ALBAttachment, error) {
+	var attachments []courier.ALBAttachment
+
+	setToStrings := func(v interface{}) []string {
+		var ss []string
+
+		if s, ok := v.(*schema.Set); ok && s != nil {
+			for _, item := range s.List() {
+				ss = append(ss, item.(string))
+			}
+		}
+
+		return ss
+	}
+
+	for i, r := range raw {
+		m := r.(map[string]interface{})
+
+		a := courier.ALBAttachment{
+			NodeGroupName: m["node_group_name"].(string),
+			Weght:         m["weight"].(int),
+			ListenerARN:   m["listener_arn"].(string),
+			NodePort:      m["node_port"].(int),
+			Protocol:      m["protocol"].(string),
+			Priority:      m["priority"].(int),
+			Hosts:         setToStrings(m["hosts"]),
+			PathPatterns:  setToStrings(m["path_patterns"]),
+			Methods:       setToStrings(m["methods"]),
+			SourceIPs:     setToStrings(m["source_ips"]),
+		}
+
+		if headers, ok := m["headers"].(map[string]interface{}); ok && len(headers) > 0 {
+			a.Headers = map[string][]string{}
+
+			for k, v := range headers {
+				a.Headers[k] = []string{v.(string)}
+			}
+		}
+
+		if qs, ok := m["querystrings"].(map[string]interface{}); ok && len(qs) > 0 {
+			a.QueryStrings = map[string]string{}
+
+			for k, v := range qs {
+				a.QueryStrings[k] = v.(string)
+			}
+		}
+
+		if v, ok := m[KeyMetrics].([]interface{}); ok {
+			metrics, err := readMetrics(v)
+			if err != nil {
+				return nil, fmt.Errorf("reading metrics of alb_attachment %d: %w", i, err)
+			}
+
+			a.Metrics = metrics
+		}
+
+		attachments = append(attachments, a)
+	}
+
+	return attachments, nil
+}
@@ -61,9 +61,19 @@ func doAttachAutoScalingGroupsToTargetGroups(ctx *sdk.Context, set *ClusterSet)
 			ngName := strings.TrimPrefix(*s.StackName, stackNamePrefix)
 
 			for _, l := range set.ListenerStatuses {
+				// On update, no new target group is created so the nodegroups are attached to the current one
+				tg := l.DesiredTG
+				if tg == nil {
+					tg = l.CurrentTG
+				}
+
+				if tg == nil {
+					continue
+				}
+
 				for _, a := range l.ALBAttachments {
-					if a.NodeGroupName == ngName {
-						targetGroupARNS = append(targetGroupARNS, l.DesiredTG.TargetGroupArn)
+					if a.NodeGroupName == ngName || set.Cluster.ImmutableNodeGroups && a.NodeGroupName == baseNodeGroupName(ngName) {
+						targetGroupARNS = append(targetGroupARNS, tg.TargetGroupArn)
 					}
 				}
 			}
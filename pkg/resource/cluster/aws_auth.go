@@ -0,0 +1,197 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/api"
+)
+
+// KeyManageAWSAuth is either `additive`, which manages only the aws-auth mappings in iam_identity_mapping,
+// or `authoritative`, which also removes the mappings created outside Terraform
+const KeyManageAWSAuth = "manage_aws_auth"
+
+const (
+	ManageAWSAuthAdditive      = "additive"
+	ManageAWSAuthAuthoritative = "authoritative"
+)
+
+// iamIdentityMappingResource is the schema of both iam_identity_mapping and aws_auth_configmap.
+// Each mapping maps either an IAM role or user by `iamarn`, or all the IAM users and roles of an AWS `account`.
+func iamIdentityMappingResource() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"iamarn": {
+				Optional: true,
+				Type:     schema.TypeString,
+			},
+			"account": {
+				Optional: true,
+				Type:     schema.TypeString,
+			},
+			"username": {
+				Optional: true,
+				Type:     schema.TypeString,
+			},
+			"groups": {
+				Optional: true,
+				Type:     schema.TypeList,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+// validateIAMIdentityMappings fails on the mappings that eksctl can't create
+func validateIAMIdentityMappings(d api.Getter) error {
+	s, ok := d.Get(KeyIAMIdentityMapping).(*schema.Set)
+	if !ok {
+		return nil
+	}
+
+	for _, v := range s.List() {
+		m := iamIdentityMappingValue(v.(map[string]interface{}))
+
+		arn, account := m["iamarn"].(string), m["account"].(string)
+
+		switch {
+		case arn == "" && account == "":
+			return fmt.Errorf("%s: either iamarn or account is required", KeyIAMIdentityMapping)
+		case arn != "" && account != "":
+			return fmt.Errorf("%s: iamarn %s and account %s can't be set at once", KeyIAMIdentityMapping, arn, account)
+		case account != "" && (m["username"] != "" || len(m["groups"].([]interface{})) > 0):
+			return fmt.Errorf("%s: account %s can't have username or groups, as all its users and roles are mapped as-is", KeyIAMIdentityMapping, account)
+		}
+	}
+
+	return nil
+}
+
+// iamIdentityMappingKey returns what identifies the mapping in aws-auth
+func iamIdentityMappingKey(m map[string]interface{}) string {
+	if arn, _ := m["iamarn"].(string); arn != "" {
+		return arn
+	}
+
+	if account, _ := m["account"].(string); account != "" {
+		return "account:" + account
+	}
+
+	return ""
+}
+
+// iamIdentityMappingValue normalizes a mapping read from aws-auth or the state, so that the mappings can be compared
+func iamIdentityMappingValue(m map[string]interface{}) map[string]interface{} {
+	arn, _ := m["iamarn"].(string)
+	account, _ := m["account"].(string)
+	username, _ := m["username"].(string)
+
+	groups := []interface{}{}
+
+	if gs, ok := m["groups"].([]interface{}); ok {
+		groups = append(groups, gs...)
+	}
+
+	return map[string]interface{}{
+		"iamarn":   arn,
+		"account":  account,
+		"username": username,
+		"groups":   groups,
+	}
+}
+
+// isNodeRoleMapping returns true for the mappings of nodegroup and fargate roles, which are maintained by eksctl
+func isNodeRoleMapping(m map[string]interface{}) bool {
+	gs, _ := m["groups"].([]interface{})
+
+	for _, g := range gs {
+		for _, ng := range nodeRoleGroups {
+			if g == ng {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// observedIAMIdentityMappings returns the live mappings to be stored as iam_identity_mapping, so that Terraform shows the drift.
+// The mappings for the configured ARNs and accounts are always returned. When authoritative, all the other mappings are returned too,
+// except the ones of nodegroup and fargate roles, so that they're removed on the next apply.
+func observedIAMIdentityMappings(live []map[string]interface{}, configured []interface{}, authoritative bool) []interface{} {
+	keys := map[string]bool{}
+
+	for _, v := range configured {
+		keys[iamIdentityMappingKey(v.(map[string]interface{}))] = true
+	}
+
+	var r []interface{}
+
+	for _, m := range live {
+		k := iamIdentityMappingKey(m)
+		if k == "" {
+			continue
+		}
+
+		if keys[k] || authoritative && !isNodeRoleMapping(m) {
+			r = append(r, iamIdentityMappingValue(m))
+		}
+	}
+
+	return r
+}
+
+// iamIdentityMappingValues normalizes all the live mappings, including the ones maintained by eksctl
+func iamIdentityMappingValues(live []map[string]interface{}) []interface{} {
+	var r []interface{}
+
+	for _, m := range live {
+		if iamIdentityMappingKey(m) != "" {
+			r = append(r, iamIdentityMappingValue(m))
+		}
+	}
+
+	return r
+}
+
+// sortIAMIdentityMappings sorts the mappings by their keys for diffing
+func sortIAMIdentityMappings(mappings []map[string]interface{}) {
+	sort.SliceStable(mappings, func(i, j int) bool {
+		return iamIdentityMappingKey(mappings[i]) < iamIdentityMappingKey(mappings[j])
+	})
+}
+
+// iamIdentityMappingArgs returns the flags of `eksctl create iamidentitymapping` for the mapping
+func iamIdentityMappingArgs(m map[string]interface{}) []string {
+	m = iamIdentityMappingValue(m)
+
+	if account := m["account"].(string); account != "" {
+		return []string{"--account", account}
+	}
+
+	args := []string{"--arn", m["iamarn"].(string)}
+
+	if username := m["username"].(string); username != "" {
+		args = append(args, "--username", username)
+	}
+
+	for _, g := range m["groups"].([]interface{}) {
+		args = append(args, "--group", g.(string))
+	}
+
+	return args
+}
+
+// iamIdentityMappingDeleteArgs returns the flags of `eksctl delete iamidentitymapping` for the mapping
+func iamIdentityMappingDeleteArgs(m map[string]interface{}) []string {
+	m = iamIdentityMappingValue(m)
+
+	if account := m["account"].(string); account != "" {
+		return []string{"--account", account}
+	}
+
+	return []string{"--arn", m["iamarn"].(string)}
+}
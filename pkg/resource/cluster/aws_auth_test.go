@@ -0,0 +1,101 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func TestObservedIAMIdentityMappings(t *testing.T) {
+	live := []map[string]interface{}{
+		{"iamarn": "arn:aws:iam::123456789012:role/admin", "username": "admin", "groups": []interface{}{"system:masters", "ops"}},
+		{"iamarn": "arn:aws:iam::123456789012:role/manual", "username": "manual"},
+		{"iamarn": "arn:aws:iam::123456789012:role/eksctl-ng1-NodeInstanceRole", "username": "system:node:{{EC2PrivateDNSName}}", "groups": []interface{}{"system:bootstrappers", "system:nodes"}},
+		{"account": "123456789012"},
+	}
+
+	configured := []interface{}{
+		map[string]interface{}{"iamarn": "arn:aws:iam::123456789012:role/admin", "account": "", "username": "admin", "groups": []interface{}{"system:masters"}},
+	}
+
+	admin := map[string]interface{}{"iamarn": "arn:aws:iam::123456789012:role/admin", "account": "", "username": "admin", "groups": []interface{}{"system:masters", "ops"}}
+
+	t.Run("additive", func(t *testing.T) {
+		want := []interface{}{admin}
+
+		if d := cmp.Diff(want, observedIAMIdentityMappings(live, configured, false)); d != "" {
+			t.Errorf("unexpected mappings: want (-), got (+)\n%s", d)
+		}
+	})
+
+	t.Run("authoritative", func(t *testing.T) {
+		want := []interface{}{
+			admin,
+			map[string]interface{}{"iamarn": "arn:aws:iam::123456789012:role/manual", "account": "", "username": "manual", "groups": []interface{}{}},
+			map[string]interface{}{"iamarn": "", "account": "123456789012", "username": "", "groups": []interface{}{}},
+		}
+
+		if d := cmp.Diff(want, observedIAMIdentityMappings(live, configured, true)); d != "" {
+			t.Errorf("unexpected mappings: want (-), got (+)\n%s", d)
+		}
+	})
+}
+
+func TestIAMIdentityMappingArgs(t *testing.T) {
+	testcases := []struct {
+		name    string
+		mapping map[string]interface{}
+		want    []string
+	}{
+		{
+			name:    "role",
+			mapping: map[string]interface{}{"iamarn": "arn:aws:iam::123456789012:role/admin", "username": "admin", "groups": []interface{}{"system:masters"}},
+			want:    []string{"--arn", "arn:aws:iam::123456789012:role/admin", "--username", "admin", "--group", "system:masters"},
+		},
+		{
+			name:    "no groups",
+			mapping: map[string]interface{}{"iamarn": "arn:aws:iam::123456789012:role/viewer", "username": "viewer", "groups": []interface{}{}},
+			want:    []string{"--arn", "arn:aws:iam::123456789012:role/viewer", "--username", "viewer"},
+		},
+		{
+			name:    "account",
+			mapping: map[string]interface{}{"account": "123456789012"},
+			want:    []string{"--account", "123456789012"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if d := cmp.Diff(tc.want, iamIdentityMappingArgs(tc.mapping)); d != "" {
+				t.Errorf("unexpected args: want (-), got (+)\n%s", d)
+			}
+		})
+	}
+}
+
+func TestValidateIAMIdentityMappings(t *testing.T) {
+	set := func(mappings ...map[string]interface{}) *schema.Set {
+		s := schema.NewSet(schema.HashResource(iamIdentityMappingResource()), nil)
+		for _, m := range mappings {
+			s.Add(m)
+		}
+
+		return s
+	}
+
+	valid := set(
+		map[string]interface{}{"iamarn": "arn:aws:iam::123456789012:role/admin", "username": "admin", "groups": []interface{}{}},
+		map[string]interface{}{"account": "123456789012", "groups": []interface{}{}},
+	)
+
+	if err := validateIAMIdentityMappings(newFakeChangeGetter(nil, map[string]interface{}{KeyIAMIdentityMapping: valid})); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	invalid := set(map[string]interface{}{"account": "123456789012", "username": "admin", "groups": []interface{}{}})
+
+	if err := validateIAMIdentityMappings(newFakeChangeGetter(nil, map[string]interface{}{KeyIAMIdentityMapping: invalid})); err == nil {
+		t.Error("expected error for the account mapping with username")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
 	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/api"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/courier"
@@ -41,6 +42,15 @@ const (
 	KeyOIDCProviderURL  = "oidc_provider_url"
 	KeyOIDCProviderARN  = "oidc_provider_arn"
 	KeySecurityGroupIDs = "security_group_ids"
+
+	// Attributes of the live EKS cluster, so that the kubernetes and helm providers can be configured without kubeconfig
+	KeyEndpoint                 = "endpoint"
+	KeyCertificateAuthorityData = "certificate_authority_data"
+	KeyARN                      = "arn"
+	KeyPlatformVersion          = "platform_version"
+	KeyStatus                   = "status"
+	KeyClusterSecurityGroupID   = "cluster_security_group_id"
+	KeyServiceIPv4CIDR          = "service_ipv4_cidr"
 )
 
 const DefaultAPIVersion = "eksctl.io/v1alpha5"
@@ -78,6 +88,27 @@ type Cluster struct {
 
 	DeleteKubernetesResourcesBeforeDestroy []DeleteKubernetesResource
 
+	// NodeGroups are declared in `node_group` and `managed_node_group` blocks, in addition to the ones in Spec
+	NodeGroups []NodeGroupBlock
+
+	// Addons are declared in `addon` blocks, in addition to the ones in Spec
+	Addons []AddonBlock
+
+	// ImmutableNodeGroups suffixes nodegroup names with the hash of their settings, so that changed nodegroups are replaced
+	ImmutableNodeGroups bool
+
+	// DeletionProtection makes the provider refuse to destroy the cluster
+	DeletionProtection bool
+
+	// ForceDestroy skips the checks for resources that would be orphaned by destroying the cluster
+	ForceDestroy bool
+
+	// CleanupAWSResourcesBeforeDestroy deletes the AWS resources created by Kubernetes controllers before the cluster is destroyed
+	CleanupAWSResourcesBeforeDestroy bool
+
+	// ManageAWSAuth is either ManageAWSAuthAdditive or ManageAWSAuthAuthoritative
+	ManageAWSAuth string
+
 	PublicSubnetIDs  []string
 	PrivateSubnetIDs []string
 	ALBAttachments   []courier.ALBAttachment
@@ -174,10 +205,59 @@ func (m *Manager) PrepareClusterSet(d api.UniqueResourceGetter, optNewId ...stri
 		return nil, err
 	}
 
+	var id string
+	var newId string
+
+	if len(optNewId) > 0 {
+		id = optNewId[0]
+		newId = optNewId[0]
+	} else {
+		id = d.Id()
+	}
+
+	if id == "" {
+		return nil, errors.New("Missing Resource ID. This must be a bug!")
+	}
+
+	clusterName := m.getClusterName(a, id)
+
+	listenerStatuses, err := planListenerChanges(a, d.Id(), newId)
+	if err != nil {
+		return nil, fmt.Errorf("planning listener changes: %v", err)
+	}
+
+	c, mergedClusterConfig, err := m.renderClusterConfig(d, a, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	a.PublicSubnetIDs = subnetIDs(c.VPC.Subnets.Public)
+	a.PrivateSubnetIDs = subnetIDs(c.VPC.Subnets.Private)
+
+	a.VPCID = c.VPC.ID
+
+	return &ClusterSet{
+		ClusterID:        id,
+		ClusterName:      clusterName,
+		Cluster:          a,
+		ClusterConfig:    mergedClusterConfig,
+		ListenerStatuses: listenerStatuses,
+		CanaryOpts: courier.CanaryOpts{
+			CanaryAdvancementInterval: 5 * time.Second,
+			CanaryAdvancementStep:     5,
+			Region:                    a.Region,
+			ClusterName:               string(clusterName),
+		},
+	}, nil
+}
+
+// renderClusterConfig generates the cluster.yaml passed to eksctl from the resource attributes.
+// Unlike PrepareClusterSet, it has no side effects so that it can be used for planning.
+func (m *Manager) renderClusterConfig(d api.Getter, a *Cluster, clusterName ClusterName) (*EksctlClusterConfig, []byte, error) {
 	spec := map[string]interface{}{}
 
 	if err := yaml.Unmarshal([]byte(a.Spec), spec); err != nil {
-		return nil, fmt.Errorf("parsing used-provided cluster.yaml: %w: INPUT:\n%s", err, a.Spec)
+		return nil, nil, fmt.Errorf("parsing used-provided cluster.yaml: %w: INPUT:\n%s", err, a.Spec)
 	}
 
 	if a.VPCID != "" {
@@ -198,7 +278,21 @@ func (m *Manager) PrepareClusterSet(d api.UniqueResourceGetter, optNewId ...stri
 		}
 
 		if !set {
-			return nil, fmt.Errorf("bug: failed to set vpc.id in cluster.yaml: type = %T, value = %v", rawVPC, rawVPC)
+			return nil, nil, fmt.Errorf("bug: failed to set vpc.id in cluster.yaml: type = %T, value = %v", rawVPC, rawVPC)
+		}
+	}
+
+	if err := mergeNodeGroupBlocks(spec, a.NodeGroups); err != nil {
+		return nil, nil, err
+	}
+
+	if err := mergeAddonBlocks(spec, a.Addons); err != nil {
+		return nil, nil, err
+	}
+
+	if a.ImmutableNodeGroups {
+		if err := renameImmutableNodeGroups(spec); err != nil {
+			return nil, nil, err
 		}
 	}
 
@@ -210,44 +304,34 @@ func (m *Manager) PrepareClusterSet(d api.UniqueResourceGetter, optNewId ...stri
 		enc.SetIndent(2)
 
 		if err := enc.Encode(spec); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		specStr = buf.String()
 	}
 
-	var id string
-	var newId string
-
-	if len(optNewId) > 0 {
-		id = optNewId[0]
-		newId = optNewId[0]
-	} else {
-		id = d.Id()
+	tags := map[string]interface{}{}
+	if v := d.Get(KeyTags); v != nil {
+		if t, ok := v.(map[string]interface{}); ok {
+			for k, v := range t {
+				tags[k] = v
+			}
+		}
 	}
 
-	if id == "" {
-		return nil, errors.New("Missing Resource ID. This must be a bug!")
+	if !m.DisableClusterNameSuffix {
+		// This lets getLiveClusterInfo tell which revision the live cluster was deployed from
+		if rev, ok := d.Get(KeyRevision).(int); ok {
+			tags[TagKeyRevision] = strconv.Itoa(rev)
+		}
 	}
 
-	clusterName := m.getClusterName(a, id)
-
-	listenerStatuses, err := planListenerChanges(a, d.Id(), newId)
+	tagsJsonBs, err := json.Marshal(tags)
 	if err != nil {
-		return nil, fmt.Errorf("planning listener changes: %v", err)
+		return nil, nil, fmt.Errorf("marshalling eksctl_cluster tags to json: %w", err)
 	}
 
-	tagsJson := "{}"
-	if v := d.Get(KeyTags); v != nil {
-		if tags, ok := v.(map[string]interface{}); ok {
-			tagsJsonBs, err := json.Marshal(tags)
-			if err != nil {
-				return nil, fmt.Errorf("marshalling eksctl_cluster tags to json: %w", err)
-			}
-
-			tagsJson = string(tagsJsonBs)
-		}
-	}
+	tagsJson := string(tagsJsonBs)
 
 	seedClusterConfig := []byte(fmt.Sprintf(`
 apiVersion: %s
@@ -265,52 +349,18 @@ metadata:
 	c := clusterConfigNew()
 
 	if err := yaml.Unmarshal(seedClusterConfig, &c); err != nil {
-		return nil, fmt.Errorf("parsing generate cluster.yaml: %w: INPUT:\n%s", err, string(seedClusterConfig))
+		return nil, nil, fmt.Errorf("parsing generate cluster.yaml: %w: INPUT:\n%s", err, string(seedClusterConfig))
 	}
-	//
-	//for i := range c.NodeGroups {
-	//	ng := c.NodeGroups[i]
-	//
-	//	for _, l := range listenerStatuses {
-	//		for _, a := range l.ALBAttachments {
-	//			if ng.Name == a.NodeGroupName {
-	//				ng.TargetGroupARNS = append(ng.TargetGroupARNS, *l.DesiredTG.TargetGroupArn)
-	//			}
-	//		}
-	//	}
-	//}
 
 	mergedClusterConfig, err := clusterConfigToYAML(c)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	log.Printf("seed cluster config:\n%s", string(seedClusterConfig))
 	log.Printf("merged cluster config:\n%s", string(mergedClusterConfig))
 
-	for _, s := range c.VPC.Subnets.Public {
-		a.PublicSubnetIDs = append(a.PublicSubnetIDs, s.ID)
-	}
-
-	for _, s := range c.VPC.Subnets.Public {
-		a.PrivateSubnetIDs = append(a.PrivateSubnetIDs, s.ID)
-	}
-
-	a.VPCID = c.VPC.ID
-
-	return &ClusterSet{
-		ClusterID:        id,
-		ClusterName:      clusterName,
-		Cluster:          a,
-		ClusterConfig:    mergedClusterConfig,
-		ListenerStatuses: listenerStatuses,
-		CanaryOpts: courier.CanaryOpts{
-			CanaryAdvancementInterval: 5 * time.Second,
-			CanaryAdvancementStep:     5,
-			Region:                    a.Region,
-			ClusterName:               string(clusterName),
-		},
-	}, nil
+	return &c, mergedClusterConfig, nil
 }
 
 func clusterConfigToYAML(c EksctlClusterConfig) ([]byte, error) {
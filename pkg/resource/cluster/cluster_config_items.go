@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// itemsByName returns the items of a list like fargateProfiles and addons in the cluster.yaml keyed by their names
+func itemsByName(v interface{}) map[string]interface{} {
+	r := map[string]interface{}{}
+
+	items, _ := v.([]interface{})
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			if name, ok := m["name"].(string); ok && name != "" {
+				r[name] = m
+			}
+		}
+	}
+
+	return r
+}
+
+// clusterConfigWithItems returns the cluster.yaml with the items of the list at `key` other than `names` removed,
+// so that e.g. `eksctl create fargateprofile -f` creates only the profiles that don't exist yet.
+func clusterConfigWithItems(clusterConfig []byte, key string, names []string) ([]byte, error) {
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(clusterConfig, &doc); err != nil {
+		return nil, fmt.Errorf("parsing cluster.yaml: %w", err)
+	}
+
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("parsing cluster.yaml: expected a mapping at the top-level")
+	}
+
+	included := map[string]bool{}
+	for _, n := range names {
+		included[n] = true
+	}
+
+	if seq := mappingValue(doc.Content[0], key); seq != nil && seq.Kind == yaml.SequenceNode {
+		var items []*yaml.Node
+
+		for _, item := range seq.Content {
+			if name := mappingValue(item, "name"); name != nil && included[name.Value] {
+				items = append(items, item)
+			}
+		}
+
+		seq.Content = items
+	}
+
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("encoding cluster.yaml: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,37 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClusterConfigWithItems(t *testing.T) {
+	config := `metadata:
+  name: mycluster
+fargateProfiles:
+  - name: fp1
+    selectors:
+      - namespace: default
+  - name: fp2
+    selectors:
+      - namespace: kube-system
+`
+
+	want := `metadata:
+  name: mycluster
+fargateProfiles:
+  - name: fp2
+    selectors:
+      - namespace: kube-system
+`
+
+	got, err := clusterConfigWithItems([]byte(config), "fargateProfiles", []string{"fp2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if d := cmp.Diff(want, string(got)); d != "" {
+		t.Errorf("unexpected cluster config: want (-), got (+)\n%s", d)
+	}
+}
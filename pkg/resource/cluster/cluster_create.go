@@ -2,6 +2,7 @@ package cluster
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -17,7 +18,7 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
 
-func (m *Manager) createCluster(d *schema.ResourceData) (*ClusterSet, error) {
+func (m *Manager) createCluster(runCtx context.Context, d *schema.ResourceData) (*ClusterSet, error) {
 	id := newClusterID()
 
 	log.Printf("[DEBUG] creating eksctl cluster with id %q", id)
@@ -27,46 +28,86 @@ func (m *Manager) createCluster(d *schema.ResourceData) (*ClusterSet, error) {
 		return nil, err
 	}
 
+	d.MarkNewResource()
+
+	if err := doCreateCluster(runCtx, d, set); err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+// doCreateCluster creates the cluster described by the set, and then runs every post-creation step
+// like writing kubeconfig, applying manifests and attaching nodegroups to target groups.
+// It is shared between the initial creation and the blue-green replacement of a cluster.
+func doCreateCluster(runCtx context.Context, d *schema.ResourceData, set *ClusterSet) error {
+	if err := doEksctlCreateCluster(runCtx, d, set); err != nil {
+		return err
+	}
+
+	return doSetUpCluster(runCtx, d, set)
+}
+
+// doEksctlCreateCluster runs `eksctl create cluster`
+func doEksctlCreateCluster(runCtx context.Context, d *schema.ResourceData, set *ClusterSet) error {
 	cluster := set.Cluster
 
-	ctx := mustNewContext(cluster)
+	ctx := mustNewContext(runCtx, cluster)
 
 	if err := createVPCResourceTags(cluster, set.ClusterName); err != nil {
-		return nil, err
+		return err
 	}
 
 	cmd, err := newEksctlCommandWithAWSProfile(cluster, "create", "cluster", "-f", "-")
 	if err != nil {
-		return nil, fmt.Errorf("creating eksctl-create command: %w", err)
+		return fmt.Errorf("creating eksctl-create command: %w", err)
 	}
 
 	cmd.Stdin = bytes.NewReader(set.ClusterConfig)
 
-	if err := ctx.Create(cmd, d, id); err != nil {
-		return nil, fmt.Errorf("running `eksctl create cluster`: %w: USED CLUSTER CONFIG:\n%s", err, string(set.ClusterConfig))
+	if err := ctx.Update(cmd, d); err != nil {
+		return fmt.Errorf("running `eksctl create cluster`: %w: USED CLUSTER CONFIG:\n%s", err, string(set.ClusterConfig))
+	}
+
+	return nil
+}
+
+// doSetUpCluster prepares the cluster created by `eksctl create cluster` for serving, from tagging the VPC resources
+// to creating access entries
+func doSetUpCluster(runCtx context.Context, d *schema.ResourceData, set *ClusterSet) error {
+	cluster := set.Cluster
+
+	ctx := mustNewContext(runCtx, cluster)
+
+	if err := createEksctlManagedVPCResourceTags(ctx, cluster, set.ClusterName); err != nil {
+		return err
 	}
 
 	if err := doWriteKubeconfig(ctx, d, string(set.ClusterName), cluster.Region); err != nil {
-		return nil, err
+		return err
 	}
 
-	if err := doApplyKubernetesManifests(ctx, cluster, id); err != nil {
-		return nil, err
+	if err := doApplyKubernetesManifests(ctx, cluster, string(set.ClusterName)); err != nil {
+		return err
 	}
 
 	if err := doAttachAutoScalingGroupsToTargetGroups(ctx, set); err != nil {
-		return nil, err
+		return err
 	}
 
-	if err := doCheckPodsReadiness(ctx, cluster, id); err != nil {
-		return nil, err
+	if err := doCheckPodsReadiness(ctx, cluster, string(set.ClusterName)); err != nil {
+		return err
 	}
 
-	if err := createIAMIdentityMapping(ctx, d, cluster); err != nil {
-		return nil, err
+	if err := createIAMIdentityMapping(ctx, d, string(set.ClusterName)); err != nil {
+		return err
 	}
 
-	return set, nil
+	if err := createAccessEntries(ctx, d, string(set.ClusterName)); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func (m *Manager) doPlanKubeconfig(d *tfsdk.DiffReadWrite) error {
@@ -128,38 +169,30 @@ func doWriteKubeconfig(ctx *sdk.Context, d api.ReadWrite, clusterName, region st
 	retryDelay := 5 * time.Second
 	for i := 0; i < retries; i++ {
 		kubectlVersion := exec.Command(kubectlBin, "version")
-		kubectlVersion.Env = append(cmd.Env, os.Environ()...)
-		kubectlVersion.Env = append(cmd.Env, "KUBECONFIG="+path)
+		kubectlVersion.Env = append(kubectlVersion.Env, os.Environ()...)
+		kubectlVersion.Env = append(kubectlVersion.Env, "KUBECONFIG="+path)
 
-		out, err := kubectlVersion.CombinedOutput()
+		_, err := ctx.Run(kubectlVersion)
 		if err == nil {
 			break
 		}
 
-		log.Printf("Retrying kubectl version error with KUBECONFIG=%s: %v: COMBINED OUTPUT:\n%s", path, err, string(out))
-		time.Sleep(retryDelay)
+		log.Printf("Retrying kubectl version error with KUBECONFIG=%s: %v", path, err)
+
+		select {
+		case <-ctx.Context().Done():
+			return fmt.Errorf("waiting for kubeconfig %s to work: %w", path, ctx.Context().Err())
+		case <-time.After(retryDelay):
+		}
 	}
 
 	return nil
 }
 
-func createIAMIdentityMapping(ctx *sdk.Context, d api.ReadWrite, cluster *Cluster) error {
-	iams, err := runGetIAMIdentityMapping(ctx, d, cluster)
-	if err != nil {
-		return fmt.Errorf("can not get iamidentitymapping from eks cluster: %w", err)
-	}
-
-	if len(iams) == 0 {
-		log.Printf("no data from eksctl get iamidentitymapping")
-	} else {
-		if err := d.Set(KeyAWSAuthConfigMap, iams); err != nil {
-			return fmt.Errorf("set aws-auth-configmap from iamidentitymapping : %w", err)
-		}
-	}
-
+func createIAMIdentityMapping(ctx *sdk.Context, d api.ReadWrite, clusterName string) error {
 	if d.Get(KeyIAMIdentityMapping) != nil {
 		values := d.Get(KeyIAMIdentityMapping).(*schema.Set)
-		if err := runCreateIAMIdentityMapping(ctx, d, values, cluster); err != nil {
+		if err := runCreateIAMIdentityMapping(ctx, d, values, clusterName); err != nil {
 			return fmt.Errorf("creating create  iamidentitymapping command: %w", err)
 		}
 
@@ -168,10 +201,30 @@ func createIAMIdentityMapping(ctx *sdk.Context, d api.ReadWrite, cluster *Cluste
 		}
 	}
 
+	return setAWSAuthConfigMap(ctx, d, clusterName)
+}
+
+// setAWSAuthConfigMap stores all the mappings in the aws-auth configmap as aws_auth_configmap
+func setAWSAuthConfigMap(ctx *sdk.Context, d api.ReadWrite, clusterName string) error {
+	iams, err := runGetIAMIdentityMapping(ctx, d, clusterName)
+	if err != nil {
+		return fmt.Errorf("can not get iamidentitymapping from eks cluster: %w", err)
+	}
+
+	if len(iams) == 0 {
+		log.Printf("no data from eksctl get iamidentitymapping")
+
+		return nil
+	}
+
+	if err := d.Set(KeyAWSAuthConfigMap, iamIdentityMappingValues(iams)); err != nil {
+		return fmt.Errorf("set aws-auth-configmap from iamidentitymapping : %w", err)
+	}
+
 	return nil
 }
 
-func runCreateIAMIdentityMapping(ctx *sdk.Context, d api.Getter, s *schema.Set, cluster *Cluster) error {
+func runCreateIAMIdentityMapping(ctx *sdk.Context, d api.Getter, s *schema.Set, clusterName string) error {
 	values := s.List()
 	for _, v := range values {
 		ele := v.(map[string]interface{})
@@ -179,20 +232,9 @@ func runCreateIAMIdentityMapping(ctx *sdk.Context, d api.Getter, s *schema.Set,
 			"create",
 			"iamidentitymapping",
 			"--cluster",
-			cluster.Name,
-			"--arn",
-			ele["iamarn"].(string),
-			"--username",
-			ele["username"].(string),
+			clusterName,
 		}
-
-		g := ele["groups"]
-		g2 := []string{}
-		for _, v := range g.([]interface{}) {
-			g2 = append(g2, "--group")
-			g2 = append(g2, v.(string))
-		}
-		args = append(args, g2...)
+		args = append(args, iamIdentityMappingArgs(ele)...)
 
 		cmd, err := newEksctlCommandFromResourceWithRegionAndProfile(d, args...)
 
@@ -210,7 +252,7 @@ func runCreateIAMIdentityMapping(ctx *sdk.Context, d api.Getter, s *schema.Set,
 	return nil
 }
 
-func runDeleteIAMIdentityMapping(ctx *sdk.Context, d api.Getter, s *schema.Set, cluster *Cluster) error {
+func runDeleteIAMIdentityMapping(ctx *sdk.Context, d api.Getter, s *schema.Set, clusterName string) error {
 	values := s.List()
 	for _, v := range values {
 		ele := v.(map[string]interface{})
@@ -218,10 +260,9 @@ func runDeleteIAMIdentityMapping(ctx *sdk.Context, d api.Getter, s *schema.Set,
 			"delete",
 			"iamidentitymapping",
 			"--cluster",
-			cluster.Name,
-			"--arn",
-			ele["iamarn"].(string),
+			clusterName,
 		}
+		args = append(args, iamIdentityMappingDeleteArgs(ele)...)
 
 		cmd, err := newEksctlCommandFromResourceWithRegionAndProfile(d, args...)
 
@@ -237,5 +278,4 @@ func runDeleteIAMIdentityMapping(ctx *sdk.Context, d api.Getter, s *schema.Set,
 		log.Printf("-----------res: %v", res)
 	}
 	return nil
-
 }
@@ -2,12 +2,15 @@ package cluster
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 	"log"
+	"strings"
 )
 
-func (m *Manager) deleteCluster(d *schema.ResourceData) error {
+func (m *Manager) deleteCluster(runCtx context.Context, d *schema.ResourceData) error {
 	log.Printf("[DEBUG] deleting eksctl cluster with id %q", d.Id())
 
 	set, err := m.PrepareClusterSet(d)
@@ -17,6 +20,10 @@ func (m *Manager) deleteCluster(d *schema.ResourceData) error {
 
 	cluster := set.Cluster
 
+	if err := checkDeletionProtection(cluster, set.ClusterName); err != nil {
+		return err
+	}
+
 	args := []string{
 		"delete",
 		"cluster",
@@ -24,9 +31,23 @@ func (m *Manager) deleteCluster(d *schema.ResourceData) error {
 		"--wait",
 	}
 
-	ctx := mustNewContext(cluster)
+	ctx := mustNewContext(runCtx, cluster)
+
+	if err := checkOrphanedResources(ctx, set); err != nil {
+		return err
+	}
 
-	if err := doDeleteKubernetesResourcesBeforeDestroy(ctx, cluster, d.Id()); err != nil {
+	if err := doDeleteKubernetesResourcesBeforeDestroy(ctx, cluster, string(set.ClusterName)); err != nil {
+		return err
+	}
+
+	if err := cleanupAWSResourcesBeforeDestroy(ctx, set); err != nil {
+		return err
+	}
+
+	// Target groups are cleaned up before the cluster, so that a failure leaves the cluster to retry the destroy against,
+	// rather than orphaned target groups.
+	if err := deleteClusterTargetGroups(set); err != nil {
 		return err
 	}
 
@@ -45,8 +66,69 @@ func (m *Manager) deleteCluster(d *schema.ResourceData) error {
 		return err
 	}
 
-	// TODO Delete target groups
-	// TODO Delete ALB listener rule
-
 	return nil
 }
+
+// deleteClusterTargetGroups deletes the target groups created for alb_attachment
+func deleteClusterTargetGroups(set *ClusterSet) error {
+	arns, err := clusterTargetGroupARNs(set)
+	if err != nil {
+		return err
+	}
+
+	if len(arns) == 0 {
+		return nil
+	}
+
+	cluster := *set.Cluster
+	cluster.TargetGroupARNs = arns
+
+	s := *set
+	s.Cluster = &cluster
+
+	return deleteTargetGroups(&s)
+}
+
+// clusterTargetGroupARNs returns the target groups created for alb_attachment, including ones tagged for the cluster
+// but missing in the state.
+func clusterTargetGroupARNs(set *ClusterSet) ([]string, error) {
+	arns := append([]string{}, set.Cluster.TargetGroupARNs...)
+
+	// Without the ID, the tagged target groups can't be told apart from the ones of other resources sharing the name
+	if set.ClusterID == "" {
+		return arns, nil
+	}
+
+	tagged, err := getTargetGroupARNs(AWSSessionFromCluster(set.Cluster), set.Cluster.Name)
+	if err != nil {
+		return nil, fmt.Errorf("listing target groups of the cluster: %w", err)
+	}
+
+	for _, arn := range tagged {
+		if isTargetGroupOf(arn, set.ClusterID) && !containsString(arns, arn) {
+			arns = append(arns, arn)
+		}
+	}
+
+	return arns, nil
+}
+
+// isTargetGroupOf tells if the target group was created for the cluster with the ID.
+// The tag only holds the name shared by all the clusters of the resource, and possibly by other resources,
+// whereas the target group name ends with the ID, as named by planListenerChanges.
+func isTargetGroupOf(targetGroupARN, clusterID string) bool {
+	a, err := arn.Parse(targetGroupARN)
+	if err != nil {
+		log.Printf("[WARN] parsing target group ARN %s: %v", targetGroupARN, err)
+
+		return false
+	}
+
+	// The resource is formatted as `targetgroup/<name>/<id>`
+	parts := strings.Split(a.Resource, "/")
+	if len(parts) != 3 || parts[0] != "targetgroup" {
+		return false
+	}
+
+	return strings.HasSuffix(parts[1], "-"+clusterID)
+}
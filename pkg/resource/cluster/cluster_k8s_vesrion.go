@@ -4,82 +4,141 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
-	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
 	"log"
 	"strconv"
+	"strings"
+
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/api"
 )
 
 type LiveClusterInfo struct {
 	KubernetesVersion string
 	Revision          int
-}
-
-func getLiveClusterInfo(ctx *sdk.Context, d *schema.ResourceData) (*LiveClusterInfo, error) {
-	log.Printf("[DEBUG] getting eksctl cluster k8s version with id %q", d.Id())
-
-	m := &Manager{}
-
-	set, err := m.PrepareClusterSet(d)
-	if err != nil {
-		return nil, err
-	}
-
-	cluster := set.Cluster
+	NodeGroups        []LiveNodeGroup
 
-	clusterName := cluster.Name + "-" + d.Id()
-
-	args := []string{
-		"get",
-		"cluster",
-		"--name", clusterName,
-		"--region", cluster.Region,
-		"-o", "json",
-	}
+	// State is the rest of the cluster printed by `eksctl get cluster -o json`
+	State *ClusterState
+}
 
-	cmd, err := newEksctlCommandWithAWSProfile(cluster, args...)
-	if err != nil {
-		return nil, err
-	}
+// LiveNodeGroup is a nodegroup summary printed by `eksctl get nodegroup -o json`
+type LiveNodeGroup struct {
+	Name            string `json:"Name"`
+	Status          string `json:"Status"`
+	InstanceType    string `json:"InstanceType"`
+	MinSize         int    `json:"MinSize"`
+	MaxSize         int    `json:"MaxSize"`
+	DesiredCapacity int    `json:"DesiredCapacity"`
+}
 
-	cmd.Stdin = bytes.NewReader(set.ClusterConfig)
+// getLiveClusterInfo queries eksctl for the live state of the cluster and its nodegroups.
+// It returns nil without an error when the cluster no longer exists.
+func getLiveClusterInfo(ctx *sdk.Context, d api.Getter, clusterName string) (*LiveClusterInfo, error) {
+	log.Printf("[DEBUG] getting live state of eksctl cluster %q", clusterName)
 
-	res, err := ctx.Run(cmd)
+	out, notFound, err := runEksctlGet(ctx, d, "cluster", "--name", clusterName, "-o", "json")
 	if err != nil {
 		return nil, err
+	} else if notFound {
+		return nil, nil
 	}
 
 	type ClusterData struct {
+		Name    string            `json:"Name"`
 		Version string            `json:"Version"`
 		Tags    map[string]string `json:"Tags"`
 	}
 
 	var data []ClusterData
 
-	if err := json.Unmarshal([]byte(res.Output), &data); err != nil {
-		return nil, err
+	if err := json.Unmarshal([]byte(out), &data); err != nil {
+		return nil, fmt.Errorf("parsing get-cluster output as json: %w", err)
+	}
+
+	if len(data) == 0 {
+		return nil, nil
 	}
 
 	if len(data) != 1 {
 		return nil, fmt.Errorf("BUG: expected number of clusters found by running eksctl get cluster: %d\n\n%v", len(data), data)
 	}
 
+	var states []*ClusterState
+
+	if err := json.Unmarshal([]byte(out), &states); err != nil {
+		return nil, fmt.Errorf("parsing get-cluster output as json: %w", err)
+	}
+
 	var rev int
 
 	{
-		revisionTagKey := "tf-provider-eksctl/revision"
-		if r, ok := data[0].Tags[revisionTagKey]; ok {
+		if r, ok := data[0].Tags[TagKeyRevision]; ok {
 			v, err := strconv.Atoi(r)
 			if err != nil {
-				return nil, fmt.Errorf("converting tag value for %s to int: %w", revisionTagKey, err)
+				return nil, fmt.Errorf("converting tag value for %s to int: %w", TagKeyRevision, err)
 			}
 
 			rev = v
 		}
 	}
 
+	nodeGroups, err := getLiveNodeGroups(ctx, d, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
 	return &LiveClusterInfo{
 		KubernetesVersion: data[0].Version,
 		Revision:          rev,
+		NodeGroups:        nodeGroups,
+		State:             states[0],
 	}, nil
 }
+
+func getLiveNodeGroups(ctx *sdk.Context, d api.Getter, clusterName string) ([]LiveNodeGroup, error) {
+	out, notFound, err := runEksctlGet(ctx, d, "nodegroup", "--cluster", clusterName, "-o", "json")
+	if err != nil {
+		return nil, err
+	} else if notFound || strings.TrimSpace(out) == "" {
+		return nil, nil
+	}
+
+	var nodeGroups []LiveNodeGroup
+
+	if err := json.Unmarshal([]byte(out), &nodeGroups); err != nil {
+		return nil, fmt.Errorf("parsing get-nodegroup output as json: %w", err)
+	}
+
+	return nodeGroups, nil
+}
+
+// runEksctlGet runs `eksctl get <args>` and returns the output.
+// notFound is true when eksctl failed because the resource doesn't exist.
+func runEksctlGet(ctx *sdk.Context, d api.Getter, args ...string) (string, bool, error) {
+	cmd, err := newEksctlCommandFromResourceWithRegionAndProfile(d, append([]string{"get"}, args...)...)
+	if err != nil {
+		return "", false, fmt.Errorf("creating eksctl-get command: %w", err)
+	}
+
+	// sdk.Run captures only stdout, whereas eksctl prints the reason of the failure to stderr
+	var stderr bytes.Buffer
+
+	cmd.Stderr = &stderr
+
+	res, err := ctx.Run(cmd)
+	if err != nil {
+		msg := stderr.String()
+
+		for _, s := range []string{"ResourceNotFoundException", "No cluster found", "No nodegroups found"} {
+			if strings.Contains(msg, s) {
+				log.Printf("[DEBUG] eksctl get %s: not found: %s", strings.Join(args, " "), msg)
+
+				return "", true, nil
+			}
+		}
+
+		return "", false, fmt.Errorf("running eksctl get %s: %w\nSTDERR:\n%s", strings.Join(args, " "), err, msg)
+	}
+
+	return res.Output, false, nil
+}
@@ -1,11 +1,11 @@
 package cluster
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
-	"sort"
 	"strings"
 
 	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
@@ -17,13 +17,33 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
 
-func (m *Manager) readCluster(d api.ReadWrite) (*Cluster, error) {
+// readCluster refreshes the state from the live cluster.
+// It clears the resource ID when the cluster no longer exists, so that Terraform plans to recreate it.
+func (m *Manager) readCluster(runCtx context.Context, d *schema.ResourceData) (*Cluster, error) {
 	cluster, err := m.readClusterInternal(d)
+	if err != nil {
+		return nil, fmt.Errorf("reading cluster: %w", err)
+	}
+
+	ctx := mustNewContext(runCtx, cluster)
 
-	ctx := mustNewContext(cluster)
+	clusterName := string(m.getClusterName(cluster, d.Id()))
 
+	info, err := getLiveClusterInfo(ctx, d, clusterName)
 	if err != nil {
-		return nil, fmt.Errorf("reading cluster: %w", err)
+		return nil, fmt.Errorf("reading live cluster info: %w", err)
+	}
+
+	if info == nil {
+		log.Printf("[WARN] cluster %s no longer exists. Removing it from the state", clusterName)
+
+		d.SetId("")
+
+		return cluster, nil
+	}
+
+	if err := m.refreshFromLiveCluster(d, info); err != nil {
+		return nil, err
 	}
 
 	var path string
@@ -39,16 +59,26 @@ func (m *Manager) readCluster(d api.ReadWrite) (*Cluster, error) {
 	if path != "" {
 		if _, err := os.Stat(path); os.IsNotExist(err) {
 			log.Printf("running customdiff: no kubeconfig file found at kubeconfig_path=%s: recreating it", path)
-			if err := doWriteKubeconfig(ctx, d, string(m.getClusterName(cluster, d.Id())), cluster.Region); err != nil {
+			if err := doWriteKubeconfig(ctx, d, clusterName, cluster.Region); err != nil {
 				return nil, fmt.Errorf("writing missing kubeconfig on plan: %w", err)
 			}
 		}
 	}
 
-	if err := readIAMIdentityMapping(ctx, d, cluster); err != nil {
+	if info.State != nil {
+		if err := setClusterAttributes(d, cluster, info.State); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := readIAMIdentityMapping(ctx, d, cluster, clusterName); err != nil {
 		return nil, fmt.Errorf("reading aws-auth via eksctl get iamidentitymaping: %w", err)
 	}
 
+	if err := readAccessEntries(ctx, d, clusterName); err != nil {
+		return nil, fmt.Errorf("reading access entries: %w", err)
+	}
+
 	return cluster, nil
 }
 
@@ -93,45 +123,60 @@ func (m *Manager) planCluster(d *tfsdk.DiffReadWrite) error {
 	return nil
 }
 
-func readIAMIdentityMapping(ctx *sdk.Context, d api.ReadWrite, cluster *Cluster) error {
-	iamWithOIDCEnabled, err := cluster.IAMWithOIDCEnabled()
+// readIAMIdentityMapping stores the live aws-auth mappings as aws_auth_configmap, and the live mappings managed by the resource
+// as iam_identity_mapping, so that Terraform shows the drift and reverts it on the next apply.
+func readIAMIdentityMapping(ctx *sdk.Context, d api.ReadWrite, cluster *Cluster, clusterName string) error {
+	iams, err := runGetIAMIdentityMapping(ctx, d, clusterName)
 	if err != nil {
-		return fmt.Errorf("reading iam.withOIDC setting from cluster.yaml: %w", err)
-	} else if !iamWithOIDCEnabled {
-		return nil
+		return fmt.Errorf("can not get iamidentitymapping from eks cluster: %w", err)
 	}
 
-	iams, err := runGetIAMIdentityMapping(ctx, d, cluster)
-	if err != nil {
-		return fmt.Errorf("can not get iamidentitymapping from eks cluster: %w", err)
+	var configured []interface{}
+
+	if s, ok := d.Get(KeyIAMIdentityMapping).(*schema.Set); ok {
+		configured = s.List()
 	}
 
+	observed := observedIAMIdentityMappings(iams, configured, cluster.ManageAWSAuth == ManageAWSAuthAuthoritative)
+
 	current := make([]map[string]interface{}, 0)
+	for _, v := range configured {
+		current = append(current, iamIdentityMappingValue(v.(map[string]interface{})))
+	}
 
-	for _, v := range d.Get(KeyAWSAuthConfigMap).(*schema.Set).List() {
-		current = append(current, v.(map[string]interface{}))
+	remote := make([]map[string]interface{}, 0)
+	for _, v := range observed {
+		remote = append(remote, v.(map[string]interface{}))
 	}
 
 	// sort for diff
-	sort.Slice(current, func(i, j int) bool { return current[i]["iamarn"].(string) < current[j]["iamarn"].(string) })
-	sort.Slice(iams, func(i, j int) bool { return iams[i]["iamarn"].(string) < iams[j]["iamarn"].(string) })
+	sortIAMIdentityMappings(current)
+	sortIAMIdentityMappings(remote)
 
-	if diff := cmp.Diff(iams, current); diff != "" {
-		log.Printf("aws-auth diff remote (-remote +current):\n%s", diff)
+	if diff := cmp.Diff(remote, current); diff != "" {
+		log.Printf("[DEBUG] aws-auth diff remote (-remote +current):\n%s", diff)
 	} else {
-		log.Printf("have diff between remote source and param")
+		log.Printf("[DEBUG] no diff between aws-auth and %s", KeyIAMIdentityMapping)
+	}
+
+	if err := d.Set(KeyIAMIdentityMapping, observed); err != nil {
+		return fmt.Errorf("setting %s: %w", KeyIAMIdentityMapping, err)
+	}
+
+	if err := d.Set(KeyAWSAuthConfigMap, iamIdentityMappingValues(iams)); err != nil {
+		return fmt.Errorf("setting %s: %w", KeyAWSAuthConfigMap, err)
 	}
 
 	return nil
 }
 
-func runGetIAMIdentityMapping(ctx *sdk.Context, d api.Getter, cluster *Cluster) ([]map[string]interface{}, error) {
+func runGetIAMIdentityMapping(ctx *sdk.Context, d api.Getter, clusterName string) ([]map[string]interface{}, error) {
 	//get iamidentitymapping
 	args := []string{
 		"get",
 		"iamidentitymapping",
 		"--cluster",
-		cluster.Name,
+		clusterName,
 		"-o",
 		"json",
 	}
@@ -158,34 +203,87 @@ func runGetIAMIdentityMapping(ctx *sdk.Context, d api.Getter, cluster *Cluster)
 	return iams, nil
 }
 
-func loadOIDCProviderURLAndARN(d api.ReadWrite, cluster *Cluster) error {
-	iamWithOIDCEnabled, err := cluster.IAMWithOIDCEnabled()
+// loadClusterAttributes stores the attributes of the live cluster, like endpoint and certificate_authority_data,
+// along with the OIDC provider URL and ARN when iam.withOIDC is enabled
+func loadClusterAttributes(runCtx context.Context, d api.ReadWrite, set *ClusterSet) error {
+	cluster := set.Cluster
+
+	state, err := runGetCluster(runCtx, d, cluster, string(set.ClusterName))
 	if err != nil {
-		return fmt.Errorf("reading iam.withOIDC setting from cluster.yaml: %w", err)
-	} else if !iamWithOIDCEnabled {
-		return nil
+		return fmt.Errorf("getting cluster %s: %w", set.ClusterName, err)
 	}
 
-	state, err := runGetCluster(d, cluster)
+	return setClusterAttributes(d, cluster, state)
+}
+
+func setClusterAttributes(d api.ReadWrite, cluster *Cluster, state *ClusterState) error {
+	iamWithOIDCEnabled, err := cluster.IAMWithOIDCEnabled()
 	if err != nil {
-		return fmt.Errorf("can not get iamidentitymapping from eks cluster: %w", err)
+		return fmt.Errorf("reading iam.withOIDC setting from cluster.yaml: %w", err)
 	}
 
-	d.Set(KeyOIDCProviderURL, state.Identity.Oidc.Issuer)
-	d.Set(KeyOIDCProviderARN, state.GetOIDCProviderARN())
-	d.Set(KeySecurityGroupIDs, state.GetSecurityGroupIDs())
+	for k, v := range clusterAttributes(state, iamWithOIDCEnabled) {
+		if err := d.Set(k, v); err != nil {
+			return fmt.Errorf("setting %s: %w", k, err)
+		}
+	}
 
 	return nil
 }
 
+// clusterAttributeKeys are the attributes of the live cluster, which are unknown until the cluster is created
+var clusterAttributeKeys = []string{
+	KeyEndpoint, KeyCertificateAuthorityData, KeyARN, KeyPlatformVersion, KeyStatus, KeyClusterSecurityGroupID, KeyServiceIPv4CIDR,
+}
+
+// clusterAttributes returns the computed attributes read from the live cluster
+func clusterAttributes(state *ClusterState, iamWithOIDCEnabled bool) map[string]interface{} {
+	attrs := map[string]interface{}{
+		KeyEndpoint:                 state.Endpoint,
+		KeyCertificateAuthorityData: state.CertificateAuthority.Data,
+		KeyARN:                      state.Arn,
+		KeyPlatformVersion:          state.PlatformVersion,
+		KeyStatus:                   state.Status,
+		KeyClusterSecurityGroupID:   state.ResourcesVpcConfig.ClusterSecurityGroupId,
+		KeyServiceIPv4CIDR:          state.KubernetesNetworkConfig.ServiceIpv4Cidr,
+		KeySecurityGroupIDs:         state.GetSecurityGroupIDs(),
+	}
+
+	if iamWithOIDCEnabled {
+		attrs[KeyOIDCProviderURL] = state.Identity.Oidc.Issuer
+		attrs[KeyOIDCProviderARN] = state.GetOIDCProviderARN()
+	}
+
+	return attrs
+}
+
 type ClusterState struct {
-	Name               string             `json:"Name"`
-	Identity           Identity           `json:"Identity"`
-	RoleArn            string             `json:"RoleArn"`
-	ResourcesVpcConfig ResourcesVpcConfig `json:"ResourcesVpcConfig"`
+	Name                    string                  `json:"Name"`
+	Version                 string                  `json:"Version"`
+	Tags                    map[string]string       `json:"Tags"`
+	Arn                     string                  `json:"Arn"`
+	Endpoint                string                  `json:"Endpoint"`
+	CertificateAuthority    CertificateAuthority    `json:"CertificateAuthority"`
+	PlatformVersion         string                  `json:"PlatformVersion"`
+	Status                  string                  `json:"Status"`
+	Identity                Identity                `json:"Identity"`
+	RoleArn                 string                  `json:"RoleArn"`
+	ResourcesVpcConfig      ResourcesVpcConfig      `json:"ResourcesVpcConfig"`
+	KubernetesNetworkConfig KubernetesNetworkConfig `json:"KubernetesNetworkConfig"`
+}
+
+// CertificateAuthority is the base64-encoded certificate of the cluster's Kubernetes API server
+type CertificateAuthority struct {
+	Data string `json:"Data"`
+}
+
+type KubernetesNetworkConfig struct {
+	ServiceIpv4Cidr string `json:"ServiceIpv4Cidr"`
 }
 
 type ResourcesVpcConfig struct {
+	VpcId                  string   `json:"VpcId"`
+	SubnetIds              []string `json:"SubnetIds"`
 	ClusterSecurityGroupId string   `json:"ClusterSecurityGroupId"`
 	SecurityGroupIds       []string `json:"SecurityGroupIds"`
 }
@@ -224,12 +322,12 @@ type Oidc struct {
 	Issuer string `json:"Issuer"`
 }
 
-func runGetCluster(d api.Getter, cluster *Cluster) (*ClusterState, error) {
+func runGetCluster(runCtx context.Context, d api.Getter, cluster *Cluster, clusterName string) (*ClusterState, error) {
 	args := []string{
 		"get",
 		"cluster",
 		"--name",
-		cluster.Name,
+		clusterName,
 		"-o",
 		"json",
 	}
@@ -239,7 +337,7 @@ func runGetCluster(d api.Getter, cluster *Cluster) (*ClusterState, error) {
 		return nil, fmt.Errorf("creating get iamidentitymapping command: %w", err)
 	}
 
-	ctx := mustNewContext(cluster)
+	ctx := mustNewContext(runCtx, cluster)
 
 	run, err := ctx.Run(cmd)
 	if err != nil {
@@ -256,14 +354,14 @@ func runGetCluster(d api.Getter, cluster *Cluster) (*ClusterState, error) {
 	var state *ClusterState
 
 	for i := range states {
-		if states[i].Name == cluster.Name {
+		if states[i].Name == clusterName {
 			state = states[i]
 			break
 		}
 	}
 
 	if state == nil {
-		return nil, xerrors.Errorf("no cluster found: %s", cluster.Name)
+		return nil, xerrors.Errorf("no cluster found: %s", clusterName)
 	}
 
 	return state, nil
@@ -0,0 +1,55 @@
+package cluster
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const getClusterOutput = `[
+  {
+    "Name": "mycluster",
+    "Arn": "arn:aws:eks:us-east-2:123456789012:cluster/mycluster",
+    "Endpoint": "https://0123456789ABCDEF.gr7.us-east-2.eks.amazonaws.com",
+    "CertificateAuthority": {"Data": "LS0tLS1CRUdJTi=="},
+    "PlatformVersion": "eks.5",
+    "Status": "ACTIVE",
+    "Version": "1.21",
+    "RoleArn": "arn:aws:iam::123456789012:role/eksctl-mycluster-cluster-ServiceRole-1",
+    "Identity": {"Oidc": {"Issuer": "https://oidc.eks.us-east-2.amazonaws.com/id/0123456789ABCDEF"}},
+    "ResourcesVpcConfig": {"VpcId": "vpc-1", "SubnetIds": ["subnet-1", "subnet-2"], "ClusterSecurityGroupId": "sg-1", "SecurityGroupIds": ["sg-2"]},
+    "Tags": {"team": "a"},
+    "KubernetesNetworkConfig": {"ServiceIpv4Cidr": "10.100.0.0/16"}
+  }
+]`
+
+func TestClusterAttributes(t *testing.T) {
+	var states []*ClusterState
+
+	if err := json.Unmarshal([]byte(getClusterOutput), &states); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]interface{}{
+		KeyEndpoint:                 "https://0123456789ABCDEF.gr7.us-east-2.eks.amazonaws.com",
+		KeyCertificateAuthorityData: "LS0tLS1CRUdJTi==",
+		KeyARN:                      "arn:aws:eks:us-east-2:123456789012:cluster/mycluster",
+		KeyPlatformVersion:          "eks.5",
+		KeyStatus:                   "ACTIVE",
+		KeyClusterSecurityGroupID:   "sg-1",
+		KeyServiceIPv4CIDR:          "10.100.0.0/16",
+		KeySecurityGroupIDs:         []string{"sg-2"},
+	}
+
+	if d := cmp.Diff(want, clusterAttributes(states[0], false)); d != "" {
+		t.Errorf("unexpected attributes: want (-), got (+)\n%s", d)
+	}
+
+	want[KeyOIDCProviderURL] = "https://oidc.eks.us-east-2.amazonaws.com/id/0123456789ABCDEF"
+	want[KeyOIDCProviderARN] = "arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-2.amazonaws.com/id/0123456789ABCDEF"
+
+	if d := cmp.Diff(want, clusterAttributes(states[0], true)); d != "" {
+		t.Errorf("unexpected attributes with OIDC: want (-), got (+)\n%s", d)
+	}
+}
@@ -2,14 +2,26 @@ package cluster
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"log"
+	"os/exec"
 	"strings"
+	"sync"
 
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+	"gopkg.in/yaml.v3"
 )
 
-func (m *Manager) updateCluster(d *schema.ResourceData) (*ClusterSet, error) {
+// eksctlCreateExtraArgs are the flags added to `eksctl create <kind> -f -` run by the operation, one argument per element
+var eksctlCreateExtraArgs = map[string][]string{
+	OpCreateNodeGroup:         {"--timeout", "90m"},
+	OpCreateIAMServiceAccount: {"--approve"},
+}
+
+func (m *Manager) updateCluster(runCtx context.Context, d *schema.ResourceData) (*ClusterSet, error) {
 	log.Printf("[DEBUG] updating eksctl cluster with id %q", d.Id())
 
 	set, err := m.PrepareClusterSet(d)
@@ -19,19 +31,33 @@ func (m *Manager) updateCluster(d *schema.ResourceData) (*ClusterSet, error) {
 
 	cluster, clusterConfig := set.Cluster, set.ClusterConfig
 
-	ctx := mustNewContext(cluster)
+	ctx := mustNewContext(runCtx, cluster)
+
+	// Operations may run concurrently, whereas schema.ResourceData isn't safe for concurrent use
+	rd := &lockedResourceData{d: d}
 
-	updateBy := func(args []string, harmlessErrors []string) func() error {
+	// version is the Kubernetes version of the upgrade step to run the command for, if any
+	updateBy := func(version string, args []string, harmlessErrors []string) func() error {
 		return func() error {
 			eksctlCmdToLog := fmt.Sprintf("eksctl-%s", strings.Join(args, "-"))
 
+			config := clusterConfig
+			if version != "" {
+				c, err := clusterConfigWithVersion(clusterConfig, version)
+				if err != nil {
+					return fmt.Errorf("setting version %s to cluster.yaml: %w", version, err)
+				}
+
+				config = c
+			}
+
 			args = append(args, "-f", "-")
 			cmd, err := newEksctlCommandWithAWSProfile(cluster, args...)
 			if err != nil {
 				return fmt.Errorf("creating %s command: %w", eksctlCmdToLog, err)
 			}
 
-			cmd.Stdin = bytes.NewReader(clusterConfig)
+			cmd.Stdin = bytes.NewReader(config)
 
 			if r, err := ctx.Run(cmd); err != nil {
 				lines := strings.Split(err.Error(), "\n")
@@ -54,14 +80,14 @@ func (m *Manager) updateCluster(d *schema.ResourceData) (*ClusterSet, error) {
 					output = r.Output
 				}
 
-				return fmt.Errorf("%v\n\nCLUSTER CONFIG:\n%s\n\nOUTPUT:\n%s", err, string(clusterConfig), output)
+				return fmt.Errorf("%v\n\nCLUSTER CONFIG:\n%s\n\nOUTPUT:\n%s", err, string(config), output)
 			}
 
 			return nil
 		}
 	}
 
-	createNew := func(kind string, extraArgs []string, harmlessErrors []string) func() error {
+	createNew := func(kind string, config []byte, extraArgs []string, harmlessErrors []string) func() error {
 		return func() error {
 			args := []string{"create", kind, "-f", "-"}
 			args = append(args, extraArgs...)
@@ -70,9 +96,9 @@ func (m *Manager) updateCluster(d *schema.ResourceData) (*ClusterSet, error) {
 				return fmt.Errorf("creating eksctl-create command: %w", err)
 			}
 
-			cmd.Stdin = bytes.NewReader(clusterConfig)
+			cmd.Stdin = bytes.NewReader(config)
 
-			if err := ctx.Update(cmd, d); err != nil {
+			if err := rd.Update(ctx, cmd); err != nil {
 				lines := strings.Split(err.Error(), "\n")
 				lastLine := lines[len(lines)-1]
 				if lastLine == "" && len(lines) > 1 {
@@ -87,7 +113,7 @@ func (m *Manager) updateCluster(d *schema.ResourceData) (*ClusterSet, error) {
 						return nil
 					}
 				}
-				return fmt.Errorf("%v\n\nCLUSTER CONFIG:\n%s", err, string(clusterConfig))
+				return fmt.Errorf("%v\n\nCLUSTER CONFIG:\n%s", err, string(config))
 			}
 
 			return nil
@@ -105,7 +131,7 @@ func (m *Manager) updateCluster(d *schema.ResourceData) (*ClusterSet, error) {
 
 			cmd.Stdin = bytes.NewReader(clusterConfig)
 
-			if err := ctx.Update(cmd, d); err != nil {
+			if err := rd.Update(ctx, cmd); err != nil {
 				lines := strings.Split(err.Error(), "\n")
 				lastLine := lines[len(lines)-1]
 				if lastLine == "" && len(lines) > 1 {
@@ -136,7 +162,7 @@ func (m *Manager) updateCluster(d *schema.ResourceData) (*ClusterSet, error) {
 			}
 			cmd.Stdin = bytes.NewReader(clusterConfig)
 
-			if err := ctx.Update(cmd, d); err != nil {
+			if err := rd.Update(ctx, cmd); err != nil {
 				return fmt.Errorf("%v\n\nCLUSTER CONFIG:\n%s", err, string(clusterConfig))
 			}
 
@@ -144,9 +170,30 @@ func (m *Manager) updateCluster(d *schema.ResourceData) (*ClusterSet, error) {
 		}
 	}
 
-	applyKubernetesManifests := func(id string) func() error {
+	setPublicAccessCIDRs := func() func() error {
+		return func() error {
+			config, err := clusterConfigWithPublicAccessCIDRs(clusterConfig)
+			if err != nil {
+				return err
+			}
+
+			cmd, err := newEksctlCommandWithAWSProfile(cluster, "utils", "set-public-access-cidrs", "-f", "-", "--approve")
+			if err != nil {
+				return fmt.Errorf("creating eksctl-utils-set-public-access-cidrs command: %w", err)
+			}
+			cmd.Stdin = bytes.NewReader(config)
+
+			if err := rd.Update(ctx, cmd); err != nil {
+				return fmt.Errorf("%v\n\nCLUSTER CONFIG:\n%s", err, string(config))
+			}
+
+			return nil
+		}
+	}
+
+	applyKubernetesManifests := func() func() error {
 		return func() error {
-			return doApplyKubernetesManifests(ctx, cluster, id)
+			return doApplyKubernetesManifests(ctx, cluster, string(set.ClusterName))
 		}
 	}
 
@@ -164,7 +211,7 @@ func (m *Manager) updateCluster(d *schema.ResourceData) (*ClusterSet, error) {
 			}
 			cmd.Stdin = bytes.NewReader(clusterConfig)
 
-			if err := ctx.Update(cmd, d); err != nil {
+			if err := rd.Update(ctx, cmd); err != nil {
 				return fmt.Errorf("%v\n\nCLUSTER CONFIG:\n%s", err, string(clusterConfig))
 			}
 
@@ -172,15 +219,17 @@ func (m *Manager) updateCluster(d *schema.ResourceData) (*ClusterSet, error) {
 		}
 	}
 
-	checkPodsReadiness := func(id string) func() error {
+	checkPodsReadiness := func() func() error {
 		return func() error {
-			return doCheckPodsReadiness(ctx, cluster, id)
+			return doCheckPodsReadiness(ctx, cluster, string(set.ClusterName))
 		}
 	}
 
 	writeKubeconfig := func() func() error {
 		return func() error {
-			return doWriteKubeconfig(ctx, d, string(set.ClusterName), cluster.Region)
+			return rd.WithLock(func() error {
+				return doWriteKubeconfig(ctx, d, string(set.ClusterName), cluster.Region)
+			})
 		}
 	}
 
@@ -190,107 +239,444 @@ func (m *Manager) updateCluster(d *schema.ResourceData) (*ClusterSet, error) {
 		}
 	}
 
-	id := d.Id()
-
 	clusterName := string(set.ClusterName)
 	harmlessFargateProfileCreationErrors := []string{
 		fmt.Sprintf(`Error: no output "FargatePodExecutionRoleARN" in stack "eksctl-%s-cluster"`, clusterName),
 		fmt.Sprintf(`Error: couldn't refresh role arn: no output "FargatePodExecutionRoleARN" in stack "eksctl-%s-cluster"`, clusterName),
 	}
 
-	drainNodegroup := func() func() error {
-
+	drainNodegroup := func(names []string) func() error {
 		return func() error {
-
 			args := []string{
 				"drain",
 				"nodegroup",
 				"--cluster=" + clusterName,
 				"-n",
 			}
-			nodegroups := d.Get(KeyDrainNodeGroups).(map[string]interface{})
+			nodegroups := rd.Get(KeyDrainNodeGroups).(map[string]interface{})
+
+			// drain_node_groups is keyed by the names in the configuration, which may differ from the actual names
+			// when immutable_node_groups is enabled
+			var c EksctlClusterConfig
+
+			if err := yaml.Unmarshal(clusterConfig, &c); err != nil {
+				return fmt.Errorf("parsing cluster.yaml: %w", err)
+			}
+
+			for _, k := range names {
+				v := nodegroups[k]
 
-			for k, v := range nodegroups {
 				log.Printf("DRAIN    %v %v ", k, v)
-				opt := append(args, string(k))
+				opt := append(args, resolveNodeGroupName(&c, k))
 
 				if v == false {
 					opt = append(opt, "--undo")
 				}
-				cmd, err := newEksctlCommandFromResourceWithRegionAndProfile(d, opt...)
+				cmd, err := newEksctlCommandFromResourceWithRegionAndProfile(rd, opt...)
 
 				if err != nil {
 					return fmt.Errorf("creating eksctl drain command: %w", err)
 				}
 
-				if err := ctx.Update(cmd, d); err != nil {
+				if err := rd.Update(ctx, cmd); err != nil {
 					return fmt.Errorf("Drain Error: %v", err)
 				}
 			}
 
 			return nil
 		}
+	}
+
+	scaleNodegroup := func(names []string) func() error {
+		return func() error {
+			var c EksctlClusterConfig
+
+			if err := yaml.Unmarshal(clusterConfig, &c); err != nil {
+				return fmt.Errorf("parsing cluster.yaml: %w", err)
+			}
+
+			capacities := nodeGroupCapacities(&c)
+
+			flags := map[string]string{
+				"desiredCapacity": "--nodes",
+				"minSize":         "--nodes-min",
+				"maxSize":         "--nodes-max",
+			}
+
+			for _, name := range names {
+				args := []string{"scale", "nodegroup", "--cluster", clusterName, "--name", name}
+
+				for _, k := range nodeGroupCapacityKeys {
+					if v, ok := capacities[name][k]; ok {
+						args = append(args, flags[k], v)
+					}
+				}
+
+				cmd, err := newEksctlCommandFromResourceWithRegionAndProfile(rd, args...)
+				if err != nil {
+					return fmt.Errorf("creating eksctl-scale-nodegroup command: %w", err)
+				}
+
+				if err := rd.Update(ctx, cmd); err != nil {
+					return fmt.Errorf("scaling nodegroup %s: %w", name, err)
+				}
+			}
+
+			return nil
+		}
+	}
+
+	createFargateProfiles := func(names []string) func() error {
+		return func() error {
+			// Only the profiles to be created are passed, as eksctl fails on existing ones
+			config, err := clusterConfigWithItems(clusterConfig, "fargateProfiles", names)
+			if err != nil {
+				return err
+			}
 
+			return createNew("fargateprofile", config, nil, harmlessFargateProfileCreationErrors)()
+		}
 	}
 
-	whenIAMWithOIDCEnabled := func(f func() error) func() error {
+	deleteFargateProfiles := func(names []string) func() error {
 		return func() error {
-			iamWithOIDCEnabled, err := cluster.IAMWithOIDCEnabled()
+			existing, err := listFargateProfiles(ctx, clusterName)
 			if err != nil {
-				return fmt.Errorf("reading iam.withOIDC setting from cluster.yaml: %w", err)
-			} else if !iamWithOIDCEnabled {
-				return nil
+				return err
+			}
+
+			for _, name := range names {
+				if !existing[name] {
+					log.Printf("[DEBUG] skipping deletion of fargate profile %s, as it doesn't exist", name)
+
+					continue
+				}
+
+				cmd, err := newEksctlCommandFromResourceWithRegionAndProfile(rd, "delete", "fargateprofile", "--cluster", clusterName, "--name", name, "--wait")
+				if err != nil {
+					return fmt.Errorf("creating eksctl-delete-fargateprofile command: %w", err)
+				}
+
+				if err := rd.Update(ctx, cmd); err != nil {
+					return fmt.Errorf("deleting fargate profile %s: %w", name, err)
+				}
+			}
+
+			return nil
+		}
+	}
+
+	createAddons := func(names []string) func() error {
+		return func() error {
+			config, err := clusterConfigWithItems(clusterConfig, "addons", names)
+			if err != nil {
+				return err
+			}
+
+			return createNew("addon", config, nil, nil)()
+		}
+	}
+
+	// version is the Kubernetes version of the upgrade step to update the add-ons for, if any
+	updateAddons := func(names []string, version string) func() error {
+		return func() error {
+			config, err := addonUpdateConfig(clusterConfig, names, version)
+			if err != nil {
+				return err
+			}
+
+			cmd, err := newEksctlCommandWithAWSProfile(cluster, "update", "addon", "-f", "-")
+			if err != nil {
+				return fmt.Errorf("creating eksctl-update-addon command: %w", err)
+			}
+
+			cmd.Stdin = bytes.NewReader(config)
+
+			if err := rd.Update(ctx, cmd); err != nil {
+				return fmt.Errorf("%v\n\nCLUSTER CONFIG:\n%s", err, string(config))
 			}
 
-			return f()
+			return nil
+		}
+	}
+
+	deleteAddons := func(names []string) func() error {
+		return func() error {
+			existing, err := listAddons(ctx, clusterName)
+			if err != nil {
+				return err
+			}
+
+			for _, name := range names {
+				if !existing[name] {
+					log.Printf("[DEBUG] skipping deletion of add-on %s, as it doesn't exist", name)
+
+					continue
+				}
+
+				cmd, err := newEksctlCommandFromResourceWithRegionAndProfile(rd, "delete", "addon", "--cluster", clusterName, "--name", name)
+				if err != nil {
+					return fmt.Errorf("creating eksctl-delete-addon command: %w", err)
+				}
+
+				if err := rd.Update(ctx, cmd); err != nil {
+					return fmt.Errorf("deleting add-on %s: %w", name, err)
+				}
+			}
+
+			return nil
+		}
+	}
+
+	upgradeNodegroup := func(names []string, version string) func() error {
+		return func() error {
+			for _, name := range names {
+				cmd, err := newEksctlCommandFromResourceWithRegionAndProfile(rd, "upgrade", "nodegroup", "--cluster", clusterName, "--name", name, "--kubernetes-version", version)
+				if err != nil {
+					return fmt.Errorf("creating eksctl-upgrade-nodegroup command: %w", err)
+				}
+
+				if err := rd.Update(ctx, cmd); err != nil {
+					return fmt.Errorf("upgrading nodegroup %s: %w", name, err)
+				}
+			}
+
+			return nil
 		}
 	}
 
 	updateIAMIdentityMapping := func() func() error {
 		return func() error {
-			d.HasChange(KeyIAMIdentityMapping)
-			a, b := d.GetChange(KeyIAMIdentityMapping)
+			a, b := rd.GetChange(KeyIAMIdentityMapping)
+
+			// Changed mappings are deleted before being created again, as eksctl deletes the first mapping of the ARN
+			if err := runDeleteIAMIdentityMapping(ctx, rd, a.(*schema.Set).Difference(b.(*schema.Set)), clusterName); err != nil {
+				return fmt.Errorf("DeleteIAMIdentityMapping Error: %v", err)
+			}
 
-			if err := runCreateIAMIdentityMapping(ctx, d, b.(*schema.Set).Difference(a.(*schema.Set)), cluster); err != nil {
+			if err := runCreateIAMIdentityMapping(ctx, rd, b.(*schema.Set).Difference(a.(*schema.Set)), clusterName); err != nil {
 				return fmt.Errorf("CreateIAMIdentityMapping Error: %v", err)
 			}
 
-			if err := runDeleteIAMIdentityMapping(ctx, d, a.(*schema.Set).Difference(b.(*schema.Set)), cluster); err != nil {
-				return fmt.Errorf("DeleteIAMIdentityMapping Error: %v", err)
+			iams, err := runGetIAMIdentityMapping(ctx, rd, clusterName)
+			if err != nil {
+				return fmt.Errorf("can not get iamidentitymapping from eks cluster: %w", err)
 			}
 
-			return nil
+			return rd.WithLock(func() error {
+				return d.Set(KeyAWSAuthConfigMap, iamIdentityMappingValues(iams))
+			})
+		}
+	}
+
+	updateAccessEntry := func() func() error {
+		return func() error {
+			o, n := rd.GetChange(KeyAccessEntry)
+
+			deleted, created := accessEntryChanges(accessEntriesFromSet(o), accessEntriesFromSet(n))
+
+			if err := runDeleteAccessEntries(ctx, rd, clusterName, deleted); err != nil {
+				return err
+			}
+
+			return runCreateAccessEntries(ctx, rd, clusterName, created)
 		}
 	}
 
-	tasks := []func() error{
-		// See https://eksctl.io/usage/cluster-upgrade/ for the cluster upgrade process
-		updateBy([]string{"upgrade", "cluster", "--approve"}, nil),
-		updateBy([]string{"utils", "update-kube-proxy", "--approve"}, nil),
-		updateBy([]string{"utils", "update-aws-node", "--approve"}, nil),
-		updateBy([]string{"utils", "update-coredns", "--approve"}, nil),
-		createNew("nodegroup", []string{"--timeout 90m"}, nil),
-		whenIAMWithOIDCEnabled(associateIAMOIDCProvider()),
-		whenIAMWithOIDCEnabled(createNew("iamserviceaccount", []string{"--approve"}, nil)),
-		createNew("fargateprofile", nil, harmlessFargateProfileCreationErrors),
-		enableRepo(),
-		drainNodegroup(),
-		updateIAMIdentityMapping(),
-		deleteMissing("nodegroup", []string{"--drain", "--approve"}, nil),
-		whenIAMWithOIDCEnabled(deleteMissing("iamserviceaccount", []string{"--approve"}, nil)),
-		// eksctl delete fargate profile doens't has --only-missing command
-		//deleteMissing("fargateprofile", nil, []string{"Error: invalid Fargate profile: empty name"}),
-		applyKubernetesManifests(id),
-		attachNodeGroupsToTargetGroups(),
-		checkPodsReadiness(id),
-		writeKubeconfig(),
+	updateTags := func() func() error {
+		return func() error {
+			o, n := rd.GetChange(KeyTags)
+
+			return doUpdateTags(ctx, clusterName, stringMap(o), stringMap(n))
+		}
 	}
 
-	for _, t := range tasks {
-		if err := t(); err != nil {
-			return nil, err
+	updateSubnetTags := func(changed []string) func() error {
+		return func() error {
+			var c EksctlClusterConfig
+
+			if err := yaml.Unmarshal(clusterConfig, &c); err != nil {
+				return fmt.Errorf("parsing cluster.yaml: %w", err)
+			}
+
+			return updateSubnetTags(ec2.New(ctx.Session()), &c, set.ClusterName, changed)
 		}
 	}
 
+	ops, _, err := m.planClusterUpdate(d)
+	if err != nil {
+		return nil, fmt.Errorf("planning cluster update: %w", err)
+	}
+
+	checkpoint := readUpdateCheckpoint(d, clusterConfigHash(clusterConfig))
+
+	var tasks []dagTask
+
+	for _, op := range ops {
+		if checkpoint.IsCompleted(op) {
+			log.Printf("[DEBUG] skipping %s on cluster %s, as it has been completed by the previous update", op, clusterName)
+
+			continue
+		}
+
+		var task func() error
+
+		switch op.Name {
+		case OpUpgradeCluster:
+			task = updateBy(op.Version, []string{"upgrade", "cluster", "--approve"}, nil)
+		case OpUpdateKubeProxy:
+			task = updateBy(op.Version, []string{"utils", "update-kube-proxy", "--approve"}, nil)
+		case OpUpdateAWSNode:
+			task = updateBy(op.Version, []string{"utils", "update-aws-node", "--approve"}, nil)
+		case OpUpdateCoreDNS:
+			task = updateBy(op.Version, []string{"utils", "update-coredns", "--approve"}, nil)
+		case OpUpdateClusterLogging:
+			task = updateBy("", []string{"utils", "update-cluster-logging", "--approve"}, nil)
+		case OpUpdateClusterEndpoints:
+			task = updateBy("", []string{"utils", "update-cluster-endpoints", "--approve"}, nil)
+		case OpSetPublicAccessCIDRs:
+			task = setPublicAccessCIDRs()
+		case OpEnableSecretsEncryption:
+			task = updateBy("", []string{"utils", "enable-secrets-encryption", "--approve"}, nil)
+		case OpCreateAddon:
+			task = createAddons(op.Targets)
+		case OpUpdateAddon:
+			task = updateAddons(op.Targets, op.Version)
+		case OpDeleteAddon:
+			task = deleteAddons(op.Targets)
+		case OpUpgradeNodeGroup:
+			task = upgradeNodegroup(op.Targets, op.Version)
+		case OpCreateNodeGroup:
+			task = createNew("nodegroup", clusterConfig, eksctlCreateExtraArgs[op.Name], nil)
+		case OpScaleNodeGroup:
+			task = scaleNodegroup(op.Targets)
+		case OpAssociateIAMOIDCProvider:
+			task = associateIAMOIDCProvider()
+		case OpCreateIAMServiceAccount:
+			task = createNew("iamserviceaccount", clusterConfig, eksctlCreateExtraArgs[op.Name], nil)
+		case OpDeleteFargateProfile:
+			task = deleteFargateProfiles(op.Targets)
+		case OpCreateFargateProfile:
+			task = createFargateProfiles(op.Targets)
+		case OpEnableRepo:
+			task = enableRepo()
+		case OpDrainNodeGroup:
+			task = drainNodegroup(op.Targets)
+		case OpUpdateAuthenticationMode:
+			task = updateBy("", []string{"utils", "update-authentication-mode"}, nil)
+		case OpUpdateAccessEntry:
+			task = updateAccessEntry()
+		case OpUpdateIAMIdentityMapping:
+			task = updateIAMIdentityMapping()
+		case OpDeleteNodeGroup:
+			task = deleteMissing("nodegroup", []string{"--drain", "--approve"}, nil)
+		case OpDeleteIAMServiceAccount:
+			task = deleteMissing("iamserviceaccount", []string{"--approve"}, nil)
+		case OpUpdateTags:
+			task = updateTags()
+		case OpUpdateSubnetTags:
+			task = updateSubnetTags(op.Targets)
+		case OpApplyKubernetesManifests:
+			task = applyKubernetesManifests()
+		case OpAttachNodeGroupsToTargetGroups:
+			task = attachNodeGroupsToTargetGroups()
+		case OpCheckPodsReadiness:
+			task = checkPodsReadiness()
+		case OpWriteKubeconfig:
+			task = writeKubeconfig()
+		default:
+			return nil, fmt.Errorf("bug: unsupported operation %q", op.Name)
+		}
+
+		op := op
+
+		tasks = append(tasks, dagTask{
+			Name: op.String(),
+			Deps: dependenciesOf(op, ops),
+			Run: func() error {
+				log.Printf("[DEBUG] running %s on cluster %s", op, clusterName)
+
+				if err := task(); err != nil {
+					return fmt.Errorf("%s: %w", op, err)
+				}
+
+				return nil
+			},
+		})
+	}
+
+	var results []taskResult
+
+	byName := map[string]Operation{}
+	for _, op := range ops {
+		byName[op.String()] = op
+	}
+
+	parallelism := d.Get(KeyMaxParallelOperations).(int)
+
+	err = runDAG(tasks, parallelism, func(r taskResult) {
+		op := byName[r.Name]
+
+		results = append(results, r)
+
+		if r.Err == nil {
+			checkpoint.Complete(op)
+		}
+	})
+
+	if err != nil {
+		if saveErr := saveUpdateProgress(d, checkpoint, results, true); saveErr != nil {
+			log.Printf("[WARN] failed saving update progress: %v", saveErr)
+		}
+
+		return nil, err
+	}
+
+	if err := saveUpdateProgress(d, checkpoint, results, false); err != nil {
+		return nil, err
+	}
+
 	return set, nil
 }
+
+// lockedResourceData serializes accesses to schema.ResourceData from concurrently running operations
+type lockedResourceData struct {
+	mu sync.Mutex
+	d  *schema.ResourceData
+}
+
+func (r *lockedResourceData) Get(k string) interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.d.Get(k)
+}
+
+func (r *lockedResourceData) GetChange(k string) (interface{}, interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.d.GetChange(k)
+}
+
+// Update runs the command without holding the lock, and then sets the output
+func (r *lockedResourceData) Update(ctx *sdk.Context, cmd *exec.Cmd) error {
+	res, err := ctx.Run(cmd)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sdk.SetOutput(r.d, res.Output)
+
+	return nil
+}
+
+func (r *lockedResourceData) WithLock(f func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return f()
+}
@@ -32,6 +32,24 @@ func newEksctlCommandFromResourceWithRegionAndProfile(resource api.Getter, args
 	return cmd, nil
 }
 
+// newEksctlCommandFromResourceWithProfile is for the commands reading the cluster.yaml with `-f`,
+// as eksctl fails when `--region` is given along with the config file
+func newEksctlCommandFromResourceWithProfile(resource api.Getter, args ...string) (*exec.Cmd, error) {
+	eksctlBin := resource.Get(KeyBin).(string)
+	eksctlVersion := resource.Get(KeyEksctlVersion).(string)
+
+	bin, err := sdk.PrepareExecutable(eksctlBin, "eksctl", eksctlVersion)
+	if err != nil {
+		return nil, fmt.Errorf("preparing eksctl binary: %w", err)
+	}
+
+	if _, profile := tfsdk.GetAWSRegionAndProfile(resource); profile != "" {
+		args = append(args, "--profile", profile)
+	}
+
+	return exec.Command(*bin, args...), nil
+}
+
 func newEksctlCommand(cluster *Cluster, args ...string) (*exec.Cmd, error) {
 	eksctlBin, err := prepareEksctlBinary(cluster)
 	if err != nil {
@@ -0,0 +1,360 @@
+package cluster
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// schemas contains the JSON schemas of eksctl's ClusterConfig, named after api_version like `eksctl.io_v1alpha5.json`.
+// Only the subset of JSON schema understood by configSchema is used.
+//
+//go:embed schemas/*.json
+var schemas embed.FS
+
+// configSchema is a JSON schema supporting $ref, type, properties, additionalProperties, items, enum and required
+type configSchema struct {
+	Ref                  string                   `json:"$ref"`
+	Type                 schemaTypes              `json:"type"`
+	Properties           map[string]*configSchema `json:"properties"`
+	AdditionalProperties *additionalProperties    `json:"additionalProperties"`
+	Items                *configSchema            `json:"items"`
+	Enum                 []string                 `json:"enum"`
+	Required             []string                 `json:"required"`
+	Definitions          map[string]*configSchema `json:"definitions"`
+}
+
+// schemaTypes is the `type` of a JSON schema, which is either a type name or a list of type names
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = schemaTypes{name}
+
+		return nil
+	}
+
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+
+	*t = names
+
+	return nil
+}
+
+// additionalProperties is either `false`, `true`, or the schema of the additional properties
+type additionalProperties struct {
+	Disallowed bool
+	Schema     *configSchema
+}
+
+func (a *additionalProperties) UnmarshalJSON(data []byte) error {
+	var allowed bool
+	if err := json.Unmarshal(data, &allowed); err == nil {
+		a.Disallowed = !allowed
+
+		return nil
+	}
+
+	return json.Unmarshal(data, &a.Schema)
+}
+
+// configSchemaError is a violation of the schema found at the path within the cluster.yaml
+type configSchemaError struct {
+	Path    []interface{}
+	Line    int
+	Message string
+}
+
+func (e configSchemaError) PathString() string {
+	var b strings.Builder
+
+	for _, p := range e.Path {
+		switch v := p.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", v)
+		default:
+			if b.Len() > 0 {
+				b.WriteString(".")
+			}
+			fmt.Fprintf(&b, "%v", v)
+		}
+	}
+
+	if b.Len() == 0 {
+		return "(root)"
+	}
+
+	return b.String()
+}
+
+func loadConfigSchema(apiVersion string) (*configSchema, error) {
+	bs, err := schemas.ReadFile("schemas/" + strings.ReplaceAll(apiVersion, "/", "_") + ".json")
+	if err != nil {
+		// No schema is embedded for the apiVersion
+		return nil, nil
+	}
+
+	var s configSchema
+
+	if err := json.Unmarshal(bs, &s); err != nil {
+		return nil, fmt.Errorf("bug: parsing the schema for %s: %w", apiVersion, err)
+	}
+
+	return &s, nil
+}
+
+// validateClusterConfig validates the diffed cluster config against the schema for its api_version,
+// so that mistakes like typos in field names are caught by `terraform plan`, rather than eksctl in the middle of `terraform apply`.
+func (m *Manager) validateClusterConfig(d *schema.ResourceDiff) error {
+	for _, k := range plannedKeys {
+		if !d.NewValueKnown(k) {
+			return nil
+		}
+	}
+
+	cluster, err := ReadCluster(d)
+	if err != nil {
+		return err
+	}
+
+	_, clusterConfig, err := m.renderClusterConfig(d, cluster, m.getClusterName(cluster, d.Id()))
+	if err != nil {
+		return err
+	}
+
+	return validateClusterConfigSchema(cluster.APIVersion, clusterConfig, cluster.Spec)
+}
+
+// validateClusterConfigSchema validates the cluster.yaml against the schema for the apiVersion.
+// Violations are reported with their lines in the spec when the violating fields come from the spec,
+// or in the generated cluster.yaml otherwise.
+func validateClusterConfigSchema(apiVersion string, clusterConfig []byte, spec string) error {
+	s, err := loadConfigSchema(apiVersion)
+	if err != nil {
+		return err
+	}
+
+	if s == nil {
+		log.Printf("[WARN] skipping validation of cluster config, as no schema is available for %s", apiVersion)
+
+		return nil
+	}
+
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(clusterConfig, &doc); err != nil {
+		return fmt.Errorf("parsing cluster.yaml: %w", err)
+	}
+
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	var errs []configSchemaError
+
+	v := &schemaValidator{root: s}
+	v.validate(s, doc.Content[0], nil, &errs)
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	var specDoc yaml.Node
+
+	// The spec has already been parsed by renderClusterConfig
+	_ = yaml.Unmarshal([]byte(spec), &specDoc)
+
+	var msgs []string
+
+	for _, e := range errs {
+		location := fmt.Sprintf("line %d of the generated cluster.yaml", e.Line)
+
+		if len(specDoc.Content) > 0 {
+			if n := lookupYAMLPath(specDoc.Content[0], e.Path); n != nil {
+				location = fmt.Sprintf("line %d of %s", n.Line, KeySpec)
+			}
+		}
+
+		msgs = append(msgs, fmt.Sprintf("%s (%s): %s", e.PathString(), location, e.Message))
+	}
+
+	return fmt.Errorf("validating cluster config against the schema for %s:\n%s\n\nCLUSTER CONFIG:\n%s", apiVersion, strings.Join(msgs, "\n"), string(clusterConfig))
+}
+
+type schemaValidator struct {
+	root *configSchema
+}
+
+func (v *schemaValidator) resolve(s *configSchema) *configSchema {
+	for s != nil && s.Ref != "" {
+		s = v.root.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+	}
+
+	return s
+}
+
+func (v *schemaValidator) validate(s *configSchema, n *yaml.Node, path []interface{}, errs *[]configSchemaError) {
+	s = v.resolve(s)
+	if s == nil {
+		return
+	}
+
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!null" {
+		return
+	}
+
+	addErr := func(line int, path []interface{}, format string, args ...interface{}) {
+		*errs = append(*errs, configSchemaError{Path: path, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.Type) > 0 && !matchesSchemaType(s.Type, n) {
+		addErr(n.Line, path, "expected %s, but got %s", strings.Join(s.Type, " or "), yamlNodeType(n))
+
+		return
+	}
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		present := map[string]bool{}
+
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, val := n.Content[i], n.Content[i+1]
+
+			present[strings.ToLower(k.Value)] = true
+
+			p := appendPath(path, k.Value)
+
+			if prop := lookupProperty(s.Properties, k.Value); prop != nil {
+				v.validate(prop, val, p, errs)
+			} else if s.AdditionalProperties != nil && s.AdditionalProperties.Disallowed {
+				addErr(k.Line, p, "unknown field %q", k.Value)
+			} else if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+				v.validate(s.AdditionalProperties.Schema, val, p, errs)
+			}
+		}
+
+		for _, r := range s.Required {
+			if !present[strings.ToLower(r)] {
+				addErr(n.Line, path, "missing required field %q", r)
+			}
+		}
+	case yaml.SequenceNode:
+		if s.Items != nil {
+			for i, item := range n.Content {
+				v.validate(s.Items, item, appendPath(path, i), errs)
+			}
+		}
+	case yaml.ScalarNode:
+		if len(s.Enum) > 0 && !containsString(s.Enum, n.Value) {
+			addErr(n.Line, path, "%q must be one of %s", n.Value, strings.Join(s.Enum, ", "))
+		}
+	}
+}
+
+func appendPath(path []interface{}, p interface{}) []interface{} {
+	r := make([]interface{}, len(path), len(path)+1)
+	copy(r, path)
+
+	return append(r, p)
+}
+
+// lookupProperty finds the property case-insensitively, as eksctl decodes cluster.yaml as JSON
+func lookupProperty(props map[string]*configSchema, name string) *configSchema {
+	if p, ok := props[name]; ok {
+		return p
+	}
+
+	for k, p := range props {
+		if strings.EqualFold(k, name) {
+			return p
+		}
+	}
+
+	return nil
+}
+
+func matchesSchemaType(types schemaTypes, n *yaml.Node) bool {
+	got := yamlNodeType(n)
+
+	for _, t := range types {
+		if t == got || t == "number" && got == "integer" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func yamlNodeType(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+
+	switch n.Tag {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	default:
+		return "string"
+	}
+}
+
+// lookupYAMLPath returns the node at the path, whose items are either mapping keys or sequence indices.
+// For a path ending with a mapping key, the key node is returned, as the value may span lines.
+func lookupYAMLPath(n *yaml.Node, path []interface{}) *yaml.Node {
+	for i, p := range path {
+		switch v := p.(type) {
+		case int:
+			if n.Kind != yaml.SequenceNode || v >= len(n.Content) {
+				return nil
+			}
+
+			n = n.Content[v]
+		case string:
+			if n.Kind != yaml.MappingNode {
+				return nil
+			}
+
+			var next *yaml.Node
+
+			for j := 0; j+1 < len(n.Content); j += 2 {
+				if n.Content[j].Value == v {
+					if i == len(path)-1 {
+						next = n.Content[j]
+					} else {
+						next = n.Content[j+1]
+					}
+
+					break
+				}
+			}
+
+			if next == nil {
+				return nil
+			}
+
+			n = next
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,69 @@
+package cluster
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateClusterConfigSchema(t *testing.T) {
+	spec := `nodeGroups:
+- name: ng1
+  instanceType: m5.large
+  desiredCapcity: 1
+managedNodeGroups:
+- name: mng1
+  spot: "yes"
+`
+
+	m := &Manager{DisableClusterNameSuffix: true}
+
+	d := newFakeChangeGetter(nil, map[string]interface{}{KeySpec: spec, KeyAPIVersion: DefaultAPIVersion, KeyVersion: "1.17"})
+
+	cluster, err := ReadCluster(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, clusterConfig, err := m.renderClusterConfig(d, cluster, "mycluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = validateClusterConfigSchema(DefaultAPIVersion, clusterConfig, spec)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	for _, want := range []string{
+		`nodeGroups[0].desiredCapcity (line 4 of spec): unknown field "desiredCapcity"`,
+		`managedNodeGroups[0].spot (line 7 of spec): expected boolean, but got string`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, but got:\n%v", want, err)
+		}
+	}
+
+	valid := strings.Replace(strings.Replace(spec, "desiredCapcity", "desiredCapacity", 1), `"yes"`, "true", 1)
+
+	d = newFakeChangeGetter(nil, map[string]interface{}{KeySpec: valid, KeyAPIVersion: DefaultAPIVersion, KeyVersion: "1.17"})
+
+	cluster, err = ReadCluster(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, clusterConfig, err = m.renderClusterConfig(d, cluster, "mycluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := validateClusterConfigSchema(DefaultAPIVersion, clusterConfig, valid); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateClusterConfigSchema_UnknownAPIVersion(t *testing.T) {
+	if err := validateClusterConfigSchema("eksctl.io/v1alpha99", []byte("foo: bar\n"), ""); err != nil {
+		t.Errorf("expected validation to be skipped, but got: %v", err)
+	}
+}
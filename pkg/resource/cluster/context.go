@@ -1,11 +1,27 @@
 package cluster
 
 import (
+	"context"
+
 	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/api"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/tfsdk"
 )
 
-func mustNewContext(cluster *Cluster) *sdk.Context {
+// mustNewContext returns the context to run commands for the cluster within.
+// The commands are interrupted when runCtx is done, on timeouts and when Terraform is interrupted.
+func mustNewContext(runCtx context.Context, cluster *Cluster) *sdk.Context {
 	sess, creds := sdk.AWSCredsFromValues(cluster.Region, cluster.Profile, cluster.AssumeRoleConfig)
 
-	return &sdk.Context{Sess: sess, Creds: creds}
+	return &sdk.Context{Sess: sess, Creds: creds, Ctx: runCtx}
+}
+
+// mustNewContextFromResource is mustNewContext for the resources and data sources that read region, profile and assume_role
+// from their own attributes rather than from an eksctl_cluster
+func mustNewContextFromResource(runCtx context.Context, d api.Getter) *sdk.Context {
+	region, profile := tfsdk.GetAWSRegionAndProfile(d)
+
+	sess, creds := sdk.AWSCredsFromValues(region, profile, tfsdk.GetAssumeRoleConfig(d))
+
+	return &sdk.Context{Sess: sess, Creds: creds, Ctx: runCtx}
 }
@@ -0,0 +1,113 @@
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultPublicAccessCIDRs is what EKS allows to access the public endpoint when vpc.publicAccessCIDRs is omitted
+var defaultPublicAccessCIDRs = []string{"0.0.0.0/0"}
+
+// controlPlaneOperations returns the operations that apply the changes in the control plane settings
+// that EKS updates in-place, in the order they are run.
+func controlPlaneOperations(old, new *EksctlClusterConfig) ([]string, error) {
+	var ops []string
+
+	if !reflect.DeepEqual(clusterLogging(old), clusterLogging(new)) {
+		ops = append(ops, OpUpdateClusterLogging)
+	}
+
+	if !reflect.DeepEqual(old.VPC.Rest["clusterEndpoints"], new.VPC.Rest["clusterEndpoints"]) {
+		ops = append(ops, OpUpdateClusterEndpoints)
+	}
+
+	if !reflect.DeepEqual(old.VPC.Rest["publicAccessCIDRs"], new.VPC.Rest["publicAccessCIDRs"]) {
+		ops = append(ops, OpSetPublicAccessCIDRs)
+	}
+
+	oldKey, newKey := secretsEncryptionKeyARN(old), secretsEncryptionKeyARN(new)
+
+	switch {
+	case oldKey == newKey:
+	case oldKey == "":
+		ops = append(ops, OpEnableSecretsEncryption)
+	default:
+		// EKS can neither disable secrets encryption nor change the KMS key once enabled
+		return nil, fmt.Errorf("secretsEncryption.keyARN can't be changed from %q to %q, as EKS doesn't support disabling secrets encryption or changing its key. "+
+			"Revert the change, or recreate the cluster", oldKey, newKey)
+	}
+
+	authOps, err := authenticationModeOperations(old, new)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ops, authOps...), nil
+}
+
+// clusterLogging returns cloudWatch.clusterLogging of the cluster.yaml, if any
+func clusterLogging(c *EksctlClusterConfig) interface{} {
+	cw, _ := c.Rest["cloudWatch"].(map[string]interface{})
+
+	return cw["clusterLogging"]
+}
+
+func secretsEncryptionKeyARN(c *EksctlClusterConfig) string {
+	se, _ := c.Rest["secretsEncryption"].(map[string]interface{})
+
+	arn, _ := se["keyARN"].(string)
+
+	return arn
+}
+
+// clusterConfigWithPublicAccessCIDRs returns the cluster.yaml with vpc.publicAccessCIDRs set to the EKS default when omitted,
+// so that removing the setting from the spec opens the public endpoint again.
+func clusterConfigWithPublicAccessCIDRs(clusterConfig []byte) ([]byte, error) {
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(clusterConfig, &doc); err != nil {
+		return nil, fmt.Errorf("parsing cluster.yaml: %w", err)
+	}
+
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("parsing cluster.yaml: expected a mapping at the top-level")
+	}
+
+	root := doc.Content[0]
+
+	vpc := mappingValue(root, "vpc")
+	if vpc == nil || vpc.Kind != yaml.MappingNode {
+		vpc = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "vpc"}, vpc)
+	}
+
+	if cidrs := mappingValue(vpc, "publicAccessCIDRs"); cidrs != nil && len(cidrs.Content) > 0 {
+		return clusterConfig, nil
+	}
+
+	seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for _, cidr := range defaultPublicAccessCIDRs {
+		seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: cidr})
+	}
+
+	if cidrs := mappingValue(vpc, "publicAccessCIDRs"); cidrs != nil {
+		*cidrs = *seq
+	} else {
+		vpc.Content = append(vpc.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "publicAccessCIDRs"}, seq)
+	}
+
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("encoding cluster.yaml: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,44 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClusterConfigWithPublicAccessCIDRs(t *testing.T) {
+	testcases := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			name:   "omitted",
+			config: "metadata:\n  name: mycluster\nvpc:\n  id: vpc-1\n",
+			want:   "metadata:\n  name: mycluster\nvpc:\n  id: vpc-1\n  publicAccessCIDRs:\n    - 0.0.0.0/0\n",
+		},
+		{
+			name:   "no vpc",
+			config: "metadata:\n  name: mycluster\n",
+			want:   "metadata:\n  name: mycluster\nvpc:\n  publicAccessCIDRs:\n    - 0.0.0.0/0\n",
+		},
+		{
+			name:   "specified",
+			config: "vpc:\n  publicAccessCIDRs:\n    - 1.1.1.1/32\n",
+			want:   "vpc:\n  publicAccessCIDRs:\n    - 1.1.1.1/32\n",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := clusterConfigWithPublicAccessCIDRs([]byte(tc.config))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if d := cmp.Diff(tc.want, string(got)); d != "" {
+				t.Errorf("unexpected cluster.yaml: want (-), got (+)\n%s", d)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dagTask is a node of the dependency graph run by runDAG
+type dagTask struct {
+	Name string
+	// Deps are the names of the tasks that must complete before this task starts.
+	// Names that aren't in the graph are considered completed.
+	Deps []string
+	Run  func() error
+}
+
+// runDAG runs tasks as soon as all their dependencies complete, with at most `parallelism` tasks running at once.
+// Ready tasks are started in the order they're given.
+//
+// onDone is called for every finished task, one at a time.
+// Once any task fails, no more task is started and runDAG returns after the running tasks finish.
+func runDAG(tasks []dagTask, parallelism int, onDone func(taskResult)) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	inGraph := map[string]bool{}
+	for _, t := range tasks {
+		inGraph[t.Name] = true
+	}
+
+	started := map[string]bool{}
+	completed := map[string]bool{}
+
+	ready := func(t dagTask) bool {
+		for _, dep := range t.Deps {
+			if inGraph[dep] && !completed[dep] {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	resultCh := make(chan taskResult)
+
+	var running int
+	var errs []error
+
+	for {
+		for _, t := range tasks {
+			if len(errs) > 0 || running >= parallelism {
+				break
+			}
+
+			if started[t.Name] || !ready(t) {
+				continue
+			}
+
+			started[t.Name] = true
+			running++
+
+			go func(t dagTask) {
+				start := time.Now()
+				err := t.Run()
+
+				resultCh <- taskResult{Name: t.Name, Duration: time.Since(start), Err: err}
+			}(t)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		r := <-resultCh
+		running--
+
+		if onDone != nil {
+			onDone(r)
+		}
+
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+		} else {
+			completed[r.Name] = true
+		}
+	}
+
+	switch len(errs) {
+	case 0:
+	case 1:
+		return errs[0]
+	default:
+		var msgs []string
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+
+		return fmt.Errorf("%d tasks failed:\n%s", len(errs), strings.Join(msgs, "\n\n"))
+	}
+
+	for _, t := range tasks {
+		if !started[t.Name] {
+			return fmt.Errorf("bug: task %q never became ready. Check for cyclic dependencies", t.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package cluster
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRunDAG(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	var running, maxRunning int
+
+	task := func(name string, deps ...string) dagTask {
+		return dagTask{
+			Name: name,
+			Deps: deps,
+			Run: func() error {
+				mu.Lock()
+				order = append(order, name)
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				mu.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+
+				return nil
+			},
+		}
+	}
+
+	tasks := []dagTask{
+		task(OpUpgradeCluster),
+		task(OpCreateNodeGroup, OpUpgradeCluster, OpUpdateCoreDNS),
+		task(OpCreateFargateProfile, OpUpgradeCluster),
+		task(OpCreateIAMServiceAccount, OpUpgradeCluster, OpAssociateIAMOIDCProvider),
+		task(OpUpdateIAMIdentityMapping, OpUpgradeCluster),
+		task(OpEnableRepo, OpUpgradeCluster),
+	}
+
+	var done []string
+
+	if err := runDAG(tasks, 2, func(r taskResult) { done = append(done, r.Name) }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if order[0] != OpUpgradeCluster {
+		t.Errorf("expected the cluster to be upgraded before creating nodegroups, but got %v", order)
+	}
+
+	if maxRunning > 2 {
+		t.Errorf("expected 2 tasks to run concurrently at most, but got %d", maxRunning)
+	}
+
+	if len(done) != len(tasks) {
+		t.Errorf("expected onDone to be called for every task, but got %v", done)
+	}
+}
+
+func TestRunDAG_Failure(t *testing.T) {
+	var ran []string
+
+	task := func(name string, err error, deps ...string) dagTask {
+		return dagTask{
+			Name: name,
+			Deps: deps,
+			Run: func() error {
+				ran = append(ran, name)
+				return err
+			},
+		}
+	}
+
+	tasks := []dagTask{
+		task("a", nil),
+		task("b", errors.New("b failed"), "a"),
+		task("c", nil, "b"),
+		task("d", nil, "b"),
+	}
+
+	err := runDAG(tasks, 1, nil)
+	if err == nil || err.Error() != "b failed" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if d := cmp.Diff([]string{"a", "b"}, ran); d != "" {
+		t.Errorf("expected no task to start after the failure: want (-), got (+)\n%s", d)
+	}
+}
+
+func TestRunDAG_Cycle(t *testing.T) {
+	tasks := []dagTask{
+		{Name: "a", Deps: []string{"b"}, Run: func() error { return nil }},
+		{Name: "b", Deps: []string{"a"}, Run: func() error { return nil }},
+	}
+
+	if err := runDAG(tasks, 4, nil); err == nil {
+		t.Fatal("expected error for cyclic dependencies")
+	}
+}
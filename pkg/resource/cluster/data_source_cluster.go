@@ -0,0 +1,178 @@
+package cluster
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/tfsdk"
+)
+
+const (
+	KeySubnetIDs      = "subnet_ids"
+	KeyNodeGroupNames = "node_group_names"
+)
+
+// DataSourceCluster is the `eksctl_cluster` data source.
+//
+// It looks up an existing cluster by name and region, so that the stacks that don't own the cluster
+// can read the same details as the `eksctl_cluster` resource exposes.
+func DataSourceCluster() *schema.Resource {
+	computedString := func() *schema.Schema {
+		return &schema.Schema{
+			Type:     schema.TypeString,
+			Computed: true,
+		}
+	}
+
+	computedStrings := func() *schema.Schema {
+		return &schema.Schema{
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		}
+	}
+
+	sc := map[string]*schema.Schema{
+		KeyName: {
+			Type:     schema.TypeString,
+			Required: true,
+		},
+		KeyRegion: {
+			Type:        schema.TypeString,
+			Required:    true,
+			DefaultFunc: schema.EnvDefaultFunc("AWS_DEFAULT_REGION", nil),
+		},
+		KeyProfile: {
+			Type:     schema.TypeString,
+			Optional: true,
+			Default:  "",
+		},
+		tfsdk.KeyAssumeRole: tfsdk.SchemaAssumeRole(),
+		KeyBin: {
+			Type:     schema.TypeString,
+			Optional: true,
+			Default:  "eksctl",
+		},
+		KeyEksctlVersion: {
+			Type:     schema.TypeString,
+			Optional: true,
+			Default:  "",
+		},
+		KeyVersion:          computedString(),
+		KeyOIDCProviderURL:  computedString(),
+		KeyOIDCProviderARN:  computedString(),
+		KeyVPCID:            computedString(),
+		KeySubnetIDs:        computedStrings(),
+		KeySecurityGroupIDs: computedStrings(),
+		KeyNodeGroupNames:   computedStrings(),
+		KeyTags: {
+			Type:     schema.TypeMap,
+			Computed: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+	}
+
+	for _, k := range clusterAttributeKeys {
+		sc[k] = computedString()
+	}
+
+	return &schema.Resource{
+		Read: func(d *schema.ResourceData, meta interface{}) (finalErr error) {
+			defer func() {
+				if err := recover(); err != nil {
+					finalErr = fmt.Errorf("unhandled error: %v\n%s", err, debug.Stack())
+				}
+			}()
+
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutRead))
+			defer cancel()
+
+			ctx := mustNewContextFromResource(runCtx, d)
+
+			clusterName := d.Get(KeyName).(string)
+
+			info, err := getLiveClusterInfo(ctx, d, clusterName)
+			if err != nil {
+				return fmt.Errorf("reading cluster %s: %w", clusterName, err)
+			}
+
+			if info == nil {
+				return fmt.Errorf("cluster %s not found", clusterName)
+			}
+
+			oidcProviderARN, err := existingOIDCProviderARN(iam.New(ctx.Session()), info.State)
+			if err != nil {
+				return err
+			}
+
+			for k, v := range dataSourceClusterAttributes(info, oidcProviderARN) {
+				if err := d.Set(k, v); err != nil {
+					return fmt.Errorf("setting %s: %w", k, err)
+				}
+			}
+
+			d.SetId(clusterName)
+
+			return nil
+		},
+		Schema: sc,
+	}
+}
+
+// dataSourceClusterAttributes returns the attributes of the eksctl_cluster data source read from the live cluster.
+// Unlike the resource, the OIDC provider URL is always returned, as the data source doesn't know the spec.
+func dataSourceClusterAttributes(info *LiveClusterInfo, oidcProviderARN string) map[string]interface{} {
+	state := info.State
+
+	attrs := clusterAttributes(state, false)
+
+	attrs[KeyVersion] = info.KubernetesVersion
+	attrs[KeyOIDCProviderURL] = state.Identity.Oidc.Issuer
+	attrs[KeyOIDCProviderARN] = oidcProviderARN
+	attrs[KeyVPCID] = state.ResourcesVpcConfig.VpcId
+	attrs[KeySubnetIDs] = state.ResourcesVpcConfig.SubnetIds
+	attrs[KeyTags] = state.Tags
+
+	var names []string
+
+	for _, ng := range info.NodeGroups {
+		names = append(names, ng.Name)
+	}
+
+	sort.Strings(names)
+
+	attrs[KeyNodeGroupNames] = names
+
+	return attrs
+}
+
+// existingOIDCProviderARN returns the ARN of the IAM OIDC provider of the cluster, or an empty string when it's not associated
+func existingOIDCProviderARN(svc iamiface.IAMAPI, state *ClusterState) (string, error) {
+	if state.Identity.Oidc.Issuer == "" {
+		return "", nil
+	}
+
+	arn := state.GetOIDCProviderARN()
+
+	if _, err := svc.GetOpenIDConnectProvider(&iam.GetOpenIDConnectProviderInput{OpenIDConnectProviderArn: aws.String(arn)}); err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == iam.ErrCodeNoSuchEntityException {
+			log.Printf("[DEBUG] OIDC provider %s isn't associated with cluster %s", arn, state.Name)
+
+			return "", nil
+		}
+
+		return "", fmt.Errorf("getting OIDC provider %s: %w", arn, err)
+	}
+
+	return arn, nil
+}
@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeIAM struct {
+	iamiface.IAMAPI
+
+	providers map[string]bool
+}
+
+func (f *fakeIAM) GetOpenIDConnectProvider(in *iam.GetOpenIDConnectProviderInput) (*iam.GetOpenIDConnectProviderOutput, error) {
+	if !f.providers[*in.OpenIDConnectProviderArn] {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, "not found", nil)
+	}
+
+	return &iam.GetOpenIDConnectProviderOutput{}, nil
+}
+
+func TestDataSourceClusterAttributes(t *testing.T) {
+	var states []*ClusterState
+
+	if err := json.Unmarshal([]byte(getClusterOutput), &states); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info := &LiveClusterInfo{
+		KubernetesVersion: "1.21",
+		NodeGroups:        []LiveNodeGroup{{Name: "ng2"}, {Name: "mng1"}},
+		State:             states[0],
+	}
+
+	const oidcProviderARN = "arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-2.amazonaws.com/id/0123456789ABCDEF"
+
+	arn, err := existingOIDCProviderARN(&fakeIAM{providers: map[string]bool{oidcProviderARN: true}}, info.State)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := dataSourceClusterAttributes(info, arn)
+
+	want := map[string]interface{}{
+		KeyVersion:                  "1.21",
+		KeyEndpoint:                 "https://0123456789ABCDEF.gr7.us-east-2.eks.amazonaws.com",
+		KeyCertificateAuthorityData: "LS0tLS1CRUdJTi==",
+		KeyARN:                      "arn:aws:eks:us-east-2:123456789012:cluster/mycluster",
+		KeyPlatformVersion:          "eks.5",
+		KeyStatus:                   "ACTIVE",
+		KeyClusterSecurityGroupID:   "sg-1",
+		KeyServiceIPv4CIDR:          "10.100.0.0/16",
+		KeySecurityGroupIDs:         []string{"sg-2"},
+		KeyOIDCProviderURL:          "https://oidc.eks.us-east-2.amazonaws.com/id/0123456789ABCDEF",
+		KeyOIDCProviderARN:          oidcProviderARN,
+		KeyVPCID:                    "vpc-1",
+		KeySubnetIDs:                []string{"subnet-1", "subnet-2"},
+		KeyTags:                     map[string]string{"team": "a"},
+		KeyNodeGroupNames:           []string{"mng1", "ng2"},
+	}
+
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("unexpected attributes: want (-), got (+)\n%s", d)
+	}
+}
+
+func TestExistingOIDCProviderARN_NotAssociated(t *testing.T) {
+	state := &ClusterState{
+		RoleArn:  "arn:aws:iam::123456789012:role/eksctl-mycluster-cluster-ServiceRole-1",
+		Identity: Identity{Oidc: Oidc{Issuer: "https://oidc.eks.us-east-2.amazonaws.com/id/0123456789ABCDEF"}},
+	}
+
+	arn, err := existingOIDCProviderARN(&fakeIAM{}, state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if arn != "" {
+		t.Errorf("expected no OIDC provider, got %s", arn)
+	}
+}
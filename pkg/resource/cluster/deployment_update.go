@@ -0,0 +1,289 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/courier"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+)
+
+// TagKeyRevision is the cluster tag used to record the `revision` the cluster was deployed from
+const TagKeyRevision = "tf-provider-eksctl/revision"
+
+// KeyPendingClusterDeletions lists the old clusters whose deletion failed after the traffic had been shifted to the new cluster
+const KeyPendingClusterDeletions = "pending_cluster_deletions"
+
+// updateClusterDeployment updates the cluster in-place when possible.
+// Whenever `revision` or `version` changes, it instead runs a blue-green deployment:
+// a new cluster is created and attached to the ALB, the traffic is gradually shifted to it,
+// and the old cluster is destroyed.
+func (m *Manager) updateClusterDeployment(runCtx context.Context, d *schema.ResourceData) (*ClusterSet, error) {
+	pending, _ := d.GetChange(KeyPendingClusterDeletions)
+
+	if !d.HasChange(KeyRevision) && !d.HasChange(KeyVersion) {
+		if err := m.deletePendingClusters(runCtx, d, pending.([]interface{})); err != nil {
+			return nil, err
+		}
+
+		return m.updateCluster(runCtx, d)
+	}
+
+	oldId := d.Id()
+	newId := newClusterID()
+
+	log.Printf("[DEBUG] replacing eksctl cluster with id %q with new cluster with id %q", oldId, newId)
+
+	set, err := m.PrepareClusterSet(d, newId)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := set.Cluster
+
+	oldClusterName := m.getClusterName(cluster, oldId)
+
+	svc := elbv2.New(AWSSessionFromCluster(cluster))
+
+	if err := doEksctlCreateCluster(runCtx, d, set); err != nil {
+		return nil, fmt.Errorf("creating new cluster %s: %w", set.ClusterName, err)
+	}
+
+	if err := doSetUpCluster(runCtx, d, set); err != nil {
+		log.Printf("Rolling back deployment of %s due to error: %v", set.ClusterName, err)
+
+		if rollbackErr := m.rollbackClusterDeployment(runCtx, d, svc, set, oldClusterName); rollbackErr != nil {
+			return nil, fmt.Errorf("setting up new cluster %s: %w\n\nrolling back also failed: %v", set.ClusterName, err, rollbackErr)
+		}
+
+		return nil, fmt.Errorf("setting up new cluster %s: %w", set.ClusterName, err)
+	}
+
+	if err := graduallyShiftTraffic(runCtx, trafficShiftableClusterSet(set), set.CanaryOpts); err != nil {
+		log.Printf("Rolling back deployment of %s due to error: %v", set.ClusterName, err)
+
+		if rollbackErr := m.rollbackClusterDeployment(runCtx, d, svc, set, oldClusterName); rollbackErr != nil {
+			return nil, fmt.Errorf("shifting traffic from %s to %s: %w\n\nrolling back also failed: %v", oldClusterName, set.ClusterName, err, rollbackErr)
+		}
+
+		return nil, fmt.Errorf("shifting traffic from %s to %s: %w", oldClusterName, set.ClusterName, err)
+	}
+
+	var oldTGARNs, newTGARNs []interface{}
+
+	for _, l := range set.ListenerStatuses {
+		if l.DesiredTG == nil {
+			continue
+		}
+
+		newTGARNs = append(newTGARNs, *l.DesiredTG.TargetGroupArn)
+
+		if l.CurrentTG == nil || l.Rule == nil {
+			continue
+		}
+
+		if err := forwardAllTrafficTo(svc, l, l.DesiredTG); err != nil {
+			return nil, fmt.Errorf("detaching old target group %s from listener rule: %w", *l.CurrentTG.TargetGroupName, err)
+		}
+
+		oldTGARNs = append(oldTGARNs, *l.CurrentTG.TargetGroupArn)
+	}
+
+	// The new cluster is serving all the traffic from now on. Record it before deleting the old cluster,
+	// so that the state keeps tracking the new cluster and the next apply can resume the deletion on failure.
+	d.SetId(newId)
+
+	if err := d.Set(KeyTargetGroupARNs, newTGARNs); err != nil {
+		return nil, fmt.Errorf("setting %s: %w", KeyTargetGroupARNs, err)
+	}
+
+	remaining := append(pending.([]interface{}), map[string]interface{}{
+		KeyName:            string(oldClusterName),
+		KeyTargetGroupARNs: oldTGARNs,
+	})
+
+	if err := m.deletePendingClusters(runCtx, d, remaining); err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+// deletePendingClusters deletes the old clusters replaced by blue-green deployments, along with their target groups.
+// Clusters that are yet to be deleted are kept in `pending_cluster_deletions`, so that the next apply can retry the deletion.
+func (m *Manager) deletePendingClusters(runCtx context.Context, d *schema.ResourceData, pending []interface{}) error {
+	if err := d.Set(KeyPendingClusterDeletions, pending); err != nil {
+		return fmt.Errorf("setting %s: %w", KeyPendingClusterDeletions, err)
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	cluster, err := ReadCluster(d)
+	if err != nil {
+		return err
+	}
+
+	ctx := mustNewContext(runCtx, cluster)
+
+	for i, v := range pending {
+		p := v.(map[string]interface{})
+
+		old := *cluster
+		old.TargetGroupARNs = nil
+
+		for _, arn := range p[KeyTargetGroupARNs].([]interface{}) {
+			old.TargetGroupARNs = append(old.TargetGroupARNs, arn.(string))
+		}
+
+		oldName := ClusterName(p[KeyName].(string))
+
+		oldSet := &ClusterSet{
+			ClusterID:   clusterIDOf(cluster, oldName),
+			ClusterName: oldName,
+			Cluster:     &old,
+		}
+
+		if err := deletePendingCluster(runCtx, ctx, d, oldSet); err != nil {
+			if setErr := d.Set(KeyPendingClusterDeletions, pending[i:]); setErr != nil {
+				log.Printf("[WARN] setting %s: %v", KeyPendingClusterDeletions, setErr)
+			}
+
+			return fmt.Errorf("deleting old cluster %s: %w", oldSet.ClusterName, err)
+		}
+	}
+
+	return d.Set(KeyPendingClusterDeletions, []interface{}{})
+}
+
+// deletePendingCluster deletes the old cluster unless a previous attempt has already deleted it, and then its target groups
+func deletePendingCluster(runCtx context.Context, ctx *sdk.Context, d *schema.ResourceData, set *ClusterSet) error {
+	_, notFound, err := runEksctlGet(ctx, d, "cluster", "--name", string(set.ClusterName), "-o", "json")
+	if err != nil {
+		return err
+	}
+
+	if notFound {
+		log.Printf("[DEBUG] old cluster %s has already been deleted", set.ClusterName)
+
+		if err := deleteVPCResourceTags(set.Cluster, set.ClusterName); err != nil {
+			return err
+		}
+	} else if err := deleteClusterByName(runCtx, set); err != nil {
+		return err
+	}
+
+	return deleteTargetGroups(set)
+}
+
+// rollbackClusterDeployment reverts the listener rules to the old cluster's target groups,
+// and then deletes the new cluster along with its target groups.
+func (m *Manager) rollbackClusterDeployment(runCtx context.Context, d *schema.ResourceData, svc elbv2iface.ELBV2API, set *ClusterSet, oldClusterName ClusterName) error {
+	failed := *set.Cluster
+	failed.TargetGroupARNs = nil
+
+	for _, l := range set.ListenerStatuses {
+		if l.DesiredTG == nil {
+			continue
+		}
+
+		if l.CurrentTG != nil && l.Rule != nil {
+			if err := forwardAllTrafficTo(svc, l, l.CurrentTG); err != nil {
+				return fmt.Errorf("reverting listener rule to target group %s: %w", *l.CurrentTG.TargetGroupName, err)
+			}
+		}
+
+		failed.TargetGroupARNs = append(failed.TargetGroupARNs, *l.DesiredTG.TargetGroupArn)
+	}
+
+	failedSet := *set
+	failedSet.Cluster = &failed
+
+	if err := deleteClusterByName(runCtx, &failedSet); err != nil {
+		return err
+	}
+
+	if err := deleteTargetGroups(&failedSet); err != nil {
+		return err
+	}
+
+	return doWriteKubeconfig(mustNewContext(runCtx, set.Cluster), d, string(oldClusterName), set.Cluster.Region)
+}
+
+// trafficShiftableClusterSet returns a copy of the set that contains only listeners that
+// had been forwarding to the old cluster. A listener rule that has just been created for the new cluster
+// already forwards all the traffic to it.
+func trafficShiftableClusterSet(set *ClusterSet) *ClusterSet {
+	s := *set
+
+	s.ListenerStatuses = ListenerStatuses{}
+
+	for arn, l := range set.ListenerStatuses {
+		if l.CurrentTG != nil && l.DesiredTG != nil && l.Rule != nil {
+			s.ListenerStatuses[arn] = l
+		}
+	}
+
+	return &s
+}
+
+func forwardAllTrafficTo(svc elbv2iface.ELBV2API, l courier.ListenerStatus, tg *elbv2.TargetGroup) error {
+	_, err := svc.ModifyRule(&elbv2.ModifyRuleInput{
+		Actions: []*elbv2.Action{
+			{
+				ForwardConfig: &elbv2.ForwardActionConfig{
+					TargetGroups: []*elbv2.TargetGroupTuple{
+						{
+							TargetGroupArn: tg.TargetGroupArn,
+							Weight:         aws.Int64(100),
+						},
+					},
+				},
+				Type: aws.String("forward"),
+			},
+		},
+		RuleArn: l.Rule.RuleArn,
+	})
+
+	return err
+}
+
+func deleteClusterByName(runCtx context.Context, set *ClusterSet) error {
+	cluster := set.Cluster
+
+	ctx := mustNewContext(runCtx, cluster)
+
+	if err := doDeleteKubernetesResourcesBeforeDestroy(ctx, cluster, string(set.ClusterName)); err != nil {
+		return err
+	}
+
+	cmd, err := newEksctlCommandWithAWSProfile(cluster, "delete", "cluster", "--name", string(set.ClusterName), "--region", cluster.Region, "--wait")
+	if err != nil {
+		return fmt.Errorf("creating eksctl-delete command: %w", err)
+	}
+
+	if err := ctx.Delete(cmd); err != nil {
+		return err
+	}
+
+	return deleteVPCResourceTags(cluster, set.ClusterName)
+}
+
+// clusterIDOf returns the ID that the cluster name was suffixed with by getClusterName, or an empty string when the
+// name isn't suffixed.
+func clusterIDOf(cluster *Cluster, name ClusterName) string {
+	prefix := cluster.Name + "-"
+
+	if !strings.HasPrefix(string(name), prefix) {
+		return ""
+	}
+
+	return strings.TrimPrefix(string(name), prefix)
+}
@@ -0,0 +1,102 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/courier"
+)
+
+func TestTrafficShiftableClusterSet(t *testing.T) {
+	current := &elbv2.TargetGroup{TargetGroupArn: aws.String("tg-current")}
+	desired := &elbv2.TargetGroup{TargetGroupArn: aws.String("tg-desired")}
+	rule := &elbv2.Rule{RuleArn: aws.String("rule")}
+
+	set := &ClusterSet{
+		ListenerStatuses: ListenerStatuses{
+			"shifted": courier.ListenerStatus{CurrentTG: current, DesiredTG: desired, Rule: rule},
+			"created": courier.ListenerStatus{DesiredTG: desired, Rule: rule},
+		},
+	}
+
+	s := trafficShiftableClusterSet(set)
+
+	if _, ok := s.ListenerStatuses["shifted"]; !ok {
+		t.Errorf("expected listener with both current and desired target groups to be shifted")
+	}
+
+	if _, ok := s.ListenerStatuses["created"]; ok {
+		t.Errorf("expected listener without current target group not to be shifted")
+	}
+
+	if len(set.ListenerStatuses) != 2 {
+		t.Errorf("expected the original set to be untouched, but got %d listeners", len(set.ListenerStatuses))
+	}
+}
+
+func TestForwardAllTrafficTo(t *testing.T) {
+	var got *elbv2.ModifyRuleInput
+
+	svc := mockedAWS{
+		ModifyRuleFunc: func(i *elbv2.ModifyRuleInput) (*elbv2.ModifyRuleOutput, error) {
+			got = i
+			return &elbv2.ModifyRuleOutput{}, nil
+		},
+	}
+
+	l := courier.ListenerStatus{Rule: &elbv2.Rule{RuleArn: aws.String("rule")}}
+
+	if err := forwardAllTrafficTo(svc, l, &elbv2.TargetGroup{TargetGroupArn: aws.String("tg-desired")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tgs := got.Actions[0].ForwardConfig.TargetGroups
+
+	if d := cmp.Diff([]string{"tg-desired"}, []string{*tgs[0].TargetGroupArn}); d != "" || len(tgs) != 1 {
+		t.Errorf("unexpected target groups: want (-), got (+)\n%s", d)
+	}
+
+	if w := *tgs[0].Weight; w != 100 {
+		t.Errorf("unexpected weight: want 100, got %d", w)
+	}
+
+	if arn := *got.RuleArn; arn != "rule" {
+		t.Errorf("unexpected rule arn: %s", arn)
+	}
+}
+
+func TestPendingClusterDeletionOperations(t *testing.T) {
+	pending := []interface{}{
+		map[string]interface{}{KeyName: "foo-old1", KeyTargetGroupARNs: []interface{}{"tg-old1"}},
+		map[string]interface{}{KeyName: "foo-old2", KeyTargetGroupARNs: []interface{}{}},
+	}
+
+	got := operationNames(pendingClusterDeletionOperations(pending))
+
+	want := operationNames([]Operation{
+		{Name: OpDeleteCluster, Targets: []string{"foo-old1"}},
+		{Name: OpDeleteCluster, Targets: []string{"foo-old2"}},
+	})
+
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("unexpected operations: want (-), got (+)\n%s", d)
+	}
+
+	if ops := pendingClusterDeletionOperations(nil); len(ops) != 0 {
+		t.Errorf("expected no operations without pending deletions, but got %v", ops)
+	}
+}
+
+func TestClusterIDOf(t *testing.T) {
+	cluster := &Cluster{Name: "mycluster"}
+
+	if got := clusterIDOf(cluster, "mycluster-abc123"); got != "abc123" {
+		t.Errorf("unexpected ID: %q", got)
+	}
+
+	if got := clusterIDOf(cluster, "mycluster"); got != "" {
+		t.Errorf("unexpected ID: %q", got)
+	}
+}
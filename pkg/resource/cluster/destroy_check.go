@@ -0,0 +1,304 @@
+package cluster
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+)
+
+const (
+	// KeyDeletionProtection makes the provider refuse to destroy the cluster, including replacements due to changes
+	// in attributes like `name` and `region`
+	KeyDeletionProtection = "deletion_protection"
+
+	// KeyForceDestroy skips the pre-destroy checks for resources that would be orphaned by destroying the cluster
+	KeyForceDestroy = "force_destroy"
+)
+
+const ebsCSIDriver = "ebs.csi.aws.com"
+
+const (
+	// loadBalancerServicesJSONPath outputs the namespace, name, and hostname of each service of type LoadBalancer
+	loadBalancerServicesJSONPath = `jsonpath={range .items[?(@.spec.type=="LoadBalancer")]}` +
+		`{.metadata.namespace}{"\t"}{.metadata.name}{"\t"}{.status.loadBalancer.ingress[*].hostname}{"\n"}{end}`
+
+	// persistentVolumesJSONPath outputs the name, EBS volume, CSI driver and volume, reclaim policy, and claim of each persistent volume
+	persistentVolumesJSONPath = `jsonpath={range .items[*]}` +
+		`{.metadata.name}{"\t"}{.spec.awsElasticBlockStore.volumeID}{"\t"}{.spec.csi.driver}{"\t"}{.spec.csi.volumeHandle}{"\t"}` +
+		`{.spec.persistentVolumeReclaimPolicy}{"\t"}{.spec.claimRef.namespace}{"\t"}{.spec.claimRef.name}{"\n"}{end}`
+)
+
+// loadBalancerService is a Kubernetes service of type LoadBalancer
+type loadBalancerService struct {
+	Namespace string
+	Name      string
+	Hostname  string
+}
+
+// ebsPersistentVolume is a Kubernetes persistent volume backed by an EBS volume
+type ebsPersistentVolume struct {
+	Name           string
+	VolumeID       string
+	ReclaimPolicy  string
+	ClaimNamespace string
+	ClaimName      string
+}
+
+// checkDeletionProtection fails when deletion_protection is enabled.
+// It reads the value in the state, so disabling deletion protection needs to be applied before the cluster can be destroyed.
+func checkDeletionProtection(cluster *Cluster, clusterName ClusterName) error {
+	if !cluster.DeletionProtection {
+		return nil
+	}
+
+	return fmt.Errorf("refusing to destroy cluster %s, as %s is enabled. "+
+		"If you really want to destroy it, set `%s = false` and run `terraform apply` first. "+
+		"Note that changing attributes like `name`, `region` and `vpc_id` also destroys the cluster",
+		clusterName, KeyDeletionProtection, KeyDeletionProtection)
+}
+
+// checkOrphanedResources fails with the list of resources that would be left behind by destroying the cluster,
+// unless force_destroy is set.
+func checkOrphanedResources(ctx *sdk.Context, set *ClusterSet) error {
+	cluster := set.Cluster
+
+	if cluster.ForceDestroy {
+		return nil
+	}
+
+	orphans, err := kubernetesOrphans(ctx, set)
+	if err != nil {
+		// The cluster may be already broken or deleted. Destroying it shouldn't be blocked by that
+		log.Printf("[WARN] skipped checking Kubernetes resources that would be orphaned by destroying cluster %s, as the cluster is unreachable: %v", set.ClusterName, err)
+	}
+
+	tgOrphans, err := targetGroupOrphans(ctx, set)
+	if err != nil {
+		return err
+	}
+
+	orphans = append(orphans, tgOrphans...)
+
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	log.Printf("[WARN] destroying cluster %s would orphan %d resources", set.ClusterName, len(orphans))
+
+	return fmt.Errorf("refusing to destroy cluster %s, as the following resources would be left behind or stop serving traffic. "+
+		"Delete them first, or set `%s = true` and run `terraform apply` to destroy the cluster anyway:\n- %s",
+		set.ClusterName, KeyForceDestroy, strings.Join(orphans, "\n- "))
+}
+
+// kubernetesOrphans returns the services of type LoadBalancer and the persistent volumes whose AWS resources would be left behind
+func kubernetesOrphans(ctx *sdk.Context, set *ClusterSet) ([]string, error) {
+	cluster := set.Cluster
+
+	kubeconfigPath, err := doWriteTempKubeconfig(ctx, cluster, string(set.ClusterName))
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.Remove(kubeconfigPath)
+
+	var orphans []string
+
+	res, err := ctx.Run(newKubectlCommand(cluster, kubeconfigPath, "get", "services", "--all-namespaces", "-o", loadBalancerServicesJSONPath))
+	if err != nil {
+		return nil, fmt.Errorf("listing services of type LoadBalancer: %w", err)
+	}
+
+	// Services of type LoadBalancer are deleted by cleanup_aws_resources_before_destroy
+	if !cluster.CleanupAWSResourcesBeforeDestroy {
+		for _, svc := range parseLoadBalancerServices(res.Output) {
+			if isDeletedBeforeDestroy(cluster.DeleteKubernetesResourcesBeforeDestroy, svc.Namespace, svc.Name) {
+				continue
+			}
+
+			s := fmt.Sprintf("Service %s/%s of type LoadBalancer", svc.Namespace, svc.Name)
+			if svc.Hostname != "" {
+				s += fmt.Sprintf(" (%s)", svc.Hostname)
+			}
+
+			orphans = append(orphans, s)
+		}
+	}
+
+	res, err = ctx.Run(newKubectlCommand(cluster, kubeconfigPath, "get", "persistentvolumes", "-o", persistentVolumesJSONPath))
+	if err != nil {
+		return nil, fmt.Errorf("listing persistent volumes: %w", err)
+	}
+
+	for _, pv := range parseEBSPersistentVolumes(res.Output) {
+		// Volumes whose reclaim policy is Delete are deleted by cleanup_aws_resources_before_destroy
+		if cluster.CleanupAWSResourcesBeforeDestroy && pv.ReclaimPolicy == "Delete" {
+			continue
+		}
+
+		orphans = append(orphans, fmt.Sprintf("PersistentVolume %s backed by EBS volume %s (reclaim policy %s)", pv.Name, pv.VolumeID, pv.ReclaimPolicy))
+	}
+
+	return orphans, nil
+}
+
+// targetGroupOrphans returns the target groups that forward traffic to the nodegroups of the cluster, but aren't deleted along with the cluster.
+// Target groups created for alb_attachment are deleted by deleteClusterTargetGroups, so they aren't reported.
+func targetGroupOrphans(ctx *sdk.Context, set *ClusterSet) ([]string, error) {
+	sess := ctx.Session()
+
+	groups, err := clusterAutoScalingGroups(autoscaling.New(sess), string(set.ClusterName))
+	if err != nil {
+		return nil, err
+	}
+
+	deleted, err := clusterTargetGroupARNs(set)
+	if err != nil {
+		return nil, err
+	}
+
+	arns := retainedTargetGroupARNs(groups, deleted)
+
+	elb := elbv2.New(sess)
+
+	var orphans []string
+
+	for _, arn := range arns {
+		rules, err := listenerRulesSendingTrafficTo(elb, arn)
+		if err != nil {
+			return nil, fmt.Errorf("checking traffic to target group %s: %w", arn, err)
+		}
+
+		for _, r := range rules {
+			orphans = append(orphans, fmt.Sprintf("Target group %s, receiving traffic from listener rule %s", arn, r))
+		}
+	}
+
+	return orphans, nil
+}
+
+// retainedTargetGroupARNs returns the target groups attached to the autoscaling groups, excluding the ones to be deleted
+func retainedTargetGroupARNs(groups []*autoscaling.Group, deleted []string) []string {
+	var arns []string
+
+	for _, g := range groups {
+		for _, arn := range aws.StringValueSlice(g.TargetGroupARNs) {
+			if !containsString(deleted, arn) && !containsString(arns, arn) {
+				arns = append(arns, arn)
+			}
+		}
+	}
+
+	sort.Strings(arns)
+
+	return arns
+}
+
+// parseLoadBalancerServices parses the output of `kubectl get services` with loadBalancerServicesJSONPath
+func parseLoadBalancerServices(out string) []loadBalancerService {
+	var services []loadBalancerService
+
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 || fields[1] == "" {
+			continue
+		}
+
+		services = append(services, loadBalancerService{Namespace: fields[0], Name: fields[1], Hostname: fields[2]})
+	}
+
+	return services
+}
+
+func isDeletedBeforeDestroy(deleted []DeleteKubernetesResource, ns, name string) bool {
+	for _, d := range deleted {
+		switch strings.ToLower(d.Kind) {
+		case "service", "services", "svc":
+		default:
+			continue
+		}
+
+		if d.Name == name && (d.Namespace == ns || d.Namespace == "" && ns == "default") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseEBSPersistentVolumes parses the output of `kubectl get persistentvolumes` with persistentVolumesJSONPath,
+// returning the persistent volumes backed by EBS volumes.
+// EBS volumes are deleted by the provisioner running in the cluster, so they are left behind by destroying the cluster regardless of the reclaim policy.
+func parseEBSPersistentVolumes(out string) []ebsPersistentVolume {
+	var volumes []ebsPersistentVolume
+
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 || fields[0] == "" {
+			continue
+		}
+
+		pv := ebsPersistentVolume{
+			Name:           fields[0],
+			VolumeID:       fields[1],
+			ReclaimPolicy:  fields[4],
+			ClaimNamespace: fields[5],
+			ClaimName:      fields[6],
+		}
+
+		if pv.VolumeID == "" && fields[2] == ebsCSIDriver {
+			pv.VolumeID = fields[3]
+		}
+
+		if pv.VolumeID == "" {
+			continue
+		}
+
+		volumes = append(volumes, pv)
+	}
+
+	return volumes
+}
+
+// listenerRulesSendingTrafficTo returns the ARNs of the listener rules that forward to the target group with non-zero weights
+func listenerRulesSendingTrafficTo(elb elbv2iface.ELBV2API, tgARN string) ([]string, error) {
+	res, err := elb.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{
+		TargetGroupArns: aws.StringSlice([]string{tgARN}),
+	})
+	if isTargetGroupNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("describing target group: %w", err)
+	}
+
+	var rules []string
+
+	for _, tg := range res.TargetGroups {
+		for _, lbARN := range tg.LoadBalancerArns {
+			if err := forEachListenerRule(elb, aws.StringValue(lbARN), func(_ string, r *elbv2.Rule) error {
+				for _, a := range r.Actions {
+					tuples := forwardedTargetGroups(a)
+
+					for _, t := range tuples {
+						if aws.StringValue(t.TargetGroupArn) == tgARN && (len(tuples) == 1 || aws.Int64Value(t.Weight) > 0) {
+							rules = append(rules, aws.StringValue(r.RuleArn))
+						}
+					}
+				}
+
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return rules, nil
+}
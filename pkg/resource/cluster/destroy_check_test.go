@@ -0,0 +1,95 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckDeletionProtection(t *testing.T) {
+	if err := checkDeletionProtection(&Cluster{}, "mycluster"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := checkDeletionProtection(&Cluster{DeletionProtection: true}, "mycluster"); err == nil {
+		t.Error("expected error for the cluster with deletion protection")
+	}
+}
+
+func TestParseLoadBalancerServices(t *testing.T) {
+	out := "default\tweb\ta1.us-east-2.elb.amazonaws.com\n" +
+		"ingress\tnginx\t\n"
+
+	want := []loadBalancerService{
+		{Namespace: "default", Name: "web", Hostname: "a1.us-east-2.elb.amazonaws.com"},
+		{Namespace: "ingress", Name: "nginx"},
+	}
+
+	if d := cmp.Diff(want, parseLoadBalancerServices(out)); d != "" {
+		t.Errorf("unexpected services: want (-), got (+)\n%s", d)
+	}
+}
+
+func TestIsDeletedBeforeDestroy(t *testing.T) {
+	deleted := []DeleteKubernetesResource{
+		{Namespace: "default", Kind: "svc", Name: "api"},
+		{Namespace: "ingress", Kind: "deployment", Name: "nginx"},
+	}
+
+	if !isDeletedBeforeDestroy(deleted, "default", "api") {
+		t.Error("expected default/api to be deleted before destroy")
+	}
+
+	if isDeletedBeforeDestroy(deleted, "ingress", "nginx") {
+		t.Error("expected ingress/nginx not to be deleted before destroy, as the deployment isn't a service")
+	}
+}
+
+func TestParseEBSPersistentVolumes(t *testing.T) {
+	out := "pv1\taws://us-east-2a/vol-1\t\t\tDelete\tdefault\tdata-db-0\n" +
+		"pv2\t\tebs.csi.aws.com\tvol-2\tRetain\tdefault\tdata-db-1\n" +
+		"pv3\t\tefs.csi.aws.com\tfs-3\tRetain\t\t\n"
+
+	want := []ebsPersistentVolume{
+		{Name: "pv1", VolumeID: "aws://us-east-2a/vol-1", ReclaimPolicy: "Delete", ClaimNamespace: "default", ClaimName: "data-db-0"},
+		{Name: "pv2", VolumeID: "vol-2", ReclaimPolicy: "Retain", ClaimNamespace: "default", ClaimName: "data-db-1"},
+	}
+
+	if d := cmp.Diff(want, parseEBSPersistentVolumes(out)); d != "" {
+		t.Errorf("unexpected volumes: want (-), got (+)\n%s", d)
+	}
+}
+
+func TestRetainedTargetGroupARNs(t *testing.T) {
+	groups := []*autoscaling.Group{
+		{TargetGroupARNs: aws.StringSlice([]string{"tg-attachment", "tg-external"})},
+		{TargetGroupARNs: aws.StringSlice([]string{"tg-external", "tg-another"})},
+	}
+
+	got := retainedTargetGroupARNs(groups, []string{"tg-attachment"})
+
+	if d := cmp.Diff([]string{"tg-another", "tg-external"}, got); d != "" {
+		t.Errorf("unexpected target groups: want (-), got (+)\n%s", d)
+	}
+}
+
+func TestIsTargetGroupOf(t *testing.T) {
+	testcases := []struct {
+		arn  string
+		want bool
+	}{
+		{arn: "arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/ng1-30080-abc123/73e2d6bc24d8a067", want: true},
+		{arn: "arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/ng1-30080-def456/73e2d6bc24d8a067", want: false},
+		{arn: "arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/ng1-30080-xabc123/73e2d6bc24d8a067", want: false},
+		{arn: "arn:aws:elasticloadbalancing:us-east-2:123456789012:loadbalancer/app/ng1-abc123/73e2d6bc24d8a067", want: false},
+		{arn: "tg1", want: false},
+	}
+
+	for _, tc := range testcases {
+		if got := isTargetGroupOf(tc.arn, "abc123"); got != tc.want {
+			t.Errorf("isTargetGroupOf(%q): want %v, got %v", tc.arn, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package cluster
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// KeyNodeGroupDrift lists the out-of-band changes to the nodegroups in spec, like `ng1: desiredCapacity changed out-of-band from 1 to 3`
+const KeyNodeGroupDrift = "node_group_drift"
+
+// refreshFromLiveCluster writes the live Kubernetes version and nodegroups into the state,
+// so that out-of-band changes show up in `terraform plan` and are reverted on the next `terraform apply`.
+func (m *Manager) refreshFromLiveCluster(d *schema.ResourceData, info *LiveClusterInfo) error {
+	if info.KubernetesVersion != "" {
+		if err := d.Set(KeyVersion, info.KubernetesVersion); err != nil {
+			return fmt.Errorf("setting %s: %w", KeyVersion, err)
+		}
+	}
+
+	// Clusters created before the revision tag was introduced have no revision to compare against.
+	if !m.DisableClusterNameSuffix && info.Revision != 0 {
+		if err := d.Set(KeyRevision, info.Revision); err != nil {
+			return fmt.Errorf("setting %s: %w", KeyRevision, err)
+		}
+	}
+
+	immutable, _ := d.Get(KeyImmutableNodeGroups).(bool)
+
+	live := indexLiveNodeGroups(info.NodeGroups, immutable)
+
+	spec, _ := d.Get(KeySpec).(string)
+
+	// spec is left as written by the user, so that its comments and formatting are kept
+	drift, err := nodeGroupDrift(spec, live)
+	if err != nil {
+		return fmt.Errorf("comparing %s with live nodegroups: %w", KeySpec, err)
+	}
+
+	if len(drift) > 0 {
+		log.Printf("[DEBUG] detected out-of-band changes to nodegroups:\n%s", strings.Join(drift, "\n"))
+	}
+
+	if err := d.Set(KeyNodeGroupDrift, drift); err != nil {
+		return fmt.Errorf("setting %s: %w", KeyNodeGroupDrift, err)
+	}
+
+	for _, k := range []string{KeyNodeGroup, KeyManagedNodeGroup} {
+		blocks, _ := d.Get(k).([]interface{})
+
+		reconciled, changed := reconcileNodeGroupBlocksWithLive(blocks, live)
+		if !changed {
+			continue
+		}
+
+		if err := d.Set(k, reconciled); err != nil {
+			return fmt.Errorf("setting %s: %w", k, err)
+		}
+	}
+
+	return nil
+}
+
+// reconcileNodeGroupBlocksWithLive updates `node_group` and `managed_node_group` blocks with the live nodegroups.
+// Capacities left to eksctl's defaults aren't tracked.
+func reconcileNodeGroupBlocksWithLive(blocks []interface{}, liveByName map[string]LiveNodeGroup) ([]interface{}, bool) {
+	var changed bool
+
+	var reconciled []interface{}
+
+	for _, b := range blocks {
+		ng := b.(map[string]interface{})
+		name := ng["name"].(string)
+
+		l, ok := liveByName[name]
+		if !ok {
+			log.Printf("[DEBUG] nodegroup %s has been deleted out-of-band", name)
+
+			changed = true
+
+			continue
+		}
+
+		for k, liveValue := range map[string]int{
+			"desired_capacity": l.DesiredCapacity,
+			"min_size":         l.MinSize,
+			"max_size":         l.MaxSize,
+		} {
+			if v, _ := ng[k].(int); v != unsetCapacity && v != liveValue {
+				log.Printf("[DEBUG] %s of nodegroup %s has been changed out-of-band from %d to %d", k, name, v, liveValue)
+
+				ng[k] = liveValue
+				changed = true
+			}
+		}
+
+		reconciled = append(reconciled, ng)
+	}
+
+	return reconciled, changed
+}
+
+// indexLiveNodeGroups returns the live nodegroups keyed by their names.
+// When immutable_node_groups is enabled, they're also keyed by the names without hashes, which are the names in the configuration.
+func indexLiveNodeGroups(live []LiveNodeGroup, immutable bool) map[string]LiveNodeGroup {
+	liveByName := map[string]LiveNodeGroup{}
+
+	for _, ng := range live {
+		liveByName[ng.Name] = ng
+	}
+
+	if immutable {
+		for _, ng := range live {
+			if base := baseNodeGroupName(ng.Name); base != ng.Name {
+				if _, ok := liveByName[base]; !ok {
+					liveByName[base] = ng
+				}
+			}
+		}
+	}
+
+	return liveByName
+}
+
+var nodeGroupCapacityKeys = []string{"desiredCapacity", "minSize", "maxSize"}
+
+// nodeGroupDrift returns the nodegroups in spec that have been deleted out-of-band, and the capacities changed out-of-band.
+//
+// Capacities are compared only when they're specified in spec, so that eksctl's defaults don't show up as a drift.
+// Live nodegroups missing in spec aren't reported, as they may be managed by eksctl_nodegroup.
+func nodeGroupDrift(spec string, liveByName map[string]LiveNodeGroup) ([]string, error) {
+	var c EksctlClusterConfig
+
+	if err := yaml.Unmarshal([]byte(spec), &c); err != nil {
+		return nil, err
+	}
+
+	capacities := nodeGroupCapacities(&c)
+
+	drift := []string{}
+
+	for _, name := range nodeGroupNames(&c) {
+		l, ok := liveByName[name]
+		if !ok {
+			drift = append(drift, fmt.Sprintf("%s: %s", name, nodeGroupDeletedOutOfBand))
+
+			continue
+		}
+
+		live := map[string]int{
+			"desiredCapacity": l.DesiredCapacity,
+			"minSize":         l.MinSize,
+			"maxSize":         l.MaxSize,
+		}
+
+		for _, k := range nodeGroupCapacityKeys {
+			v, ok := capacities[name][k]
+			if !ok {
+				continue
+			}
+
+			if liveValue := strconv.Itoa(live[k]); v != liveValue {
+				drift = append(drift, fmt.Sprintf("%s: %s changed out-of-band from %s to %s", name, k, v, liveValue))
+			}
+		}
+	}
+
+	return drift, nil
+}
+
+const nodeGroupDeletedOutOfBand = "deleted out-of-band"
+
+// driftedNodeGroups returns the names of the nodegroups deleted out-of-band and the ones scaled out-of-band,
+// recorded in node_group_drift by the last refresh
+func driftedNodeGroups(d ChangeGetter) ([]string, []string) {
+	// The new value is always empty, as the update reverts the drift
+	v, _ := d.GetChange(KeyNodeGroupDrift)
+
+	drift, _ := v.([]interface{})
+
+	var deleted, scaled []string
+
+	for _, item := range drift {
+		s, _ := item.(string)
+
+		parts := strings.SplitN(s, ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		if parts[1] == nodeGroupDeletedOutOfBand {
+			deleted = appendIfMissing(deleted, parts[0])
+		} else {
+			scaled = appendIfMissing(scaled, parts[0])
+		}
+	}
+
+	return deleted, scaled
+}
+
+func appendIfMissing(names []string, name string) []string {
+	if containsString(names, name) {
+		return names
+	}
+
+	return append(names, name)
+}
+
+// planNodeGroupDriftRevert clears node_group_drift when the last refresh found any drift,
+// which triggers an update to recreate and scale the drifted nodegroups as declared in spec.
+func planNodeGroupDriftRevert(d *schema.ResourceDiff) error {
+	if drift, _ := d.Get(KeyNodeGroupDrift).([]interface{}); len(drift) == 0 {
+		return nil
+	}
+
+	return d.SetNew(KeyNodeGroupDrift, []interface{}{})
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+
+	return nil
+}
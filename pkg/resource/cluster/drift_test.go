@@ -0,0 +1,85 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNodeGroupDrift(t *testing.T) {
+	spec := `# my cluster
+nodeGroups:
+- name: ng1
+  instanceType: m5.large
+  desiredCapacity: 1
+- name: ng2
+  instanceType: m5.large
+managedNodeGroups:
+- name: mng1
+  minSize: 1
+  maxSize: 3
+`
+
+	testcases := []struct {
+		name string
+		live []LiveNodeGroup
+		want []string
+	}{
+		{
+			name: "no drift",
+			live: []LiveNodeGroup{
+				{Name: "ng1", DesiredCapacity: 1, MinSize: 1, MaxSize: 1},
+				{Name: "ng2", DesiredCapacity: 2, MinSize: 2, MaxSize: 2},
+				{Name: "mng1", DesiredCapacity: 2, MinSize: 1, MaxSize: 3},
+				{Name: "created-by-eksctl-nodegroup", DesiredCapacity: 1, MinSize: 1, MaxSize: 1},
+			},
+			want: []string{},
+		},
+		{
+			name: "scaled and deleted",
+			live: []LiveNodeGroup{
+				{Name: "ng1", DesiredCapacity: 3, MinSize: 1, MaxSize: 3},
+				{Name: "mng1", DesiredCapacity: 2, MinSize: 1, MaxSize: 5},
+			},
+			want: []string{
+				"mng1: maxSize changed out-of-band from 3 to 5",
+				"ng1: desiredCapacity changed out-of-band from 1 to 3",
+				"ng2: deleted out-of-band",
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := nodeGroupDrift(spec, indexLiveNodeGroups(tc.live, false))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if d := cmp.Diff(tc.want, got); d != "" {
+				t.Errorf("unexpected drift: want (-), got (+)\n%s", d)
+			}
+		})
+	}
+}
+
+func TestDriftedNodeGroups(t *testing.T) {
+	d := newFakeChangeGetter(
+		map[string]interface{}{KeyNodeGroupDrift: []interface{}{
+			"mng1: maxSize changed out-of-band from 3 to 5",
+			"mng1: minSize changed out-of-band from 1 to 2",
+			"ng2: deleted out-of-band",
+		}},
+		map[string]interface{}{KeyNodeGroupDrift: []interface{}{}},
+	)
+
+	deleted, scaled := driftedNodeGroups(d)
+
+	if diff := cmp.Diff([]string{"ng2"}, deleted); diff != "" {
+		t.Errorf("unexpected deleted nodegroups: want (-), got (+)\n%s", diff)
+	}
+
+	if diff := cmp.Diff([]string{"mng1"}, scaled); diff != "" {
+		t.Errorf("unexpected scaled nodegroups: want (-), got (+)\n%s", diff)
+	}
+}
@@ -0,0 +1,65 @@
+package cluster
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+)
+
+// fargateProfiles returns the fargateProfiles entries in the cluster.yaml keyed by their names
+func fargateProfiles(c *EksctlClusterConfig) map[string]interface{} {
+	return itemsByName(c.Rest["fargateProfiles"])
+}
+
+// recreatedFargateProfiles returns the names of existing fargate profiles whose selectors or any other settings have changed.
+// EKS doesn't support updating fargate profiles, so they need to be deleted and created again.
+func recreatedFargateProfiles(old, new *EksctlClusterConfig) []string {
+	oldProfiles := fargateProfiles(old)
+
+	var names []string
+
+	for name, p := range fargateProfiles(new) {
+		if o, ok := oldProfiles[name]; ok && !reflect.DeepEqual(o, p) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// listFargateProfiles returns the names of the fargate profiles that exist in the cluster
+func listFargateProfiles(ctx *sdk.Context, clusterName string) (map[string]bool, error) {
+	svc := eks.New(ctx.Session())
+
+	names := map[string]bool{}
+
+	var nextToken *string
+
+	for {
+		res, err := svc.ListFargateProfiles(&eks.ListFargateProfilesInput{
+			ClusterName: aws.String(clusterName),
+			NextToken:   nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("listing fargate profiles: %w", err)
+		}
+
+		for _, n := range res.FargateProfileNames {
+			names[aws.StringValue(n)] = true
+		}
+
+		nextToken = res.NextToken
+
+		if nextToken == nil {
+			break
+		}
+	}
+
+	return names, nil
+}
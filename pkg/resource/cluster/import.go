@@ -1,13 +1,15 @@
 package cluster
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"log"
 	"strings"
 )
 
-func (m *Manager) importCluster(d *schema.ResourceData) (*schema.ResourceData, error) {
+func (m *Manager) importCluster(runCtx context.Context, d *schema.ResourceData) (*schema.ResourceData, error) {
 	clusterName := d.Id()
 
 	d.Set(KeyName, clusterName)
@@ -15,9 +17,13 @@ func (m *Manager) importCluster(d *schema.ResourceData) (*schema.ResourceData, e
 
 	d.SetId(newClusterID())
 
-	getCluster, err := newEksctlCommandFromResourceWithRegionAndProfile(d, "get", "cluster", "-o", "json", "--name", clusterName)
+	out, notFound, err := runEksctlGet(mustNewContextFromResource(runCtx, d), d, "cluster", "-o", "json", "--name", clusterName)
 	if err != nil {
-		return nil, fmt.Errorf("getting cluster %s:: %w", clusterName, err)
+		return nil, fmt.Errorf("getting cluster %s: %w", clusterName, err)
+	}
+
+	if notFound {
+		return nil, fmt.Errorf("found no cluster named %s", clusterName)
 	}
 
 	type resourceVpcConfig struct {
@@ -33,10 +39,8 @@ func (m *Manager) importCluster(d *schema.ResourceData) (*schema.ResourceData, e
 
 	var clusters []cluster
 
-	if getClusterOut, err := getCluster.CombinedOutput(); err != nil {
-		return nil, fmt.Errorf("failed running %s %s: %vw: COMBINED OUTPUT:\n%s", getCluster.Path, strings.Join(getCluster.Args, " "), err, string(getClusterOut))
-	} else if err := json.Unmarshal(getClusterOut, &clusters); err != nil {
-		return nil, fmt.Errorf("parsing json: %w: INPUT:\n%s", err, string(getClusterOut))
+	if err := json.Unmarshal([]byte(out), &clusters); err != nil {
+		return nil, fmt.Errorf("parsing json: %w: INPUT:\n%s", err, out)
 	}
 
 	var found *cluster
@@ -72,5 +76,36 @@ func (m *Manager) importCluster(d *schema.ResourceData) (*schema.ResourceData, e
 	d.Set(KeyRegion, region)
 	d.Set(KeyVersion, found.Version)
 
+	a, err := ReadCluster(d)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := mustNewContext(runCtx, a)
+
+	spec, vpcID, err := importSpec(ctx, d, AWSSessionFromCluster(a), clusterName)
+	if err != nil {
+		return nil, fmt.Errorf("reconstructing spec of cluster %s: %w", clusterName, err)
+	}
+
+	specStr, err := spec.String()
+	if err != nil {
+		return nil, fmt.Errorf("marshalling spec of cluster %s: %w", clusterName, err)
+	}
+
+	log.Printf("[DEBUG] reconstructed spec of cluster %s:\n%s", clusterName, specStr)
+
+	d.Set(KeySpec, specStr)
+	d.Set(KeyVPCID, vpcID)
+
+	mappings, err := runGetIAMIdentityMapping(ctx, d, clusterName)
+	if err != nil {
+		return nil, fmt.Errorf("reading iamidentitymapping of cluster %s: %w", clusterName, err)
+	}
+
+	if err := d.Set(KeyIAMIdentityMapping, importIAMIdentityMappings(mappings)); err != nil {
+		return nil, fmt.Errorf("setting %s: %w", KeyIAMIdentityMapping, err)
+	}
+
 	return d, nil
 }
@@ -0,0 +1,399 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/api"
+	"gopkg.in/yaml.v3"
+)
+
+// importedSpec is the cluster.yaml reconstructed from a live cluster.
+// Fields are ordered as they're usually written in cluster.yaml.
+type importedSpec struct {
+	VPC               *importedVPC             `yaml:"vpc,omitempty"`
+	IAM               importedIAM              `yaml:"iam"`
+	NodeGroups        []importedNodeGroup      `yaml:"nodeGroups,omitempty"`
+	ManagedNodeGroups []importedNodeGroup      `yaml:"managedNodeGroups,omitempty"`
+	FargateProfiles   []importedFargateProfile `yaml:"fargateProfiles,omitempty"`
+}
+
+type importedVPC struct {
+	CIDR    string           `yaml:"cidr,omitempty"`
+	Subnets *importedSubnets `yaml:"subnets,omitempty"`
+}
+
+type importedSubnets struct {
+	Public  map[string]Subnet `yaml:"public,omitempty"`
+	Private map[string]Subnet `yaml:"private,omitempty"`
+}
+
+type importedIAM struct {
+	WithOIDC        bool                     `yaml:"withOIDC"`
+	ServiceAccounts []importedServiceAccount `yaml:"serviceAccounts,omitempty"`
+}
+
+type importedServiceAccount struct {
+	Metadata struct {
+		Name      string `yaml:"name" json:"name"`
+		Namespace string `yaml:"namespace,omitempty" json:"namespace"`
+	} `yaml:"metadata" json:"metadata"`
+	AttachPolicyARNs []string `yaml:"attachPolicyARNs,omitempty" json:"attachPolicyARNs"`
+	Status           *struct {
+		RoleARN string `json:"roleARN"`
+	} `yaml:"-" json:"status"`
+}
+
+type importedNodeGroup struct {
+	Name            string            `yaml:"name"`
+	InstanceType    string            `yaml:"instanceType,omitempty"`
+	InstanceTypes   []string          `yaml:"instanceTypes,omitempty"`
+	DesiredCapacity *int              `yaml:"desiredCapacity,omitempty"`
+	MinSize         *int              `yaml:"minSize,omitempty"`
+	MaxSize         *int              `yaml:"maxSize,omitempty"`
+	VolumeSize      *int              `yaml:"volumeSize,omitempty"`
+	Labels          map[string]string `yaml:"labels,omitempty"`
+}
+
+type importedFargateProfile struct {
+	Name                string                    `yaml:"name"`
+	PodExecutionRoleARN string                    `yaml:"podExecutionRoleARN,omitempty"`
+	Selectors           []importedFargateSelector `yaml:"selectors"`
+	Subnets             []string                  `yaml:"subnets,omitempty"`
+}
+
+type importedFargateSelector struct {
+	Namespace string            `yaml:"namespace"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+func (s importedSpec) String() (string, error) {
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(s); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// importSpec discovers nodegroups, managed nodegroups, fargate profiles, IAM service accounts, subnets and OIDC settings
+// of the live cluster via eksctl and the EKS, EC2, IAM and CloudFormation APIs, and reconstructs cluster.yaml from them.
+//
+// It returns the ID of the VPC the cluster has been created in, or an empty string when the VPC is managed by eksctl,
+// so that the VPC is recreated along with the cluster on replacement.
+func importSpec(ctx *sdk.Context, d api.Getter, sess *session.Session, clusterName string) (*importedSpec, string, error) {
+	eksSvc := eks.New(sess)
+
+	out, err := eksSvc.DescribeCluster(&eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err != nil {
+		return nil, "", fmt.Errorf("describing cluster %s: %w", clusterName, err)
+	}
+
+	c := out.Cluster
+
+	spec := &importedSpec{}
+
+	vpcID := aws.StringValue(c.ResourcesVpcConfig.VpcId)
+
+	eksctlManagedVPC, err := isEksctlManagedVPC(cloudformation.New(sess), clusterName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ec2Svc := ec2.New(sess)
+
+	if eksctlManagedVPC {
+		vpcs, err := ec2Svc.DescribeVpcs(&ec2.DescribeVpcsInput{VpcIds: []*string{c.ResourcesVpcConfig.VpcId}})
+		if err != nil {
+			return nil, "", fmt.Errorf("describing vpc %s: %w", vpcID, err)
+		}
+
+		if len(vpcs.Vpcs) > 0 {
+			spec.VPC = &importedVPC{CIDR: aws.StringValue(vpcs.Vpcs[0].CidrBlock)}
+		}
+
+		vpcID = ""
+	} else {
+		subnets, err := importSubnets(ec2Svc, c.ResourcesVpcConfig.SubnetIds)
+		if err != nil {
+			return nil, "", err
+		}
+
+		spec.VPC = &importedVPC{Subnets: subnets}
+	}
+
+	if c.Identity != nil && c.Identity.Oidc != nil && c.Identity.Oidc.Issuer != nil {
+		spec.IAM.WithOIDC, err = hasOIDCProvider(iam.New(sess), aws.StringValue(c.Identity.Oidc.Issuer))
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if spec.IAM.WithOIDC {
+		spec.IAM.ServiceAccounts, err = importServiceAccounts(ctx, d, iam.New(sess), clusterName)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	managed := map[string]bool{}
+
+	err = eksSvc.ListNodegroupsPages(&eks.ListNodegroupsInput{ClusterName: aws.String(clusterName)}, func(page *eks.ListNodegroupsOutput, lastPage bool) bool {
+		for _, n := range page.Nodegroups {
+			managed[aws.StringValue(n)] = true
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("listing managed nodegroups: %w", err)
+	}
+
+	for name := range managed {
+		ng, err := eksSvc.DescribeNodegroup(&eks.DescribeNodegroupInput{ClusterName: aws.String(clusterName), NodegroupName: aws.String(name)})
+		if err != nil {
+			return nil, "", fmt.Errorf("describing managed nodegroup %s: %w", name, err)
+		}
+
+		spec.ManagedNodeGroups = append(spec.ManagedNodeGroups, importedManagedNodeGroup(ng.Nodegroup))
+	}
+
+	liveNodeGroups, err := getLiveNodeGroups(ctx, d, clusterName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, l := range liveNodeGroups {
+		if managed[l.Name] {
+			continue
+		}
+
+		desired, min, max := l.DesiredCapacity, l.MinSize, l.MaxSize
+
+		spec.NodeGroups = append(spec.NodeGroups, importedNodeGroup{
+			Name:            l.Name,
+			InstanceType:    l.InstanceType,
+			DesiredCapacity: &desired,
+			MinSize:         &min,
+			MaxSize:         &max,
+		})
+	}
+
+	err = eksSvc.ListFargateProfilesPages(&eks.ListFargateProfilesInput{ClusterName: aws.String(clusterName)}, func(page *eks.ListFargateProfilesOutput, lastPage bool) bool {
+		for _, n := range page.FargateProfileNames {
+			spec.FargateProfiles = append(spec.FargateProfiles, importedFargateProfile{Name: aws.StringValue(n)})
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("listing fargate profiles: %w", err)
+	}
+
+	for i := range spec.FargateProfiles {
+		p := &spec.FargateProfiles[i]
+
+		fp, err := eksSvc.DescribeFargateProfile(&eks.DescribeFargateProfileInput{ClusterName: aws.String(clusterName), FargateProfileName: aws.String(p.Name)})
+		if err != nil {
+			return nil, "", fmt.Errorf("describing fargate profile %s: %w", p.Name, err)
+		}
+
+		p.PodExecutionRoleARN = aws.StringValue(fp.FargateProfile.PodExecutionRoleArn)
+		p.Subnets = aws.StringValueSlice(fp.FargateProfile.Subnets)
+
+		for _, s := range fp.FargateProfile.Selectors {
+			p.Selectors = append(p.Selectors, importedFargateSelector{
+				Namespace: aws.StringValue(s.Namespace),
+				Labels:    aws.StringValueMap(s.Labels),
+			})
+		}
+	}
+
+	spec.sort()
+
+	return spec, vpcID, nil
+}
+
+func (s *importedSpec) sort() {
+	sort.Slice(s.NodeGroups, func(i, j int) bool { return s.NodeGroups[i].Name < s.NodeGroups[j].Name })
+	sort.Slice(s.ManagedNodeGroups, func(i, j int) bool { return s.ManagedNodeGroups[i].Name < s.ManagedNodeGroups[j].Name })
+	sort.Slice(s.FargateProfiles, func(i, j int) bool { return s.FargateProfiles[i].Name < s.FargateProfiles[j].Name })
+	sort.Slice(s.IAM.ServiceAccounts, func(i, j int) bool {
+		a, b := s.IAM.ServiceAccounts[i].Metadata, s.IAM.ServiceAccounts[j].Metadata
+
+		return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+	})
+}
+
+func importedManagedNodeGroup(ng *eks.Nodegroup) importedNodeGroup {
+	r := importedNodeGroup{
+		Name:          aws.StringValue(ng.NodegroupName),
+		InstanceTypes: aws.StringValueSlice(ng.InstanceTypes),
+		Labels:        aws.StringValueMap(ng.Labels),
+	}
+
+	if sc := ng.ScalingConfig; sc != nil {
+		desired, min, max := int(aws.Int64Value(sc.DesiredSize)), int(aws.Int64Value(sc.MinSize)), int(aws.Int64Value(sc.MaxSize))
+
+		r.DesiredCapacity, r.MinSize, r.MaxSize = &desired, &min, &max
+	}
+
+	if ng.DiskSize != nil {
+		size := int(*ng.DiskSize)
+
+		r.VolumeSize = &size
+	}
+
+	// eksctl accepts either instanceType or instanceTypes for managed nodegroups
+	if len(r.InstanceTypes) == 1 {
+		r.InstanceType, r.InstanceTypes = r.InstanceTypes[0], nil
+	}
+
+	return r
+}
+
+// isEksctlManagedVPC returns true when the VPC has been created by eksctl along with the cluster
+func isEksctlManagedVPC(svc *cloudformation.CloudFormation, clusterName string) (bool, error) {
+	stackName := fmt.Sprintf("eksctl-%s-cluster", clusterName)
+
+	out, err := svc.DescribeStackResources(&cloudformation.DescribeStackResourcesInput{StackName: aws.String(stackName)})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "ValidationError" {
+			log.Printf("[DEBUG] stack %s not found. Assuming the cluster hasn't been created by eksctl", stackName)
+
+			return false, nil
+		}
+
+		return false, fmt.Errorf("describing resources of stack %s: %w", stackName, err)
+	}
+
+	for _, r := range out.StackResources {
+		if aws.StringValue(r.ResourceType) == "AWS::EC2::VPC" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// importSubnets classifies the cluster subnets into public and private ones, keyed by availability zones as eksctl does.
+func importSubnets(svc *ec2.EC2, subnetIDs []*string) (*importedSubnets, error) {
+	out, err := svc.DescribeSubnets(&ec2.DescribeSubnetsInput{SubnetIds: subnetIDs})
+	if err != nil {
+		return nil, fmt.Errorf("describing subnets: %w", err)
+	}
+
+	subnets := &importedSubnets{
+		Public:  map[string]Subnet{},
+		Private: map[string]Subnet{},
+	}
+
+	for _, s := range out.Subnets {
+		public := aws.BoolValue(s.MapPublicIpOnLaunch)
+
+		for _, t := range s.Tags {
+			switch aws.StringValue(t.Key) {
+			case "kubernetes.io/role/elb":
+				public = true
+			case "kubernetes.io/role/internal-elb":
+				public = false
+			}
+		}
+
+		m := subnets.Private
+		if public {
+			m = subnets.Public
+		}
+
+		id := aws.StringValue(s.SubnetId)
+
+		// eksctl accepts any name for the subnet when there are two or more subnets in an AZ
+		key := aws.StringValue(s.AvailabilityZone)
+		if _, ok := m[key]; ok {
+			key = id
+		}
+
+		m[key] = Subnet{ID: id}
+	}
+
+	return subnets, nil
+}
+
+func hasOIDCProvider(svc *iam.IAM, issuer string) (bool, error) {
+	out, err := svc.ListOpenIDConnectProviders(&iam.ListOpenIDConnectProvidersInput{})
+	if err != nil {
+		return false, fmt.Errorf("listing oidc providers: %w", err)
+	}
+
+	suffix := ":oidc-provider/" + strings.TrimPrefix(issuer, "https://")
+
+	for _, p := range out.OpenIDConnectProviderList {
+		if strings.HasSuffix(aws.StringValue(p.Arn), suffix) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func importServiceAccounts(ctx *sdk.Context, d api.Getter, svc *iam.IAM, clusterName string) ([]importedServiceAccount, error) {
+	out, notFound, err := runEksctlGet(ctx, d, "iamserviceaccount", "--cluster", clusterName, "-o", "json")
+	if err != nil {
+		return nil, err
+	} else if notFound || strings.TrimSpace(out) == "" {
+		return nil, nil
+	}
+
+	var sas []importedServiceAccount
+
+	if err := json.Unmarshal([]byte(out), &sas); err != nil {
+		return nil, fmt.Errorf("parsing get-iamserviceaccount output as json: %w", err)
+	}
+
+	for i := range sas {
+		sa := &sas[i]
+
+		if len(sa.AttachPolicyARNs) > 0 || sa.Status == nil || sa.Status.RoleARN == "" {
+			continue
+		}
+
+		roleName := sa.Status.RoleARN[strings.LastIndex(sa.Status.RoleARN, "/")+1:]
+
+		err := svc.ListAttachedRolePoliciesPages(&iam.ListAttachedRolePoliciesInput{RoleName: aws.String(roleName)}, func(page *iam.ListAttachedRolePoliciesOutput, lastPage bool) bool {
+			for _, p := range page.AttachedPolicies {
+				sa.AttachPolicyARNs = append(sa.AttachPolicyARNs, aws.StringValue(p.PolicyArn))
+			}
+
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("listing policies attached to role %s: %w", roleName, err)
+		}
+	}
+
+	return sas, nil
+}
+
+// nodeRoleGroups are the Kubernetes groups aws-auth maps nodegroup and fargate roles to.
+// Such mappings are maintained by eksctl and must not be managed via iam_identity_mapping.
+var nodeRoleGroups = []string{"system:bootstrappers", "system:nodes", "system:node-proxier"}
+
+func importIAMIdentityMappings(mappings []map[string]interface{}) []interface{} {
+	return observedIAMIdentityMappings(mappings, nil, true)
+}
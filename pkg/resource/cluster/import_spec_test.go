@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestImportedSpec(t *testing.T) {
+	one, three := 1, 3
+
+	spec := importedSpec{
+		VPC: &importedVPC{
+			Subnets: &importedSubnets{
+				Public:  map[string]Subnet{"us-east-2a": {ID: "subnet-1"}},
+				Private: map[string]Subnet{"us-east-2a": {ID: "subnet-2"}},
+			},
+		},
+		IAM: importedIAM{WithOIDC: true},
+		NodeGroups: []importedNodeGroup{
+			{Name: "ng1", InstanceType: "m5.large", DesiredCapacity: &one, MinSize: &one, MaxSize: &three},
+		},
+		ManagedNodeGroups: []importedNodeGroup{
+			importedManagedNodeGroup(&eks.Nodegroup{
+				NodegroupName: aws.String("mng1"),
+				InstanceTypes: aws.StringSlice([]string{"t3.medium"}),
+				ScalingConfig: &eks.NodegroupScalingConfig{DesiredSize: aws.Int64(1), MinSize: aws.Int64(1), MaxSize: aws.Int64(3)},
+				DiskSize:      aws.Int64(20),
+			}),
+		},
+		FargateProfiles: []importedFargateProfile{
+			{Name: "fp1", Selectors: []importedFargateSelector{{Namespace: "default"}}},
+		},
+	}
+
+	sa := importedServiceAccount{}
+	sa.Metadata.Name = "foo"
+	sa.Metadata.Namespace = "kube-system"
+	sa.AttachPolicyARNs = []string{"arn:aws:iam::aws:policy/AmazonS3ReadOnlyAccess"}
+
+	spec.IAM.ServiceAccounts = append(spec.IAM.ServiceAccounts, sa)
+
+	got, err := spec.String()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `vpc:
+  subnets:
+    public:
+      us-east-2a:
+        id: subnet-1
+    private:
+      us-east-2a:
+        id: subnet-2
+iam:
+  withOIDC: true
+  serviceAccounts:
+    - metadata:
+        name: foo
+        namespace: kube-system
+      attachPolicyARNs:
+        - arn:aws:iam::aws:policy/AmazonS3ReadOnlyAccess
+nodeGroups:
+  - name: ng1
+    instanceType: m5.large
+    desiredCapacity: 1
+    minSize: 1
+    maxSize: 3
+managedNodeGroups:
+  - name: mng1
+    instanceType: t3.medium
+    desiredCapacity: 1
+    minSize: 1
+    maxSize: 3
+    volumeSize: 20
+fargateProfiles:
+  - name: fp1
+    selectors:
+      - namespace: default
+`
+
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("unexpected spec: want (-), got (+)\n%s", d)
+	}
+}
+
+func TestImportIAMIdentityMappings(t *testing.T) {
+	mappings := []map[string]interface{}{
+		{"iamarn": "arn:aws:iam::123456789012:role/admin", "username": "admin", "groups": []interface{}{"system:masters"}},
+		{"iamarn": "arn:aws:iam::123456789012:role/eksctl-ng1-NodeInstanceRole", "username": "system:node:{{EC2PrivateDNSName}}", "groups": []interface{}{"system:bootstrappers", "system:nodes"}},
+		{"account": "123456789012"},
+	}
+
+	want := []interface{}{
+		map[string]interface{}{"iamarn": "arn:aws:iam::123456789012:role/admin", "account": "", "username": "admin", "groups": []interface{}{"system:masters"}},
+		map[string]interface{}{"iamarn": "", "account": "123456789012", "username": "", "groups": []interface{}{}},
+	}
+
+	if d := cmp.Diff(want, importIAMIdentityMappings(mappings)); d != "" {
+		t.Errorf("unexpected mappings: want (-), got (+)\n%s", d)
+	}
+}
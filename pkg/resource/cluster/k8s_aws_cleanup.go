@@ -0,0 +1,646 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+)
+
+// KeyCleanupAWSResourcesBeforeDestroy enables deleting the AWS resources created by controllers running in the cluster,
+// like load balancers, security groups, network interfaces and EBS volumes, before the cluster is destroyed.
+const KeyCleanupAWSResourcesBeforeDestroy = "cleanup_aws_resources_before_destroy"
+
+const (
+	// TagKeyELBv2Cluster is the tag the AWS Load Balancer Controller adds to the resources it creates, whose value is the cluster name
+	TagKeyELBv2Cluster = "elbv2.k8s.aws/cluster"
+
+	// TagKeyVPCCNICluster is the tag the Amazon VPC CNI plugin adds to the network interfaces it creates, whose value is the cluster name
+	TagKeyVPCCNICluster = "cluster.k8s.amazonaws.com/name"
+
+	tagKeyKubernetesClusterPrefix = "kubernetes.io/cluster/"
+	tagKeyCloudFormationStackName = "aws:cloudformation:stack-name"
+	tagKeyEKSClusterName          = "aws:eks:cluster-name"
+)
+
+var (
+	awsResourceCleanupTimeout      = 10 * time.Minute
+	awsResourceCleanupPollInterval = 10 * time.Second
+)
+
+// isKubernetesCreated returns true if the tags mark the load balancer or target group as created from the cluster by a Kubernetes controller.
+// Resources managed by CloudFormation stacks or EKS are excluded even though they are also tagged `kubernetes.io/cluster/<name>`,
+// as they're deleted along with the cluster.
+func isKubernetesCreated(tags map[string]string, clusterName string) bool {
+	if isManagedByStackOrEKS(tags) {
+		return false
+	}
+
+	return tags[tagKeyKubernetesClusterPrefix+clusterName] == "owned" || tags[TagKeyELBv2Cluster] == clusterName
+}
+
+// isKubernetesCreatedSecurityGroup returns true if the security group was created from the cluster by a Kubernetes controller.
+// Security groups tagged `kubernetes.io/cluster/<name>` by users, like the ones for nodes, are excluded unless they're named
+// `k8s-elb-*` by the in-tree service controller.
+func isKubernetesCreatedSecurityGroup(groupName string, tags map[string]string, clusterName string) bool {
+	if isManagedByStackOrEKS(tags) {
+		return false
+	}
+
+	if tags[TagKeyELBv2Cluster] == clusterName {
+		return true
+	}
+
+	return strings.HasPrefix(groupName, "k8s-elb-") && tags[tagKeyKubernetesClusterPrefix+clusterName] == "owned"
+}
+
+// isVPCCNICreated returns true if the network interface was created for the cluster by the Amazon VPC CNI plugin
+func isVPCCNICreated(tags map[string]string, clusterName string) bool {
+	return !isManagedByStackOrEKS(tags) && tags[TagKeyVPCCNICluster] == clusterName
+}
+
+func isManagedByStackOrEKS(tags map[string]string) bool {
+	if _, ok := tags[tagKeyCloudFormationStackName]; ok {
+		return true
+	}
+
+	_, ok := tags[tagKeyEKSClusterName]
+
+	return ok
+}
+
+// cleanupAWSResourcesBeforeDestroy deletes the Kubernetes objects backed by AWS resources,
+// waits for the controllers to delete the AWS resources, and then deletes the leftovers.
+// It's opt-in, as it deletes all the ingresses, services of type LoadBalancer, and dynamically provisioned EBS volumes in the cluster.
+func cleanupAWSResourcesBeforeDestroy(ctx *sdk.Context, set *ClusterSet) error {
+	cluster := set.Cluster
+
+	if !cluster.CleanupAWSResourcesBeforeDestroy {
+		return nil
+	}
+
+	clusterName := string(set.ClusterName)
+
+	kubeconfigPath, err := doWriteTempKubeconfig(ctx, cluster, clusterName)
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(kubeconfigPath)
+
+	deletedVolumes, err := deleteKubernetesObjectsBackedByAWS(ctx, cluster, kubeconfigPath)
+	if err != nil {
+		return err
+	}
+
+	sess := ctx.Session()
+
+	s := &awsResourceSweeper{
+		clusterName:    clusterName,
+		ec2:            ec2.New(sess),
+		elb:            elb.New(sess),
+		elbv2:          elbv2.New(sess),
+		deletedVolumes: deletedVolumes,
+		runCtx:         ctx.Ctx,
+	}
+
+	if err := s.waitUntilDeleted("load balancers and volumes deleted by Kubernetes controllers", func() ([]string, error) {
+		lbs, err := s.loadBalancers()
+		if err != nil {
+			return nil, err
+		}
+
+		vols, err := s.existingVolumes(deletedVolumes)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(lbs.names(), vols...), nil
+	}); err != nil {
+		return err
+	}
+
+	return s.sweep()
+}
+
+// deleteKubernetesObjectsBackedByAWS deletes all the ingresses and services of type LoadBalancer,
+// and the persistent volume claims of the EBS volumes whose reclaim policy is Delete.
+// It returns the IDs of the EBS volumes deleted by the claim deletions.
+func deleteKubernetesObjectsBackedByAWS(ctx *sdk.Context, cluster *Cluster, kubeconfigPath string) ([]string, error) {
+	// Objects deleted concurrently, like by their controllers, are ignored by `--ignore-not-found` on deletion
+	kubectl := func(args ...string) (string, error) {
+		res, err := ctx.Run(newKubectlCommand(cluster, kubeconfigPath, args...))
+		if err != nil {
+			return "", fmt.Errorf("running kubectl %s: %w", strings.Join(args, " "), err)
+		}
+
+		return res.Output, nil
+	}
+
+	// Ingresses are deleted first, as the AWS Load Balancer Controller needs the services to delete the load balancers for ingresses
+	if _, err := kubectl("delete", "ingresses", "--all", "--all-namespaces", "--ignore-not-found", "--wait=false"); err != nil {
+		return nil, err
+	}
+
+	out, err := kubectl("get", "services", "--all-namespaces", "-o", loadBalancerServicesJSONPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, svc := range parseLoadBalancerServices(out) {
+		if _, err := kubectl("delete", "service", "-n", svc.Namespace, svc.Name, "--ignore-not-found", "--wait=false"); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err = kubectl("get", "persistentvolumes", "-o", persistentVolumesJSONPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []string
+
+	for _, pv := range parseEBSPersistentVolumes(out) {
+		if pv.ReclaimPolicy != "Delete" || pv.ClaimName == "" {
+			continue
+		}
+
+		// The volume ID of in-tree EBS volumes are in the form of `aws://<az>/<id>`
+		id := path.Base(pv.VolumeID)
+
+		if _, err := kubectl("delete", "persistentvolumeclaim", "-n", pv.ClaimNamespace, pv.ClaimName, "--ignore-not-found", "--wait=false"); err != nil {
+			return nil, err
+		}
+
+		deleted = append(deleted, id)
+	}
+
+	return deleted, nil
+}
+
+// awsResourceSweeper deletes the AWS resources created from the cluster by Kubernetes controllers
+type awsResourceSweeper struct {
+	clusterName string
+
+	ec2   ec2iface.EC2API
+	elb   elbiface.ELBAPI
+	elbv2 elbv2iface.ELBV2API
+
+	// deletedVolumes are the IDs of EBS volumes whose claims have been deleted by this destroy.
+	// Other volumes are never deleted, as they may hold data retained on purpose, like the ones of deleted Retain persistent volumes.
+	deletedVolumes []string
+
+	// runCtx stops the waits for the resources to be deleted when the destroy is interrupted
+	runCtx context.Context
+}
+
+// kubernetesLoadBalancers are the names of classic load balancers and the ARNs of the other load balancers
+type kubernetesLoadBalancers struct {
+	classic []string
+	v2      []string
+}
+
+func (l kubernetesLoadBalancers) names() []string {
+	return append(append([]string{}, l.classic...), l.v2...)
+}
+
+// waitUntilDeleted waits until the function returns no resources, or the timeout.
+// The remaining resources are left to be deleted by the sweep.
+// It fails only when the destroy is interrupted while waiting.
+func (s *awsResourceSweeper) waitUntilDeleted(desc string, f func() ([]string, error)) error {
+	deadline := time.Now().Add(awsResourceCleanupTimeout)
+
+	for {
+		remaining, err := f()
+		if err != nil {
+			log.Printf("[WARN] failed waiting for %s: %v", desc, err)
+
+			return nil
+		}
+
+		if len(remaining) == 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			log.Printf("[WARN] timed out waiting for %s: %s", desc, strings.Join(remaining, ", "))
+
+			return nil
+		}
+
+		log.Printf("[DEBUG] waiting for %s: %s", desc, strings.Join(remaining, ", "))
+
+		if err := s.sleep(); err != nil {
+			return fmt.Errorf("waiting for %s: %w", desc, err)
+		}
+	}
+}
+
+// sleep waits for the poll interval, or fails when the destroy is interrupted
+func (s *awsResourceSweeper) sleep() error {
+	if s.runCtx == nil {
+		time.Sleep(awsResourceCleanupPollInterval)
+
+		return nil
+	}
+
+	select {
+	case <-s.runCtx.Done():
+		return fmt.Errorf("interrupted: %w", s.runCtx.Err())
+	case <-time.After(awsResourceCleanupPollInterval):
+		return nil
+	}
+}
+
+// sweep deletes the leftover load balancers, target groups, network interfaces, security groups, and EBS volumes, in the order.
+// Failures don't stop the rest of the resources from being deleted, and are reported together.
+func (s *awsResourceSweeper) sweep() error {
+	var failures []string
+
+	fail := func(kind, id string, err error) {
+		failures = append(failures, fmt.Sprintf("%s %s: %v", kind, id, err))
+	}
+
+	lbs, err := s.loadBalancers()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range lbs.classic {
+		log.Printf("[DEBUG] deleting classic load balancer %s left behind by %s", name, s.clusterName)
+
+		if _, err := s.elb.DeleteLoadBalancer(&elb.DeleteLoadBalancerInput{LoadBalancerName: aws.String(name)}); err != nil {
+			fail("classic load balancer", name, err)
+		}
+	}
+
+	for _, arn := range lbs.v2 {
+		log.Printf("[DEBUG] deleting load balancer %s left behind by %s", arn, s.clusterName)
+
+		if _, err := s.elbv2.DeleteLoadBalancer(&elbv2.DeleteLoadBalancerInput{LoadBalancerArn: aws.String(arn)}); err != nil {
+			fail("load balancer", arn, err)
+		}
+	}
+
+	if len(lbs.classic)+len(lbs.v2) > 0 {
+		if err := s.waitUntilDeleted("load balancers", func() ([]string, error) {
+			lbs, err := s.loadBalancers()
+
+			return lbs.names(), err
+		}); err != nil {
+			return err
+		}
+	}
+
+	tgs, err := s.targetGroups()
+	if err != nil {
+		return err
+	}
+
+	for _, arn := range tgs {
+		log.Printf("[DEBUG] deleting target group %s left behind by %s", arn, s.clusterName)
+
+		if _, err := s.elbv2.DeleteTargetGroup(&elbv2.DeleteTargetGroupInput{TargetGroupArn: aws.String(arn)}); err != nil && !isTargetGroupNotFound(err) {
+			fail("target group", arn, err)
+		}
+	}
+
+	enis, err := s.networkInterfaces()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range enis {
+		log.Printf("[DEBUG] deleting network interface %s left behind by %s", id, s.clusterName)
+
+		if _, err := s.ec2.DeleteNetworkInterface(&ec2.DeleteNetworkInterfaceInput{NetworkInterfaceId: aws.String(id)}); err != nil {
+			fail("network interface", id, err)
+		}
+	}
+
+	sgs, err := s.securityGroups()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range sgs {
+		log.Printf("[DEBUG] deleting security group %s left behind by %s", id, s.clusterName)
+
+		if err := s.deleteSecurityGroup(id); err != nil {
+			fail("security group", id, err)
+		}
+	}
+
+	vols, err := s.volumes()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range vols {
+		log.Printf("[DEBUG] deleting EBS volume %s left behind by %s", id, s.clusterName)
+
+		if _, err := s.ec2.DeleteVolume(&ec2.DeleteVolumeInput{VolumeId: aws.String(id)}); err != nil {
+			fail("EBS volume", id, err)
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("deleting AWS resources created from cluster %s by Kubernetes failed. Delete them manually, or retry:\n%s",
+			s.clusterName, strings.Join(failures, "\n"))
+	}
+
+	return nil
+}
+
+func (s *awsResourceSweeper) loadBalancers() (kubernetesLoadBalancers, error) {
+	var lbs kubernetesLoadBalancers
+
+	var names []*string
+
+	if err := s.elb.DescribeLoadBalancersPages(&elb.DescribeLoadBalancersInput{}, func(res *elb.DescribeLoadBalancersOutput, _ bool) bool {
+		for _, lb := range res.LoadBalancerDescriptions {
+			names = append(names, lb.LoadBalancerName)
+		}
+
+		return true
+	}); err != nil {
+		return lbs, fmt.Errorf("describing classic load balancers: %w", err)
+	}
+
+	// DescribeTags accepts up to 20 load balancers at once
+	for _, batch := range batchStrings(names, 20) {
+		res, err := s.elb.DescribeTags(&elb.DescribeTagsInput{LoadBalancerNames: batch})
+		if err != nil {
+			return lbs, fmt.Errorf("describing tags of classic load balancers: %w", err)
+		}
+
+		for _, d := range res.TagDescriptions {
+			tags := map[string]string{}
+			for _, t := range d.Tags {
+				tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+			}
+
+			if isKubernetesCreated(tags, s.clusterName) {
+				lbs.classic = append(lbs.classic, aws.StringValue(d.LoadBalancerName))
+			}
+		}
+	}
+
+	var arns []*string
+
+	if err := s.elbv2.DescribeLoadBalancersPages(&elbv2.DescribeLoadBalancersInput{}, func(res *elbv2.DescribeLoadBalancersOutput, _ bool) bool {
+		for _, lb := range res.LoadBalancers {
+			arns = append(arns, lb.LoadBalancerArn)
+		}
+
+		return true
+	}); err != nil {
+		return lbs, fmt.Errorf("describing load balancers: %w", err)
+	}
+
+	v2, err := s.taggedELBv2Resources(arns)
+	if err != nil {
+		return lbs, fmt.Errorf("describing tags of load balancers: %w", err)
+	}
+
+	lbs.v2 = v2
+
+	return lbs, nil
+}
+
+func (s *awsResourceSweeper) targetGroups() ([]string, error) {
+	var arns []*string
+
+	if err := s.elbv2.DescribeTargetGroupsPages(&elbv2.DescribeTargetGroupsInput{}, func(res *elbv2.DescribeTargetGroupsOutput, _ bool) bool {
+		for _, tg := range res.TargetGroups {
+			arns = append(arns, tg.TargetGroupArn)
+		}
+
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing target groups: %w", err)
+	}
+
+	tgs, err := s.taggedELBv2Resources(arns)
+	if err != nil {
+		return nil, fmt.Errorf("describing tags of target groups: %w", err)
+	}
+
+	return tgs, nil
+}
+
+// taggedELBv2Resources returns the ARNs of the load balancers or target groups created from the cluster
+func (s *awsResourceSweeper) taggedELBv2Resources(arns []*string) ([]string, error) {
+	var r []string
+
+	// DescribeTags accepts up to 20 resources at once
+	for _, batch := range batchStrings(arns, 20) {
+		res, err := s.elbv2.DescribeTags(&elbv2.DescribeTagsInput{ResourceArns: batch})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range res.TagDescriptions {
+			tags := map[string]string{}
+			for _, t := range d.Tags {
+				tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+			}
+
+			if isKubernetesCreated(tags, s.clusterName) {
+				r = append(r, aws.StringValue(d.ResourceArn))
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// networkInterfaces returns the IDs of the detached network interfaces created from the cluster
+func (s *awsResourceSweeper) networkInterfaces() ([]string, error) {
+	var ids []string
+
+	if err := s.ec2.DescribeNetworkInterfacesPages(&ec2.DescribeNetworkInterfacesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("status"), Values: aws.StringSlice([]string{ec2.NetworkInterfaceStatusAvailable})}},
+	}, func(res *ec2.DescribeNetworkInterfacesOutput, _ bool) bool {
+		for _, eni := range res.NetworkInterfaces {
+			if isVPCCNICreated(ec2Tags(eni.TagSet), s.clusterName) {
+				ids = append(ids, aws.StringValue(eni.NetworkInterfaceId))
+			}
+		}
+
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing network interfaces: %w", err)
+	}
+
+	return ids, nil
+}
+
+func (s *awsResourceSweeper) securityGroups() ([]string, error) {
+	var ids []string
+
+	if err := s.ec2.DescribeSecurityGroupsPages(&ec2.DescribeSecurityGroupsInput{}, func(res *ec2.DescribeSecurityGroupsOutput, _ bool) bool {
+		for _, sg := range res.SecurityGroups {
+			if isKubernetesCreatedSecurityGroup(aws.StringValue(sg.GroupName), ec2Tags(sg.Tags), s.clusterName) {
+				ids = append(ids, aws.StringValue(sg.GroupId))
+			}
+		}
+
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing security groups: %w", err)
+	}
+
+	return ids, nil
+}
+
+// deleteSecurityGroup revokes the rules of other security groups referencing the security group, like the ones
+// added to the node security group by the AWS Load Balancer Controller, and then deletes it.
+// The deletion is retried until the network interfaces of the deleted load balancers using the security group are gone.
+func (s *awsResourceSweeper) deleteSecurityGroup(id string) error {
+	res, err := s.ec2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{{Name: aws.String("ip-permission.group-id"), Values: aws.StringSlice([]string{id})}},
+	})
+	if err != nil {
+		return fmt.Errorf("describing security groups referencing it: %w", err)
+	}
+
+	for _, sg := range res.SecurityGroups {
+		if perms := permissionsReferencing(sg.IpPermissions, id); len(perms) > 0 {
+			if _, err := s.ec2.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{
+				GroupId:       sg.GroupId,
+				IpPermissions: perms,
+			}); err != nil {
+				return fmt.Errorf("revoking ingress rules of security group %s referencing it: %w", aws.StringValue(sg.GroupId), err)
+			}
+		}
+	}
+
+	deadline := time.Now().Add(awsResourceCleanupTimeout)
+
+	for {
+		_, err := s.ec2.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{GroupId: aws.String(id)})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "DependencyViolation" && time.Now().Before(deadline) {
+			log.Printf("[DEBUG] retrying deletion of security group %s still in use: %v", id, err)
+
+			if err := s.sleep(); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		return err
+	}
+}
+
+// permissionsReferencing returns the parts of the rules that allow traffic from the security group
+func permissionsReferencing(perms []*ec2.IpPermission, groupID string) []*ec2.IpPermission {
+	var r []*ec2.IpPermission
+
+	for _, p := range perms {
+		for _, pair := range p.UserIdGroupPairs {
+			if aws.StringValue(pair.GroupId) != groupID {
+				continue
+			}
+
+			r = append(r, &ec2.IpPermission{
+				IpProtocol:       p.IpProtocol,
+				FromPort:         p.FromPort,
+				ToPort:           p.ToPort,
+				UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: pair.GroupId, UserId: pair.UserId}},
+			})
+		}
+	}
+
+	return r
+}
+
+// volumes returns the IDs of the detached EBS volumes whose claims have been deleted, but are left behind by the provisioner
+func (s *awsResourceSweeper) volumes() ([]string, error) {
+	if len(s.deletedVolumes) == 0 {
+		return nil, nil
+	}
+
+	var ids []string
+
+	if err := s.ec2.DescribeVolumesPages(&ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("volume-id"), Values: aws.StringSlice(s.deletedVolumes)},
+			{Name: aws.String("status"), Values: aws.StringSlice([]string{ec2.VolumeStateAvailable})},
+		},
+	}, func(res *ec2.DescribeVolumesOutput, _ bool) bool {
+		for _, v := range res.Volumes {
+			if id := aws.StringValue(v.VolumeId); containsString(s.deletedVolumes, id) {
+				ids = append(ids, id)
+			}
+		}
+
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing volumes: %w", err)
+	}
+
+	return ids, nil
+}
+
+// existingVolumes returns the volumes that still exist out of the given ones
+func (s *awsResourceSweeper) existingVolumes(ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var existing []string
+
+	if err := s.ec2.DescribeVolumesPages(&ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("volume-id"), Values: aws.StringSlice(ids)}},
+	}, func(res *ec2.DescribeVolumesOutput, _ bool) bool {
+		for _, v := range res.Volumes {
+			existing = append(existing, aws.StringValue(v.VolumeId))
+		}
+
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing volumes: %w", err)
+	}
+
+	return existing, nil
+}
+
+func ec2Tags(tags []*ec2.Tag) map[string]string {
+	m := map[string]string{}
+
+	for _, t := range tags {
+		m[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+
+	return m
+}
+
+func batchStrings(ss []*string, size int) [][]*string {
+	var batches [][]*string
+
+	for len(ss) > size {
+		batches = append(batches, ss[:size])
+		ss = ss[size:]
+	}
+
+	if len(ss) > 0 {
+		batches = append(batches, ss)
+	}
+
+	return batches
+}
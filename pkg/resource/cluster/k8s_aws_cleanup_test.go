@@ -0,0 +1,205 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIsKubernetesCreated(t *testing.T) {
+	testcases := []struct {
+		name string
+		tags map[string]string
+		want bool
+	}{
+		{
+			name: "in-tree service controller",
+			tags: map[string]string{"kubernetes.io/cluster/mycluster": "owned"},
+			want: true,
+		},
+		{
+			name: "aws load balancer controller",
+			tags: map[string]string{"elbv2.k8s.aws/cluster": "mycluster"},
+			want: true,
+		},
+		{
+			name: "another cluster",
+			tags: map[string]string{"elbv2.k8s.aws/cluster": "othercluster", "kubernetes.io/cluster/othercluster": "owned"},
+			want: false,
+		},
+		{
+			name: "eksctl nodegroup",
+			tags: map[string]string{"kubernetes.io/cluster/mycluster": "owned", "aws:cloudformation:stack-name": "eksctl-mycluster-nodegroup-ng1"},
+			want: false,
+		},
+		{
+			name: "eks managed",
+			tags: map[string]string{"kubernetes.io/cluster/mycluster": "owned", "aws:eks:cluster-name": "mycluster"},
+			want: false,
+		},
+		{
+			name: "shared",
+			tags: map[string]string{"kubernetes.io/cluster/mycluster": "shared"},
+			want: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isKubernetesCreated(tc.tags, "mycluster"); got != tc.want {
+				t.Errorf("unexpected result: want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIsKubernetesCreatedSecurityGroup(t *testing.T) {
+	testcases := []struct {
+		name      string
+		groupName string
+		tags      map[string]string
+		want      bool
+	}{
+		{
+			name:      "in-tree service controller",
+			groupName: "k8s-elb-a1b2c3d4",
+			tags:      map[string]string{"kubernetes.io/cluster/mycluster": "owned"},
+			want:      true,
+		},
+		{
+			name:      "aws load balancer controller",
+			groupName: "k8s-default-myingres-1a2b3c4d5e",
+			tags:      map[string]string{"elbv2.k8s.aws/cluster": "mycluster"},
+			want:      true,
+		},
+		{
+			name:      "user managed",
+			groupName: "mycluster-nodes",
+			tags:      map[string]string{"kubernetes.io/cluster/mycluster": "owned"},
+			want:      false,
+		},
+		{
+			name:      "another cluster",
+			groupName: "k8s-elb-a1b2c3d4",
+			tags:      map[string]string{"kubernetes.io/cluster/othercluster": "owned"},
+			want:      false,
+		},
+		{
+			name:      "cluster security group",
+			groupName: "eks-cluster-sg-mycluster-123456789",
+			tags:      map[string]string{"kubernetes.io/cluster/mycluster": "owned", "aws:eks:cluster-name": "mycluster"},
+			want:      false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isKubernetesCreatedSecurityGroup(tc.groupName, tc.tags, "mycluster"); got != tc.want {
+				t.Errorf("unexpected result: want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIsVPCCNICreated(t *testing.T) {
+	if !isVPCCNICreated(map[string]string{"cluster.k8s.amazonaws.com/name": "mycluster"}, "mycluster") {
+		t.Error("expected the network interface of the VPC CNI plugin to be matched")
+	}
+
+	if isVPCCNICreated(map[string]string{"kubernetes.io/cluster/mycluster": "owned"}, "mycluster") {
+		t.Error("expected the network interface tagged by users not to be matched")
+	}
+}
+
+func TestPermissionsReferencing(t *testing.T) {
+	perms := []*ec2.IpPermission{
+		{
+			IpProtocol: aws.String("tcp"),
+			FromPort:   aws.Int64(30000),
+			ToPort:     aws.Int64(32767),
+			UserIdGroupPairs: []*ec2.UserIdGroupPair{
+				{GroupId: aws.String("sg-lb")},
+				{GroupId: aws.String("sg-other")},
+			},
+		},
+		{
+			IpProtocol: aws.String("-1"),
+			IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("10.0.0.0/8")}},
+		},
+	}
+
+	want := []*ec2.IpPermission{
+		{
+			IpProtocol:       aws.String("tcp"),
+			FromPort:         aws.Int64(30000),
+			ToPort:           aws.Int64(32767),
+			UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: aws.String("sg-lb")}},
+		},
+	}
+
+	if d := cmp.Diff(want, permissionsReferencing(perms, "sg-lb")); d != "" {
+		t.Errorf("unexpected permissions: want (-), got (+)\n%s", d)
+	}
+}
+
+type fakeVolumes struct {
+	ec2iface.EC2API
+
+	volumes []*ec2.Volume
+}
+
+func (f *fakeVolumes) DescribeVolumesPages(_ *ec2.DescribeVolumesInput, fn func(*ec2.DescribeVolumesOutput, bool) bool) error {
+	fn(&ec2.DescribeVolumesOutput{Volumes: f.volumes}, true)
+
+	return nil
+}
+
+func TestSweeperVolumes(t *testing.T) {
+	tags := []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/mycluster"), Value: aws.String("owned")}}
+
+	s := &awsResourceSweeper{
+		clusterName: "mycluster",
+		ec2: &fakeVolumes{
+			volumes: []*ec2.Volume{
+				{VolumeId: aws.String("vol-1"), Tags: tags},
+				// The volume of a Retain persistent volume deleted before the destroy
+				{VolumeId: aws.String("vol-2"), Tags: tags},
+				{VolumeId: aws.String("vol-3")},
+			},
+		},
+		deletedVolumes: []string{"vol-1"},
+	}
+
+	got, err := s.volumes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if d := cmp.Diff([]string{"vol-1"}, got); d != "" {
+		t.Errorf("unexpected volumes: want (-), got (+)\n%s", d)
+	}
+
+	s.deletedVolumes = nil
+
+	if got, _ := s.volumes(); len(got) != 0 {
+		t.Errorf("expected no volumes to be deleted without deleted claims, but got %v", got)
+	}
+}
+
+func TestBatchStrings(t *testing.T) {
+	ss := aws.StringSlice([]string{"a", "b", "c", "d", "e"})
+
+	var got [][]string
+	for _, b := range batchStrings(ss, 2) {
+		got = append(got, aws.StringValueSlice(b))
+	}
+
+	want := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}
+
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("unexpected batches: want (-), got (+)\n%s", d)
+	}
+}
@@ -4,42 +4,70 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+	"golang.org/x/xerrors"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"strings"
 )
 
-func doApplyKubernetesManifests(ctx *sdk.Context, cluster *Cluster, id string) error {
+func doApplyKubernetesManifests(ctx *sdk.Context, cluster *Cluster, clusterName string) error {
 	if len(cluster.Manifests) == 0 {
 		return nil
 	}
 
-	kubeconfig, err := ioutil.TempFile("", "terraform-provider-eksctl-kubeconfig-")
+	kubeconfigPath, err := doWriteTempKubeconfig(ctx, cluster, clusterName)
 	if err != nil {
 		return err
 	}
 
-	kubeconfigPath := kubeconfig.Name()
+	defer os.Remove(kubeconfigPath)
 
-	if err := kubeconfig.Close(); err != nil {
+	all := strings.Join(cluster.Manifests, "\n---\n")
+
+	kubectlCmd := newKubectlCommand(cluster, kubeconfigPath, "apply", "-f", "-")
+
+	kubectlCmd.Stdin = bytes.NewBufferString(all)
+
+	if _, err := ctx.Run(kubectlCmd); err != nil {
 		return err
 	}
 
-	clusterName := cluster.Name + "-" + id
+	return nil
+}
+
+// doWriteTempKubeconfig writes the kubeconfig for the cluster into a temporary file that is used only by
+// the provider's own kubectl invocations, so that the user-facing `kubeconfig_path` is left untouched.
+func doWriteTempKubeconfig(ctx *sdk.Context, cluster *Cluster, clusterName string) (string, error) {
+	kubeconfig, err := ioutil.TempFile("", "terraform-provider-eksctl-kubeconfig-")
+	if err != nil {
+		return "", xerrors.Errorf("creating temp kubeconfig file: %w", err)
+	}
+
+	kubeconfigPath := kubeconfig.Name()
+
+	if err := kubeconfig.Close(); err != nil {
+		_ = os.Remove(kubeconfigPath)
+
+		return "", xerrors.Errorf("writing kubeconfig: %w", err)
+	}
 
 	writeKubeconfigCmd, err := newEksctlCommandWithAWSProfile(cluster, "utils", "write-kubeconfig", "--kubeconfig", kubeconfigPath, "--cluster", clusterName, "--region", cluster.Region)
 	if err != nil {
-		return fmt.Errorf("creating eksctl-utils-write-kubeconfig command: %w", err)
+		return "", fmt.Errorf("creating eksctl-utils-write-kubeconfig command: %w", err)
 	}
 
 	if _, err := ctx.Run(writeKubeconfigCmd); err != nil {
-		return err
+		_ = os.Remove(kubeconfigPath)
+
+		return "", xerrors.Errorf("running eksctl-utils-write-kubeconfig: %w", err)
 	}
 
-	all := strings.Join(cluster.Manifests, "\n---\n")
+	return kubeconfigPath, nil
+}
 
-	kubectlCmd := exec.Command(cluster.KubectlBin, "apply", "-f", "-")
+func newKubectlCommand(cluster *Cluster, kubeconfigPath string, args ...string) *exec.Cmd {
+	kubectlCmd := exec.Command(cluster.KubectlBin, args...)
 
 	for _, env := range os.Environ() {
 		if !strings.HasPrefix(env, "KUBECONFIG=") {
@@ -49,11 +77,5 @@ func doApplyKubernetesManifests(ctx *sdk.Context, cluster *Cluster, id string) e
 
 	kubectlCmd.Env = append(kubectlCmd.Env, "KUBECONFIG="+kubeconfigPath)
 
-	kubectlCmd.Stdin = bytes.NewBufferString(all)
-
-	if _, err := ctx.Run(kubectlCmd); err != nil {
-		return err
-	}
-
-	return nil
+	return kubectlCmd
 }
@@ -0,0 +1,280 @@
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+)
+
+const (
+	KeyNodeGroup        = "node_group"
+	KeyManagedNodeGroup = "managed_node_group"
+)
+
+// unsetCapacity is the default of desired_capacity, min_size and max_size,
+// which leaves them to eksctl's defaults while allowing 0 for scaling nodegroups to zero.
+const unsetCapacity = -1
+
+// NodeGroupBlock is a `node_group` or `managed_node_group` block, merged into the cluster.yaml by renderClusterConfig
+type NodeGroupBlock struct {
+	Managed         bool
+	Name            string
+	InstanceTypes   []string
+	DesiredCapacity int
+	MinSize         int
+	MaxSize         int
+	VolumeSize      int
+	Spot            bool
+	Labels          map[string]string
+	Taints          []NodeGroupTaint
+	Subnets         []string
+}
+
+type NodeGroupTaint struct {
+	Key    string
+	Value  string
+	Effect string
+}
+
+func nodeGroupSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				// The first instance type is used for on-demand nodes of unmanaged nodegroups.
+				// Spot nodegroups can have many instance types.
+				"instance_types": {
+					Type:     schema.TypeList,
+					Optional: true,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+				"desired_capacity": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      unsetCapacity,
+					ValidateFunc: validation.IntAtLeast(unsetCapacity),
+				},
+				"min_size": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      unsetCapacity,
+					ValidateFunc: validation.IntAtLeast(unsetCapacity),
+				},
+				"max_size": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      unsetCapacity,
+					ValidateFunc: validation.IntAtLeast(unsetCapacity),
+				},
+				// volume_size is the size of the root volume in GiB. 0 leaves it to eksctl's default
+				"volume_size": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					ValidateFunc: validation.IntAtLeast(0),
+				},
+				"spot": {
+					Type:     schema.TypeBool,
+					Optional: true,
+				},
+				"labels": {
+					Type:     schema.TypeMap,
+					Optional: true,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+				"taint": {
+					Type:     schema.TypeList,
+					Optional: true,
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"key": {
+								Type:     schema.TypeString,
+								Required: true,
+							},
+							"value": {
+								Type:     schema.TypeString,
+								Optional: true,
+							},
+							"effect": {
+								Type:         schema.TypeString,
+								Required:     true,
+								ValidateFunc: validation.StringInSlice([]string{"NoSchedule", "PreferNoSchedule", "NoExecute"}, false),
+							},
+						},
+					},
+				},
+				// subnets are either subnet IDs or availability zones
+				"subnets": {
+					Type:     schema.TypeList,
+					Optional: true,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+			},
+		},
+	}
+}
+
+func readNodeGroupBlocks(v interface{}, managed bool) []NodeGroupBlock {
+	var blocks []NodeGroupBlock
+
+	items, _ := v.([]interface{})
+
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		ng := NodeGroupBlock{
+			Managed:         managed,
+			Name:            m["name"].(string),
+			InstanceTypes:   stringList(m["instance_types"]),
+			DesiredCapacity: m["desired_capacity"].(int),
+			MinSize:         m["min_size"].(int),
+			MaxSize:         m["max_size"].(int),
+			VolumeSize:      m["volume_size"].(int),
+			Spot:            m["spot"].(bool),
+			Labels:          map[string]string{},
+			Subnets:         stringList(m["subnets"]),
+		}
+
+		if labels, ok := m["labels"].(map[string]interface{}); ok {
+			for k, v := range labels {
+				ng.Labels[k] = v.(string)
+			}
+		}
+
+		taints, _ := m["taint"].([]interface{})
+		for _, t := range taints {
+			t := t.(map[string]interface{})
+
+			ng.Taints = append(ng.Taints, NodeGroupTaint{
+				Key:    t["key"].(string),
+				Value:  t["value"].(string),
+				Effect: t["effect"].(string),
+			})
+		}
+
+		blocks = append(blocks, ng)
+	}
+
+	return blocks
+}
+
+func stringList(v interface{}) []string {
+	var r []string
+
+	items, _ := v.([]interface{})
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			r = append(r, s)
+		}
+	}
+
+	return r
+}
+
+// toSpec returns the nodegroup in the form of an item of nodeGroups or managedNodeGroups in the cluster.yaml
+func (ng NodeGroupBlock) toSpec() map[string]interface{} {
+	spec := map[string]interface{}{
+		"name": ng.Name,
+	}
+
+	switch {
+	case len(ng.InstanceTypes) == 0:
+	case ng.Managed:
+		spec["instanceTypes"] = ng.InstanceTypes
+	case ng.Spot:
+		// Unmanaged nodegroups run spot instances via an ASG mixed instances policy
+		spec["instancesDistribution"] = map[string]interface{}{
+			"instanceTypes":                       ng.InstanceTypes,
+			"onDemandBaseCapacity":                0,
+			"onDemandPercentageAboveBaseCapacity": 0,
+			"spotAllocationStrategy":              "capacity-optimized",
+		}
+	default:
+		spec["instanceType"] = ng.InstanceTypes[0]
+	}
+
+	if ng.Managed && ng.Spot {
+		spec["spot"] = true
+	}
+
+	for k, v := range map[string]int{
+		"desiredCapacity": ng.DesiredCapacity,
+		"minSize":         ng.MinSize,
+		"maxSize":         ng.MaxSize,
+	} {
+		if v != unsetCapacity {
+			spec[k] = v
+		}
+	}
+
+	if ng.VolumeSize > 0 {
+		spec["volumeSize"] = ng.VolumeSize
+	}
+
+	if len(ng.Labels) > 0 {
+		labels := map[string]interface{}{}
+		for k, v := range ng.Labels {
+			labels[k] = v
+		}
+
+		spec["labels"] = labels
+	}
+
+	if len(ng.Taints) > 0 {
+		var taints []interface{}
+		for _, t := range ng.Taints {
+			taints = append(taints, map[string]interface{}{
+				"key":    t.Key,
+				"value":  t.Value,
+				"effect": t.Effect,
+			})
+		}
+
+		spec["taints"] = taints
+	}
+
+	if len(ng.Subnets) > 0 {
+		spec["subnets"] = ng.Subnets
+	}
+
+	return spec
+}
+
+// mergeNodeGroupBlocks appends the nodegroups declared in `node_group` and `managed_node_group` blocks
+// to the ones declared in the spec.
+func mergeNodeGroupBlocks(spec map[string]interface{}, blocks []NodeGroupBlock) error {
+	names := map[string]bool{}
+
+	for _, key := range []string{"nodeGroups", "managedNodeGroups"} {
+		for _, name := range namesInList(spec[key], "name") {
+			names[name] = true
+		}
+	}
+
+	for _, ng := range blocks {
+		key, attr := "nodeGroups", KeyNodeGroup
+		if ng.Managed {
+			key, attr = "managedNodeGroups", KeyManagedNodeGroup
+		}
+
+		if names[ng.Name] {
+			return fmt.Errorf("%s %q: nodegroup with the same name is already declared", attr, ng.Name)
+		}
+
+		names[ng.Name] = true
+
+		items, _ := spec[key].([]interface{})
+
+		spec[key] = append(items, ng.toSpec())
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package cluster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// KeyImmutableNodeGroups enables replacing nodegroups whose settings have changed, instead of ignoring the changes
+const KeyImmutableNodeGroups = "immutable_node_groups"
+
+// nodeGroupHashLen is the length of the hash suffixed to the name of every nodegroup when immutable_node_groups is enabled
+const nodeGroupHashLen = 8
+
+var hashedNodeGroupNameRegexp = regexp.MustCompile(fmt.Sprintf("^(.+)-[0-9a-f]{%d}$", nodeGroupHashLen))
+
+// renameImmutableNodeGroups suffixes the name of every nodegroup in the cluster.yaml with the hash of its settings,
+// so that any change to the settings results in `eksctl create nodegroup` for the new nodegroup and
+// `eksctl delete nodegroup --drain` for the old one.
+func renameImmutableNodeGroups(spec map[string]interface{}) error {
+	for _, key := range []string{"nodeGroups", "managedNodeGroups"} {
+		items, _ := spec[key].([]interface{})
+
+		for _, item := range items {
+			ng, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			name, err := immutableNodeGroupName(ng)
+			if err != nil {
+				return fmt.Errorf("computing name of nodegroup %v: %w", ng["name"], err)
+			}
+
+			ng["name"] = name
+		}
+	}
+
+	return nil
+}
+
+// immutableNodeGroupName returns `<name>-<hash>` for the nodegroup.
+// Capacities are excluded from the hash, as eksctl can scale nodegroups in place.
+func immutableNodeGroupName(ng map[string]interface{}) (string, error) {
+	settings := map[string]interface{}{}
+
+	for k, v := range ng {
+		settings[k] = v
+	}
+
+	delete(settings, "name")
+
+	for _, k := range nodeGroupCapacityKeys {
+		delete(settings, k)
+	}
+
+	// json.Marshal sorts map keys so that the hash doesn't depend on the order of the settings
+	bs, err := json.Marshal(settings)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.Sum256(bs)
+
+	return fmt.Sprintf("%v-%s", ng["name"], hex.EncodeToString(h[:])[:nodeGroupHashLen]), nil
+}
+
+// baseNodeGroupName returns the name of the nodegroup in the configuration, without the hash suffixed by renameImmutableNodeGroups
+func baseNodeGroupName(name string) string {
+	if m := hashedNodeGroupNameRegexp.FindStringSubmatch(name); m != nil {
+		return m[1]
+	}
+
+	return name
+}
+
+// resolveNodeGroupName returns the name of the nodegroup in the rendered cluster.yaml that is declared as `name`
+func resolveNodeGroupName(c *EksctlClusterConfig, name string) string {
+	for _, n := range nodeGroupNames(c) {
+		if n == name {
+			return n
+		}
+	}
+
+	for _, n := range nodeGroupNames(c) {
+		if baseNodeGroupName(n) == name {
+			return n
+		}
+	}
+
+	return name
+}
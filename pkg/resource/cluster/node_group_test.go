@@ -0,0 +1,200 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+)
+
+func testNodeGroupBlock(name string, desiredCapacity int) map[string]interface{} {
+	return map[string]interface{}{
+		"name":             name,
+		"instance_types":   []interface{}{"m5.large"},
+		"desired_capacity": desiredCapacity,
+		"min_size":         unsetCapacity,
+		"max_size":         unsetCapacity,
+		"volume_size":      0,
+		"spot":             false,
+		"labels":           map[string]interface{}{},
+		"taint":            []interface{}{},
+		"subnets":          []interface{}{},
+	}
+}
+
+func TestMergeNodeGroupBlocks(t *testing.T) {
+	spec := map[string]interface{}{}
+
+	if err := yaml.Unmarshal([]byte(`
+nodeGroups:
+- name: ng1
+`), spec); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := []NodeGroupBlock{
+		{
+			Name:            "ng2",
+			InstanceTypes:   []string{"m5.large", "m5a.large"},
+			Spot:            true,
+			DesiredCapacity: 0,
+			MinSize:         0,
+			MaxSize:         3,
+			Labels:          map[string]string{"role": "worker"},
+			Taints:          []NodeGroupTaint{{Key: "dedicated", Value: "worker", Effect: "NoSchedule"}},
+		},
+		{
+			Managed:         true,
+			Name:            "mng1",
+			InstanceTypes:   []string{"m5.large", "m5a.large"},
+			Spot:            true,
+			DesiredCapacity: unsetCapacity,
+			MinSize:         unsetCapacity,
+			MaxSize:         unsetCapacity,
+			VolumeSize:      100,
+			Subnets:         []string{"us-east-2a"},
+		},
+	}
+
+	if err := mergeNodeGroupBlocks(spec, blocks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := yaml.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `managedNodeGroups:
+    - instanceTypes:
+        - m5.large
+        - m5a.large
+      name: mng1
+      spot: true
+      subnets:
+        - us-east-2a
+      volumeSize: 100
+nodeGroups:
+    - name: ng1
+    - desiredCapacity: 0
+      instancesDistribution:
+        instanceTypes:
+            - m5.large
+            - m5a.large
+        onDemandBaseCapacity: 0
+        onDemandPercentageAboveBaseCapacity: 0
+        spotAllocationStrategy: capacity-optimized
+      labels:
+        role: worker
+      maxSize: 3
+      minSize: 0
+      name: ng2
+      taints:
+        - effect: NoSchedule
+          key: dedicated
+          value: worker
+`
+
+	if d := cmp.Diff(want, string(got)); d != "" {
+		t.Errorf("unexpected spec: want (-), got (+)\n%s", d)
+	}
+
+	if err := mergeNodeGroupBlocks(spec, []NodeGroupBlock{{Name: "ng1"}}); err == nil {
+		t.Error("expected error for the duplicate nodegroup name")
+	}
+}
+
+func TestReconcileNodeGroupBlocksWithLive(t *testing.T) {
+	blocks := []interface{}{
+		testNodeGroupBlock("ng1", 1),
+		testNodeGroupBlock("ng2", 1),
+	}
+
+	got, changed := reconcileNodeGroupBlocksWithLive(blocks, indexLiveNodeGroups([]LiveNodeGroup{
+		{Name: "ng1", DesiredCapacity: 3, MinSize: 1, MaxSize: 5},
+	}, false))
+
+	if !changed {
+		t.Fatal("expected drift to be detected")
+	}
+
+	want := []interface{}{testNodeGroupBlock("ng1", 3)}
+
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("unexpected blocks: want (-), got (+)\n%s", d)
+	}
+}
+
+func TestRenameImmutableNodeGroups(t *testing.T) {
+	render := func(spec string) []string {
+		t.Helper()
+
+		m := map[string]interface{}{}
+
+		if err := yaml.Unmarshal([]byte(spec), m); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := renameImmutableNodeGroups(m); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var names []string
+		for _, key := range []string{"nodeGroups", "managedNodeGroups"} {
+			names = append(names, namesInList(m[key], "name")...)
+		}
+
+		return names
+	}
+
+	original := render(`
+nodeGroups:
+- name: ng1
+  instanceType: m5.large
+  desiredCapacity: 1
+managedNodeGroups:
+- name: mng1
+`)
+
+	scaled := render(`
+nodeGroups:
+- name: ng1
+  desiredCapacity: 3
+  instanceType: m5.large
+managedNodeGroups:
+- name: mng1
+`)
+
+	changed := render(`
+nodeGroups:
+- name: ng1
+  instanceType: m5.xlarge
+  desiredCapacity: 1
+managedNodeGroups:
+- name: mng1
+`)
+
+	for _, n := range original {
+		if !hashedNodeGroupNameRegexp.MatchString(n) {
+			t.Errorf("expected %s to be suffixed with a hash", n)
+		}
+	}
+
+	if d := cmp.Diff(original, scaled); d != "" {
+		t.Errorf("expected scaling not to rename nodegroups: want (-), got (+)\n%s", d)
+	}
+
+	if original[0] == changed[0] {
+		t.Errorf("expected ng1 to be renamed after changing the instance type, but got %s", changed[0])
+	}
+
+	if original[1] != changed[1] {
+		t.Errorf("expected mng1 not to be renamed, but got %s and %s", original[1], changed[1])
+	}
+
+	c := &EksctlClusterConfig{NodeGroups: []NodeGroup{{Name: changed[0]}}}
+
+	if got := resolveNodeGroupName(c, "ng1"); got != changed[0] {
+		t.Errorf("unexpected resolved name: want %s, got %s", changed[0], got)
+	}
+}
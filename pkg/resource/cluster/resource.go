@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
 	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/tfsdk"
+	"io"
 	"log"
 	"regexp"
 	"runtime/debug"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
 	"gopkg.in/yaml.v3"
 )
 
@@ -25,15 +28,18 @@ func ResourceCluster() *schema.Resource {
 				}
 			}()
 
-			set, err := m.createCluster(d)
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutCreate))
+			defer cancel()
+
+			set, err := m.createCluster(runCtx, d)
 			if err != nil {
 				return fmt.Errorf("creating cluster: %w", err)
 			}
 
 			d.SetId(set.ClusterID)
 
-			if err := loadOIDCProviderURLAndARN(d, set.Cluster); err != nil {
-				return fmt.Errorf("loading oidc issuer url: %w", err)
+			if err := loadClusterAttributes(runCtx, d, set); err != nil {
+				return fmt.Errorf("loading cluster attributes: %w", err)
 			}
 
 			return nil
@@ -65,6 +71,32 @@ func ResourceCluster() *schema.Resource {
 				return fmt.Errorf("drain error: %s", err)
 			}
 
+			if d.NewValueKnown(KeyIAMIdentityMapping) {
+				if err := validateIAMIdentityMappings(d); err != nil {
+					return err
+				}
+			}
+
+			if d.NewValueKnown(KeyAccessEntry) && d.NewValueKnown(KeySpec) {
+				if err := validateAccessEntries(d); err != nil {
+					return err
+				}
+			}
+
+			if err := m.validateClusterConfig(d); err != nil {
+				return err
+			}
+
+			if d.Id() != "" {
+				if err := setSpecDiff(d); err != nil {
+					return err
+				}
+
+				if err := m.setPlannedOperations(d); err != nil {
+					return fmt.Errorf("planning cluster update: %w", err)
+				}
+			}
+
 			return nil
 		},
 		Update: func(d *schema.ResourceData, meta interface{}) (finalErr error) {
@@ -74,15 +106,25 @@ func ResourceCluster() *schema.Resource {
 				}
 			}()
 
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutUpdate))
+			defer cancel()
+
 			log.Printf("udapting existing cluster...")
 
-			set, err := m.updateCluster(d)
+			set, err := m.updateCluster(runCtx, d)
 			if err != nil {
 				return fmt.Errorf("updating cluster: %w", err)
 			}
 
-			if err := loadOIDCProviderURLAndARN(d, set.Cluster); err != nil {
-				return fmt.Errorf("loading oidc issuer url: %w", err)
+			if err := loadClusterAttributes(runCtx, d, set); err != nil {
+				return fmt.Errorf("loading cluster attributes: %w", err)
+			}
+
+			// The operations have been run and the changes applied, so that the next plan without changes shows no diff
+			for _, k := range []string{KeyPlannedOperations, KeySpecDiff} {
+				if err := d.Set(k, []interface{}{}); err != nil {
+					return fmt.Errorf("setting %s: %w", k, err)
+				}
 			}
 
 			return nil
@@ -94,7 +136,10 @@ func ResourceCluster() *schema.Resource {
 				}
 			}()
 
-			if err := m.deleteCluster(d); err != nil {
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutDelete))
+			defer cancel()
+
+			if err := m.deleteCluster(runCtx, d); err != nil {
 				return err
 			}
 
@@ -109,16 +154,23 @@ func ResourceCluster() *schema.Resource {
 				}
 			}()
 
-			_, err := m.readCluster(d)
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutRead))
+			defer cancel()
+
+			_, err := m.readCluster(runCtx, d)
 			if err != nil {
 				return fmt.Errorf("reading cluster: %w", err)
 			}
 
 			return nil
 		},
+		Timeouts: clusterTimeouts(),
 		Importer: &schema.ResourceImporter{
 			State: func(data *schema.ResourceData, i interface{}) ([]*schema.ResourceData, error) {
-				data, err := m.importCluster(data)
+				runCtx, cancel := sdk.ContextWithTimeout(i, data.Timeout(schema.TimeoutRead))
+				defer cancel()
+
+				data, err := m.importCluster(runCtx, data)
 				if err != nil {
 					return nil, fmt.Errorf("importing cluster: %w", err)
 				}
@@ -168,13 +220,13 @@ func ResourceCluster() *schema.Resource {
 				Optional: true,
 				Default:  DefaultVersion,
 			},
-			// Tags is the metadata.tags in the cluster config
+			// Tags is the metadata.tags in the cluster config.
+			// Changes are applied in-place to the cluster, the eksctl stacks and the nodegroups' autoscaling groups.
 			KeyTags: {
 				Type:     schema.TypeMap,
 				Optional: true,
 				Elem:     &schema.Schema{Type: schema.TypeString},
 				Default:  map[string]interface{}{},
-				ForceNew: true,
 			},
 			// revision is the manually bumped revision number of the cluster.
 			// Increment this so that any changes made to `spec` are deployed via a blue-green cluster deployment.
@@ -208,8 +260,10 @@ func ResourceCluster() *schema.Resource {
 			// Over time the provider adds HCL-native syntax for any of cluster.yaml items.
 			// Until then, this is the primary place you configure the cluster as you like.
 			KeySpec: {
-				Type:     schema.TypeString,
-				Required: true,
+				Type:             schema.TypeString,
+				Optional:         true,
+				Default:          "",
+				DiffSuppressFunc: suppressEquivalentSpec,
 				ValidateFunc: func(v interface{}, name string) ([]string, []error) {
 					s := v.(string)
 
@@ -241,50 +295,169 @@ func ResourceCluster() *schema.Resource {
 			KeyIAMIdentityMapping: {
 				Type:     schema.TypeSet,
 				Optional: true,
+				Elem:     iamIdentityMappingResource(),
+			},
+			KeyManageAWSAuth: {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      ManageAWSAuthAdditive,
+				ValidateFunc: validation.StringInSlice([]string{ManageAWSAuthAdditive, ManageAWSAuthAuthoritative}, false),
+			},
+			// access_entry blocks are created with `eksctl create accessentry`, which requires
+			// accessConfig.authenticationMode to be API_AND_CONFIG_MAP or API in the spec
+			KeyAccessEntry: {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem:     accessEntryResource(),
+			},
+			// aws_auth_configmap is all the mappings in the aws-auth configmap, including the ones maintained by eksctl
+			KeyAWSAuthConfigMap: {
+				Type:     schema.TypeSet,
+				Computed: true,
+				Optional: true,
+				Elem:     iamIdentityMappingResource(),
+			},
+			// manifests are applied with `kubectl apply` on cluster creation and update,
+			// before the cluster is considered ready.
+			KeyManifests: {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateManifest,
+				},
+			},
+			KeyPodsReadinessCheck: {
+				Type:     schema.TypeList,
+				Optional: true,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
-						"iamarn": {
-							Required: true,
+						"namespace": {
 							Type:     schema.TypeString,
-						},
-						"username": {
 							Required: true,
-							Type:     schema.TypeString,
 						},
-						"groups": {
+						"labels": {
+							Type:     schema.TypeMap,
 							Required: true,
-							Type:     schema.TypeList,
 							Elem: &schema.Schema{
 								Type: schema.TypeString,
 							},
+							ValidateFunc: func(v interface{}, k string) ([]string, []error) {
+								if len(v.(map[string]interface{})) == 0 {
+									return nil, []error{fmt.Errorf("%q: at least one label is required to select pods", k)}
+								}
+
+								return nil, nil
+							},
+						},
+						"timeout_sec": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      300,
+							ValidateFunc: validation.IntAtLeast(1),
 						},
 					},
 				},
 			},
-			KeyAWSAuthConfigMap: {
-				Type:     schema.TypeSet,
-				Computed: true,
+			KeyKubernetesResourceDeletionBeforeDestroy: {
+				Type:     schema.TypeList,
 				Optional: true,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
-						"iamarn": {
-							Required: true,
+						"namespace": {
 							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "default",
 						},
-						"username": {
-							Required: true,
+						"name": {
 							Type:     schema.TypeString,
-						},
-						"groups": {
 							Required: true,
-							Type:     schema.TypeList,
-							Elem: &schema.Schema{
-								Type: schema.TypeString,
-							},
+						},
+						"kind": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringInSlice(ValidDeleteK8sResourceKinds, false),
 						},
 					},
 				},
 			},
+			KeyNodeGroup:        nodeGroupSchema(),
+			KeyManagedNodeGroup: nodeGroupSchema(),
+			// immutable_node_groups replaces nodegroups whose settings have changed with new ones named `<name>-<hash>`
+			KeyImmutableNodeGroups: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			// deletion_protection makes `terraform destroy` and replacements of the cluster fail until it's disabled
+			KeyDeletionProtection: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			// force_destroy destroys the cluster even when it has LoadBalancer services, EBS-backed persistent volumes,
+			// or target groups receiving traffic, that would be left behind
+			KeyForceDestroy: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			// cleanup_aws_resources_before_destroy deletes ingresses, services of type LoadBalancer, and persistent volume claims
+			// before destroy, and then the load balancers, security groups, network interfaces and EBS volumes left behind
+			KeyCleanupAWSResourcesBeforeDestroy: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			KeyAddon:         addonSchema(),
+			KeyALBAttachment: albAttachmentSchema(),
+			KeyMetrics:       metricsSchema(),
+			KeyTargetGroupARNs: {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			// upgrade_node_groups upgrades managed nodegroups to every intermediate version the control plane is upgraded to
+			KeyUpgradeNodeGroups: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			// max_parallel_operations limits the number of update operations that run concurrently
+			KeyMaxParallelOperations: {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      4,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			// planned_operations lists the eksctl operations that the pending update is going to run
+			KeyPlannedOperations: {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			// spec_diff lists the changes made to spec by the pending update, like `nodeGroups[ng1].desiredCapacity: 1 -> 2`
+			KeySpecDiff: {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			// node_group_drift lists the out-of-band changes to the nodegroups in spec, which the next update reverts
+			KeyNodeGroupDrift: {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			KeyUpdateCheckpoint: updateCheckpointSchema(),
+			KeyUpdateTasks:      updateTasksSchema(),
 			sdk.KeyOutput: {
 				Type:     schema.TypeString,
 				Computed: true,
@@ -304,6 +477,35 @@ func ResourceCluster() *schema.Resource {
 					Type: schema.TypeString,
 				},
 			},
+			KeyEndpoint: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			// certificate_authority_data is base64-encoded, like the certificate-authority-data in kubeconfig
+			KeyCertificateAuthorityData: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			KeyARN: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			KeyPlatformVersion: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			KeyStatus: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			KeyClusterSecurityGroupID: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			KeyServiceIPv4CIDR: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
 		},
 	}
 }
@@ -329,3 +531,38 @@ func validateDrainNodeGroups(d *schema.ResourceDiff) error {
 
 	return nil
 }
+
+func validateManifest(v interface{}, k string) ([]string, []error) {
+	dec := yaml.NewDecoder(strings.NewReader(v.(string)))
+
+	for i := 0; ; i++ {
+		var doc map[string]interface{}
+
+		if err := dec.Decode(&doc); err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, []error{fmt.Errorf("%q: parsing document %d: %w", k, i, err)}
+		}
+
+		if doc == nil {
+			continue
+		}
+
+		for _, f := range []string{"apiVersion", "kind"} {
+			if _, ok := doc[f]; !ok {
+				return nil, []error{fmt.Errorf("%q: document %d is missing %q", k, i, f)}
+			}
+		}
+	}
+}
+
+// clusterTimeouts returns the default timeouts of the cluster resources.
+// They are long enough for eksctl to create or delete a cluster with a few nodegroups,
+// and to update a cluster that goes through several addon and nodegroup upgrades.
+func clusterTimeouts() *schema.ResourceTimeout {
+	return &schema.ResourceTimeout{
+		Create: schema.DefaultTimeout(60 * time.Minute),
+		Update: schema.DefaultTimeout(180 * time.Minute),
+		Delete: schema.DefaultTimeout(60 * time.Minute),
+	}
+}
@@ -0,0 +1,205 @@
+package cluster
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/api"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/tfsdk"
+)
+
+// KeyCluster is the name of the cluster the eksctl_access_entry belongs to
+const KeyCluster = "cluster"
+
+// ResourceAccessEntry is the `eksctl_access_entry` resource.
+//
+// It manages a single EKS access entry of a cluster managed elsewhere, like the `access_entry` block of `eksctl_cluster` does.
+// Any change to the access entry replaces it, as eksctl can't update access entries.
+func ResourceAccessEntry() *schema.Resource {
+	sc := accessEntrySchema()
+
+	for _, s := range sc {
+		s.ForceNew = true
+	}
+
+	sc[KeyCluster] = &schema.Schema{
+		Type:     schema.TypeString,
+		Required: true,
+		ForceNew: true,
+	}
+	sc[KeyRegion] = &schema.Schema{
+		Type:        schema.TypeString,
+		Required:    true,
+		ForceNew:    true,
+		DefaultFunc: schema.EnvDefaultFunc("AWS_DEFAULT_REGION", nil),
+	}
+	sc[KeyProfile] = &schema.Schema{
+		Type:     schema.TypeString,
+		Optional: true,
+		Default:  "",
+	}
+	sc[tfsdk.KeyAssumeRole] = tfsdk.SchemaAssumeRole()
+	sc[KeyBin] = &schema.Schema{
+		Type:     schema.TypeString,
+		Optional: true,
+		Default:  "eksctl",
+	}
+	sc[KeyEksctlVersion] = &schema.Schema{
+		Type:     schema.TypeString,
+		Optional: true,
+		Default:  "",
+	}
+
+	return &schema.Resource{
+		Create: func(d *schema.ResourceData, meta interface{}) (finalErr error) {
+			defer func() {
+				if err := recover(); err != nil {
+					finalErr = fmt.Errorf("unhandled error: %v\n%s", err, debug.Stack())
+				}
+			}()
+
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutCreate))
+			defer cancel()
+
+			e := accessEntryFromValue(accessEntryValues(d))
+
+			if err := validateAccessEntry(e); err != nil {
+				return err
+			}
+
+			clusterName := d.Get(KeyCluster).(string)
+
+			if err := runCreateAccessEntries(mustNewContextFromResource(runCtx, d), d, clusterName, []accessEntry{e}); err != nil {
+				return fmt.Errorf("creating access entry: %w", err)
+			}
+
+			region, _ := tfsdk.GetAWSRegionAndProfile(d)
+
+			d.SetId(accessEntryID(region, clusterName, e.PrincipalARN))
+
+			return nil
+		},
+		Read: func(d *schema.ResourceData, meta interface{}) (finalErr error) {
+			defer func() {
+				if err := recover(); err != nil {
+					finalErr = fmt.Errorf("unhandled error: %v\n%s", err, debug.Stack())
+				}
+			}()
+
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutRead))
+			defer cancel()
+
+			return readAccessEntry(mustNewContextFromResource(runCtx, d), d)
+		},
+		Update: func(d *schema.ResourceData, meta interface{}) error {
+			// Only profile, assume_role and the eksctl binary can change in-place, which are used by the next operation
+			return nil
+		},
+		Delete: func(d *schema.ResourceData, meta interface{}) (finalErr error) {
+			defer func() {
+				if err := recover(); err != nil {
+					finalErr = fmt.Errorf("unhandled error: %v\n%s", err, debug.Stack())
+				}
+			}()
+
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutDelete))
+			defer cancel()
+
+			arn := d.Get(KeyPrincipalARN).(string)
+
+			if err := runDeleteAccessEntries(mustNewContextFromResource(runCtx, d), d, d.Get(KeyCluster).(string), []string{arn}); err != nil {
+				return err
+			}
+
+			d.SetId("")
+
+			return nil
+		},
+		Importer: &schema.ResourceImporter{
+			State: func(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+				region, clusterName, arn, err := parseAccessEntryID(d.Id())
+				if err != nil {
+					return nil, err
+				}
+
+				for k, v := range map[string]interface{}{
+					KeyRegion:        region,
+					KeyCluster:       clusterName,
+					KeyPrincipalARN:  arn,
+					KeyProfile:       "",
+					KeyBin:           "eksctl",
+					KeyEksctlVersion: "",
+				} {
+					if err := d.Set(k, v); err != nil {
+						return nil, fmt.Errorf("setting %s: %w", k, err)
+					}
+				}
+
+				return []*schema.ResourceData{d}, nil
+			},
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(20 * time.Minute),
+			Delete: schema.DefaultTimeout(20 * time.Minute),
+		},
+		Schema: sc,
+	}
+}
+
+// accessEntryValues returns the attributes of the eksctl_access_entry resource in the form of an access_entry block
+func accessEntryValues(d api.Getter) map[string]interface{} {
+	m := map[string]interface{}{}
+
+	for k := range accessEntrySchema() {
+		m[k] = d.Get(k)
+	}
+
+	return m
+}
+
+// readAccessEntry refreshes the state of the eksctl_access_entry resource from the live access entry.
+// It clears the resource ID when the access entry no longer exists, so that Terraform plans to recreate it.
+func readAccessEntry(ctx *sdk.Context, d *schema.ResourceData) error {
+	live, err := runGetAccessEntries(ctx, d, d.Get(KeyCluster).(string))
+	if err != nil {
+		return fmt.Errorf("reading access entries: %w", err)
+	}
+
+	observed := observedAccessEntries(live, []accessEntry{accessEntryFromValue(accessEntryValues(d))})
+
+	if len(observed) == 0 {
+		log.Printf("[WARN] access entry %s no longer exists. Removing it from the state", d.Id())
+
+		d.SetId("")
+
+		return nil
+	}
+
+	for k, v := range observed[0].(map[string]interface{}) {
+		if err := d.Set(k, v); err != nil {
+			return fmt.Errorf("setting %s: %w", k, err)
+		}
+	}
+
+	return nil
+}
+
+func accessEntryID(region, clusterName, principalARN string) string {
+	return fmt.Sprintf("%s:%s:%s", region, clusterName, principalARN)
+}
+
+// parseAccessEntryID parses the ID of the eksctl_access_entry resource in the form of `<region>:<cluster>:<principal arn>`
+func parseAccessEntryID(id string) (string, string, string, error) {
+	parts := strings.SplitN(id, ":", 3)
+
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || !strings.HasPrefix(parts[2], "arn:") {
+		return "", "", "", fmt.Errorf("unexpected ID %q: it must be in the form of <region>:<cluster>:<principal arn>", id)
+	}
+
+	return parts[0], parts[1], parts[2], nil
+}
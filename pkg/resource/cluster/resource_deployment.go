@@ -0,0 +1,219 @@
+package cluster
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/tfsdk"
+)
+
+// ResourceClusterDeployment is the `eksctl_cluster_deployment` resource.
+//
+// Unlike `eksctl_cluster`, every cluster managed by this resource is named `<name>-<id>`, so that
+// bumping `revision` or `version` can bring up a new cluster alongside the current one,
+// shift the ALB traffic to it, and finally destroy the old cluster.
+func ResourceClusterDeployment() *schema.Resource {
+	m := &Manager{
+		DisableClusterNameSuffix: false,
+	}
+
+	sc := ResourceCluster().Schema
+
+	sc[KeyPendingClusterDeletions] = &schema.Schema{
+		Type:     schema.TypeList,
+		Computed: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				KeyName: {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				KeyTargetGroupARNs: {
+					Type:     schema.TypeList,
+					Computed: true,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+			},
+		},
+	}
+
+	return &schema.Resource{
+		Create: func(d *schema.ResourceData, meta interface{}) (finalErr error) {
+			defer func() {
+				if err := recover(); err != nil {
+					finalErr = fmt.Errorf("unhandled error: %v\n%s", err, debug.Stack())
+				}
+			}()
+
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutCreate))
+			defer cancel()
+
+			set, err := m.createCluster(runCtx, d)
+			if err != nil {
+				return fmt.Errorf("creating cluster: %w", err)
+			}
+
+			d.SetId(set.ClusterID)
+
+			if err := loadClusterAttributes(runCtx, d, set); err != nil {
+				return fmt.Errorf("loading cluster attributes: %w", err)
+			}
+
+			return nil
+		},
+		CustomizeDiff: func(d *schema.ResourceDiff, meta interface{}) (finalErr error) {
+			defer func() {
+				if err := recover(); err != nil {
+					finalErr = fmt.Errorf("unhandled error: %v\n%s", err, debug.Stack())
+				}
+			}()
+
+			if err := m.planCluster(&tfsdk.DiffReadWrite{D: d}); err != nil {
+				return fmt.Errorf("diffing cluster: %w", err)
+			}
+
+			if err := m.validateClusterConfig(d); err != nil {
+				return err
+			}
+
+			if d.Id() == "" {
+				return nil
+			}
+
+			if err := setSpecDiff(d); err != nil {
+				return err
+			}
+
+			pendingOps := pendingClusterDeletionOperations(d.Get(KeyPendingClusterDeletions).([]interface{}))
+
+			if len(pendingOps) > 0 {
+				// Trigger an update to retry deleting the old clusters
+				if err := d.SetNewComputed(KeyPendingClusterDeletions); err != nil {
+					return fmt.Errorf("marking %s as computed: %w", KeyPendingClusterDeletions, err)
+				}
+			}
+
+			if !d.HasChange(KeyRevision) && !d.HasChange(KeyVersion) {
+				if err := m.setPlannedOperations(d); err != nil {
+					return fmt.Errorf("planning cluster update: %w", err)
+				}
+
+				if len(pendingOps) == 0 {
+					return nil
+				}
+
+				if err := d.SetNew(KeyPlannedOperations, append(operationNames(pendingOps), d.Get(KeyPlannedOperations).([]interface{})...)); err != nil {
+					return fmt.Errorf("setting %s: %w", KeyPlannedOperations, err)
+				}
+
+				return nil
+			}
+
+			// The cluster is going to be replaced by a new one, whose outputs are unknown until it's created.
+			for _, k := range append([]string{sdk.KeyOutput, KeyOIDCProviderURL, KeyOIDCProviderARN, KeySecurityGroupIDs, KeyTargetGroupARNs, KeyNodeGroupDrift}, clusterAttributeKeys...) {
+				if err := d.SetNewComputed(k); err != nil {
+					return fmt.Errorf("marking %s as computed: %w", k, err)
+				}
+			}
+
+			oldName, _ := d.GetChange(KeyName)
+
+			ops := []Operation{
+				{Name: OpCreateCluster},
+				{Name: OpShiftTraffic},
+				{Name: OpDeleteCluster, Targets: []string{fmt.Sprintf("%s-%s", oldName, d.Id())}},
+			}
+
+			ops = append(ops, pendingOps...)
+
+			if err := d.SetNew(KeyPlannedOperations, operationNames(ops)); err != nil {
+				return fmt.Errorf("setting %s: %w", KeyPlannedOperations, err)
+			}
+
+			return nil
+		},
+		Update: func(d *schema.ResourceData, meta interface{}) (finalErr error) {
+			defer func() {
+				if err := recover(); err != nil {
+					finalErr = fmt.Errorf("unhandled error: %v\n%s", err, debug.Stack())
+				}
+			}()
+
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutUpdate))
+			defer cancel()
+
+			set, err := m.updateClusterDeployment(runCtx, d)
+			if err != nil {
+				return fmt.Errorf("updating cluster deployment: %w", err)
+			}
+
+			if err := loadClusterAttributes(runCtx, d, set); err != nil {
+				return fmt.Errorf("loading cluster attributes: %w", err)
+			}
+
+			// The operations have been run and the changes applied, so that the next plan without changes shows no diff
+			for _, k := range []string{KeyPlannedOperations, KeySpecDiff} {
+				if err := d.Set(k, []interface{}{}); err != nil {
+					return fmt.Errorf("setting %s: %w", k, err)
+				}
+			}
+
+			return nil
+		},
+		Delete: func(d *schema.ResourceData, meta interface{}) (finalErr error) {
+			defer func() {
+				if err := recover(); err != nil {
+					finalErr = fmt.Errorf("unhandled error: %v\n%s", err, debug.Stack())
+				}
+			}()
+
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutDelete))
+			defer cancel()
+
+			if err := m.deletePendingClusters(runCtx, d, d.Get(KeyPendingClusterDeletions).([]interface{})); err != nil {
+				return err
+			}
+
+			if err := m.deleteCluster(runCtx, d); err != nil {
+				return err
+			}
+
+			d.SetId("")
+
+			return nil
+		},
+		Read: func(d *schema.ResourceData, meta interface{}) (finalErr error) {
+			defer func() {
+				if err := recover(); err != nil {
+					finalErr = fmt.Errorf("unhandled error: %v\n%s", err, debug.Stack())
+				}
+			}()
+
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutRead))
+			defer cancel()
+
+			if _, err := m.readCluster(runCtx, d); err != nil {
+				return fmt.Errorf("reading cluster: %w", err)
+			}
+
+			return nil
+		},
+		Timeouts: clusterTimeouts(),
+		Schema:   sc,
+	}
+}
+
+// pendingClusterDeletionOperations returns the operations for deleting the old clusters left by failed deployments
+func pendingClusterDeletionOperations(pending []interface{}) []Operation {
+	var ops []Operation
+
+	for _, v := range pending {
+		if p, ok := v.(map[string]interface{}); ok {
+			ops = append(ops, Operation{Name: OpDeleteCluster, Targets: []string{p[KeyName].(string)}})
+		}
+	}
+
+	return ops
+}
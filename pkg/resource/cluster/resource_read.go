@@ -30,6 +30,14 @@ func ReadCluster(d api.Getter) (*Cluster, error) {
 
 	a.VPCID = d.Get(KeyVPCID).(string)
 
+	a.NodeGroups = append(readNodeGroupBlocks(d.Get(KeyNodeGroup), false), readNodeGroupBlocks(d.Get(KeyManagedNodeGroup), true)...)
+	a.ImmutableNodeGroups, _ = d.Get(KeyImmutableNodeGroups).(bool)
+	a.Addons = readAddonBlocks(d.Get(KeyAddon))
+	a.DeletionProtection, _ = d.Get(KeyDeletionProtection).(bool)
+	a.ForceDestroy, _ = d.Get(KeyForceDestroy).(bool)
+	a.CleanupAWSResourcesBeforeDestroy, _ = d.Get(KeyCleanupAWSResourcesBeforeDestroy).(bool)
+	a.ManageAWSAuth, _ = d.Get(KeyManageAWSAuth).(string)
+
 	if v := d.Get(KeyPodsReadinessCheck); v != nil {
 		rawCheckPodsReadiness := v.([]interface{})
 		for _, r := range rawCheckPodsReadiness {
@@ -81,6 +89,24 @@ func ReadCluster(d api.Getter) (*Cluster, error) {
 		}
 	}
 
+	if v := d.Get(KeyALBAttachment); v != nil {
+		attachments, err := readALBAttachments(v.([]interface{}))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", KeyALBAttachment, err)
+		}
+
+		a.ALBAttachments = attachments
+	}
+
+	if v := d.Get(KeyMetrics); v != nil {
+		metrics, err := readMetrics(v.([]interface{}))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", KeyMetrics, err)
+		}
+
+		a.Metrics = metrics
+	}
+
 	if cfg := tfsdk.GetAssumeRoleConfig(d); cfg != nil {
 		a.AssumeRoleConfig = cfg
 	}
@@ -0,0 +1,58 @@
+package cluster
+
+import (
+	"testing"
+)
+
+func TestValidateManifest(t *testing.T) {
+	testcases := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{
+			name: "multiple documents",
+			input: `apiVersion: v1
+kind: Namespace
+metadata:
+  name: foo
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: bar
+  namespace: foo
+`,
+		},
+		{
+			name: "empty documents",
+			input: `---
+apiVersion: v1
+kind: Namespace
+---
+`,
+		},
+		{
+			name:    "invalid yaml",
+			input:   "apiVersion: v1\nkind: [\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing kind",
+			input:   "apiVersion: v1\nmetadata:\n  name: foo\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, errs := validateManifest(tc.input, KeyManifests)
+
+			if tc.wantErr && len(errs) == 0 {
+				t.Errorf("expected error, got none")
+			} else if !tc.wantErr && len(errs) > 0 {
+				t.Errorf("unexpected errors: %v", errs)
+			}
+		})
+	}
+}
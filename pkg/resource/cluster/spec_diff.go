@@ -0,0 +1,235 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// KeySpecDiff lists the changes made to the spec, path by path
+const KeySpecDiff = "spec_diff"
+
+// suppressEquivalentSpec suppresses diffs between spec YAMLs that differ only in formatting, like
+// indentation, comments, quotes and the order of keys.
+func suppressEquivalentSpec(k, old, new string, d *schema.ResourceData) bool {
+	o, err := parseSpec(old)
+	if err != nil {
+		return false
+	}
+
+	n, err := parseSpec(new)
+	if err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(o, n)
+}
+
+func parseSpec(spec string) (interface{}, error) {
+	var v interface{}
+
+	if err := yaml.Unmarshal([]byte(spec), &v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// setSpecDiff exposes the changes made to the spec as `spec_diff`, so that `terraform plan` shows which fields have changed
+// rather than the whole spec.
+func setSpecDiff(d *schema.ResourceDiff) error {
+	if !d.NewValueKnown(KeySpec) {
+		return d.SetNewComputed(KeySpecDiff)
+	}
+
+	if !d.HasChange(KeySpec) {
+		return d.SetNew(KeySpecDiff, []interface{}{})
+	}
+
+	o, n := d.GetChange(KeySpec)
+
+	changes, err := specDiff(o.(string), n.(string))
+	if err != nil {
+		return fmt.Errorf("diffing %s: %w", KeySpec, err)
+	}
+
+	v := []interface{}{}
+	for _, c := range changes {
+		v = append(v, c)
+	}
+
+	return d.SetNew(KeySpecDiff, v)
+}
+
+// specDiff returns the changes between the two spec YAMLs, one line per changed path like `nodeGroups[ng1].desiredCapacity: 1 -> 2`.
+// Items of lists like nodeGroups are identified by their names, so that reordering them isn't reported as changes.
+func specDiff(old, new string) ([]string, error) {
+	o, err := parseSpec(old)
+	if err != nil {
+		return nil, fmt.Errorf("parsing previous spec: %w", err)
+	}
+
+	n, err := parseSpec(new)
+	if err != nil {
+		return nil, fmt.Errorf("parsing spec: %w", err)
+	}
+
+	var changes []string
+
+	diffValues("", o, n, &changes)
+
+	return changes, nil
+}
+
+func diffValues(path string, o, n interface{}, changes *[]string) {
+	if reflect.DeepEqual(o, n) {
+		return
+	}
+
+	switch ov := o.(type) {
+	case map[string]interface{}:
+		if nv, ok := n.(map[string]interface{}); ok {
+			diffMaps(path, ov, nv, changes)
+
+			return
+		}
+	case []interface{}:
+		if nv, ok := n.([]interface{}); ok {
+			if diffNamedLists(path, ov, nv, changes) {
+				return
+			}
+
+			if len(ov) == len(nv) {
+				for i := range ov {
+					diffValues(fmt.Sprintf("%s[%d]", path, i), ov[i], nv[i], changes)
+				}
+
+				return
+			}
+		}
+	}
+
+	if path == "" {
+		path = "(root)"
+	}
+
+	*changes = append(*changes, fmt.Sprintf("%s: %s -> %s", path, formatSpecValue(o), formatSpecValue(n)))
+}
+
+func diffMaps(path string, o, n map[string]interface{}, changes *[]string) {
+	keys := map[string]bool{}
+
+	for k := range o {
+		keys[k] = true
+	}
+
+	for k := range n {
+		keys[k] = true
+	}
+
+	var sorted []string
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+
+		diffValues(p, o[k], n[k], changes)
+	}
+}
+
+// diffNamedLists diffs lists whose items are all maps with names, by the names.
+// It returns false for any other lists.
+func diffNamedLists(path string, o, n []interface{}, changes *[]string) bool {
+	oldItems, ok := namedListItems(o)
+	if !ok {
+		return false
+	}
+
+	newItems, ok := namedListItems(n)
+	if !ok {
+		return false
+	}
+
+	var names []string
+
+	for name := range oldItems {
+		names = append(names, name)
+	}
+
+	for name := range newItems {
+		if _, ok := oldItems[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		oi, ni := oldItems[name], newItems[name]
+
+		// Untyped nils so that added and removed items are formatted as (none)
+		var ov, nv interface{}
+		if oi != nil {
+			ov = oi
+		}
+		if ni != nil {
+			nv = ni
+		}
+
+		diffValues(fmt.Sprintf("%s[%s]", path, name), ov, nv, changes)
+	}
+
+	return true
+}
+
+func namedListItems(items []interface{}) (map[string]map[string]interface{}, bool) {
+	if len(items) == 0 {
+		return nil, false
+	}
+
+	r := map[string]map[string]interface{}{}
+
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		name, ok := m["name"].(string)
+		if !ok || name == "" {
+			return nil, false
+		}
+
+		if _, dup := r[name]; dup {
+			return nil, false
+		}
+
+		r[name] = m
+	}
+
+	return r, true
+}
+
+func formatSpecValue(v interface{}) string {
+	if v == nil {
+		return "(none)"
+	}
+
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+
+	return string(bs)
+}
@@ -0,0 +1,123 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSuppressEquivalentSpec(t *testing.T) {
+	testcases := []struct {
+		name     string
+		old, new string
+		want     bool
+	}{
+		{
+			name: "reindented and reordered",
+			old: `
+nodeGroups:
+- name: ng1
+  instanceType: m5.large
+  desiredCapacity: 1
+`,
+			new: `
+# comment
+nodeGroups:
+    -   desiredCapacity: 1
+        name: "ng1"
+        instanceType: m5.large
+`,
+			want: true,
+		},
+		{
+			name: "value changed",
+			old: `
+nodeGroups:
+- name: ng1
+  desiredCapacity: 1
+`,
+			new: `
+nodeGroups:
+- name: ng1
+  desiredCapacity: 2
+`,
+			want: false,
+		},
+		{
+			name: "unparsable",
+			old:  `nodeGroups: []`,
+			new:  `nodeGroups: [`,
+			want: false,
+		},
+		{
+			name: "empty",
+			old:  ``,
+			new:  "\n",
+			want: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := suppressEquivalentSpec(KeySpec, tc.old, tc.new, nil); got != tc.want {
+				t.Errorf("unexpected result: want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSpecDiff(t *testing.T) {
+	old := `
+iam:
+  withOIDC: true
+nodeGroups:
+- name: ng1
+  instanceType: m5.large
+  desiredCapacity: 1
+  labels:
+    role: worker
+- name: ng2
+  instanceType: m5.large
+vpc:
+  publicAccessCIDRs:
+  - 10.0.0.0/8
+`
+
+	new := `
+nodeGroups:
+- name: ng3
+  instanceType: m5.xlarge
+- name: ng1
+  instanceType: m5.large
+  desiredCapacity: 2
+  labels:
+    role: worker
+    team: a
+vpc:
+  publicAccessCIDRs:
+  - 10.0.0.0/8
+  - 192.168.0.0/16
+`
+
+	got, err := specDiff(old, new)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		`iam: {"withOIDC":true} -> (none)`,
+		`nodeGroups[ng1].desiredCapacity: 1 -> 2`,
+		`nodeGroups[ng1].labels.team: (none) -> "a"`,
+		`nodeGroups[ng2]: {"instanceType":"m5.large","name":"ng2"} -> (none)`,
+		`nodeGroups[ng3]: (none) -> {"instanceType":"m5.xlarge","name":"ng3"}`,
+		`vpc.publicAccessCIDRs: ["10.0.0.0/8"] -> ["10.0.0.0/8","192.168.0.0/16"]`,
+	}
+
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("unexpected diff: want (-), got (+)\n%s", d)
+	}
+
+	if _, err := specDiff(old, "nodeGroups: ["); err == nil {
+		t.Error("expected error for the unparsable spec")
+	}
+}
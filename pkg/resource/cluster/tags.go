@@ -0,0 +1,349 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/eks/eksiface"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+)
+
+// TagKeyEksctlClusterName is the tag eksctl adds to every CloudFormation stack it creates, whose value is the cluster name
+const TagKeyEksctlClusterName = "alpha.eksctl.io/cluster-name"
+
+// TagKeyEKSClusterName is the tag EKS adds to the autoscaling groups of managed nodegroups, whose value is the cluster name
+const TagKeyEKSClusterName = "eks:cluster-name"
+
+// updatableStackStatuses are the statuses of stacks that can be updated
+var updatableStackStatuses = []string{
+	cloudformation.StackStatusCreateComplete,
+	cloudformation.StackStatusUpdateComplete,
+	cloudformation.StackStatusUpdateRollbackComplete,
+	cloudformation.StackStatusImportComplete,
+}
+
+// doUpdateTags applies the change in `tags` to the EKS cluster, the CloudFormation stacks created by eksctl, and the autoscaling groups of the nodegroups.
+// The stacks propagate the tags to the resources they manage, and the autoscaling groups propagate them to the nodes launched afterwards.
+func doUpdateTags(ctx *sdk.Context, clusterName string, old, new map[string]string) error {
+	updated, removed := tagChanges(old, new)
+	if len(updated) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	sess := ctx.Session()
+
+	if err := updateClusterTags(eks.New(sess), clusterName, updated, removed); err != nil {
+		return err
+	}
+
+	if err := updateStackTags(ctx.Context(), cloudformation.New(sess), clusterName, updated, removed); err != nil {
+		return err
+	}
+
+	return updateAutoScalingGroupTags(autoscaling.New(sess), clusterName, updated, removed)
+}
+
+// tagChanges returns the tags that are added or whose values have changed, and the keys of the removed tags
+func tagChanges(old, new map[string]string) (map[string]string, []string) {
+	updated := map[string]string{}
+
+	for k, v := range new {
+		if ov, ok := old[k]; !ok || ov != v {
+			updated[k] = v
+		}
+	}
+
+	var removed []string
+
+	for k := range old {
+		if _, ok := new[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+
+	sort.Strings(removed)
+
+	return updated, removed
+}
+
+func updateClusterTags(svc eksiface.EKSAPI, clusterName string, updated map[string]string, removed []string) error {
+	res, err := svc.DescribeCluster(&eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err != nil {
+		return fmt.Errorf("describing cluster %s: %w", clusterName, err)
+	}
+
+	arn := res.Cluster.Arn
+
+	if len(updated) > 0 {
+		if _, err := svc.TagResource(&eks.TagResourceInput{ResourceArn: arn, Tags: aws.StringMap(updated)}); err != nil {
+			return fmt.Errorf("tagging cluster %s: %w", clusterName, err)
+		}
+	}
+
+	if len(removed) > 0 {
+		if _, err := svc.UntagResource(&eks.UntagResourceInput{ResourceArn: arn, TagKeys: aws.StringSlice(removed)}); err != nil {
+			return fmt.Errorf("untagging cluster %s: %w", clusterName, err)
+		}
+	}
+
+	return nil
+}
+
+// updateStackTags updates the tags of the eksctl stacks of the cluster with their templates and parameters unchanged.
+// All the stacks are updated at once, and then waited for.
+// A stack that can't be updated, like one in the middle of another update, doesn't block the other stacks, but fails the update once they are done.
+func updateStackTags(ctx context.Context, cfn cloudformationiface.CloudFormationAPI, clusterName string, updated map[string]string, removed []string) error {
+	stacks, err := eksctlStacksOf(cfn, clusterName)
+	if err != nil {
+		return err
+	}
+
+	var updating []string
+
+	var errs []error
+
+	for _, s := range stacks {
+		name := aws.StringValue(s.StackName)
+
+		if !containsString(updatableStackStatuses, aws.StringValue(s.StackStatus)) {
+			errs = append(errs, fmt.Errorf("updating tags of stack %s: stack is in %s state", name, aws.StringValue(s.StackStatus)))
+
+			continue
+		}
+
+		var params []*cloudformation.Parameter
+		for _, p := range s.Parameters {
+			params = append(params, &cloudformation.Parameter{ParameterKey: p.ParameterKey, UsePreviousValue: aws.Bool(true)})
+		}
+
+		log.Printf("[DEBUG] updating tags of stack %s", name)
+
+		_, err := cfn.UpdateStack(&cloudformation.UpdateStackInput{
+			StackName:           s.StackName,
+			UsePreviousTemplate: aws.Bool(true),
+			Parameters:          params,
+			Capabilities:        s.Capabilities,
+			Tags:                stackTagsWith(s.Tags, updated, removed),
+		})
+		if isNoUpdatesToPerform(err) {
+			log.Printf("[DEBUG] tags of stack %s are up to date", name)
+
+			continue
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("updating tags of stack %s: %w", name, err))
+
+			continue
+		}
+
+		updating = append(updating, name)
+	}
+
+	for _, name := range updating {
+		if err := cfn.WaitUntilStackUpdateCompleteWithContext(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String(name)}); err != nil {
+			errs = append(errs, fmt.Errorf("waiting for tags of stack %s to be updated: %w", name, err))
+		}
+	}
+
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		var msgs []string
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+
+		return fmt.Errorf("updating tags of %d stacks failed:\n%s", len(errs), strings.Join(msgs, "\n"))
+	}
+}
+
+// eksctlStacksOf returns the stacks created by eksctl for the cluster.
+// It lists only the stacks named `eksctl-<cluster name>-*`, and then checks their tags to exclude the stacks of clusters whose names share the prefix.
+func eksctlStacksOf(cfn cloudformationiface.CloudFormationAPI, clusterName string) ([]*cloudformation.Stack, error) {
+	prefix := fmt.Sprintf("eksctl-%s-", clusterName)
+
+	var names []string
+
+	if err := cfn.ListStacksPages(&cloudformation.ListStacksInput{}, func(out *cloudformation.ListStacksOutput, _ bool) bool {
+		for _, s := range out.StackSummaries {
+			name := aws.StringValue(s.StackName)
+
+			if strings.HasPrefix(name, prefix) && aws.StringValue(s.StackStatus) != cloudformation.StackStatusDeleteComplete {
+				names = append(names, name)
+			}
+		}
+
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("listing stacks of cluster %s: %w", clusterName, err)
+	}
+
+	var stacks []*cloudformation.Stack
+
+	for _, name := range names {
+		res, err := cfn.DescribeStacks(&cloudformation.DescribeStacksInput{StackName: aws.String(name)})
+		if err != nil {
+			return nil, fmt.Errorf("describing stack %s: %w", name, err)
+		}
+
+		for _, s := range res.Stacks {
+			if isEksctlStackOf(s, clusterName) {
+				stacks = append(stacks, s)
+			}
+		}
+	}
+
+	return stacks, nil
+}
+
+func isEksctlStackOf(s *cloudformation.Stack, clusterName string) bool {
+	for _, t := range s.Tags {
+		if aws.StringValue(t.Key) == TagKeyEksctlClusterName && aws.StringValue(t.Value) == clusterName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stackTagsWith returns the stack tags with the updated tags set and the removed tags deleted, preserving the tags added by eksctl
+func stackTagsWith(tags []*cloudformation.Tag, updated map[string]string, removed []string) []*cloudformation.Tag {
+	var r []*cloudformation.Tag
+
+	for _, t := range tags {
+		k := aws.StringValue(t.Key)
+
+		if _, ok := updated[k]; ok || containsString(removed, k) {
+			continue
+		}
+
+		r = append(r, t)
+	}
+
+	var keys []string
+	for k := range updated {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		r = append(r, &cloudformation.Tag{Key: aws.String(k), Value: aws.String(updated[k])})
+	}
+
+	return r
+}
+
+func isNoUpdatesToPerform(err error) bool {
+	aerr, ok := err.(awserr.Error)
+
+	return ok && aerr.Code() == "ValidationError" && strings.Contains(aerr.Message(), "No updates are to be performed")
+}
+
+// updateAutoScalingGroupTags updates the tags of the autoscaling groups of both unmanaged and managed nodegroups, so that they're propagated to new nodes
+func updateAutoScalingGroupTags(as autoscalingiface.AutoScalingAPI, clusterName string, updated map[string]string, removed []string) error {
+	groups, err := clusterAutoScalingGroups(as, clusterName)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+
+	for _, g := range groups {
+		names = append(names, aws.StringValue(g.AutoScalingGroupName))
+	}
+
+	for _, name := range names {
+		var tags []*autoscaling.Tag
+
+		for k, v := range updated {
+			tags = append(tags, asgTag(name, k, v))
+		}
+
+		if len(tags) > 0 {
+			if _, err := as.CreateOrUpdateTags(&autoscaling.CreateOrUpdateTagsInput{Tags: tags}); err != nil {
+				return fmt.Errorf("tagging autoscaling group %s: %w", name, err)
+			}
+		}
+
+		tags = nil
+
+		for _, k := range removed {
+			tags = append(tags, asgTag(name, k, ""))
+		}
+
+		if len(tags) > 0 {
+			if _, err := as.DeleteTags(&autoscaling.DeleteTagsInput{Tags: tags}); err != nil {
+				return fmt.Errorf("untagging autoscaling group %s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// clusterAutoScalingGroups returns the autoscaling groups of the nodegroups of the cluster.
+// Autoscaling groups of managed nodegroups are tagged with `eks:cluster-name` by EKS,
+// whereas the ones of unmanaged nodegroups inherit `alpha.eksctl.io/cluster-name` from their stacks.
+func clusterAutoScalingGroups(as autoscalingiface.AutoScalingAPI, clusterName string) ([]*autoscaling.Group, error) {
+	var groups []*autoscaling.Group
+
+	seen := map[string]bool{}
+
+	for _, k := range []string{TagKeyEKSClusterName, TagKeyEksctlClusterName} {
+		if err := as.DescribeAutoScalingGroupsPages(&autoscaling.DescribeAutoScalingGroupsInput{
+			Filters: []*autoscaling.Filter{
+				{Name: aws.String("tag:" + k), Values: aws.StringSlice([]string{clusterName})},
+			},
+		}, func(out *autoscaling.DescribeAutoScalingGroupsOutput, _ bool) bool {
+			for _, g := range out.AutoScalingGroups {
+				name := aws.StringValue(g.AutoScalingGroupName)
+
+				if !seen[name] {
+					seen[name] = true
+					groups = append(groups, g)
+				}
+			}
+
+			return true
+		}); err != nil {
+			return nil, fmt.Errorf("listing autoscaling groups of cluster %s: %w", clusterName, err)
+		}
+	}
+
+	return groups, nil
+}
+
+func asgTag(groupName, k, v string) *autoscaling.Tag {
+	return &autoscaling.Tag{
+		ResourceId:        aws.String(groupName),
+		ResourceType:      aws.String("auto-scaling-group"),
+		Key:               aws.String(k),
+		Value:             aws.String(v),
+		PropagateAtLaunch: aws.Bool(true),
+	}
+}
+
+// stringMap converts the value of a TypeMap attribute of strings
+func stringMap(v interface{}) map[string]string {
+	r := map[string]string{}
+
+	m, _ := v.(map[string]interface{})
+	for k, v := range m {
+		r[k] = fmt.Sprintf("%v", v)
+	}
+
+	return r
+}
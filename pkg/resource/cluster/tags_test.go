@@ -0,0 +1,251 @@
+package cluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTagChanges(t *testing.T) {
+	old := map[string]string{"team": "a", "env": "prod", "cost-center": "1"}
+	new := map[string]string{"team": "b", "env": "prod", "owner": "x"}
+
+	updated, removed := tagChanges(old, new)
+
+	if d := cmp.Diff(map[string]string{"team": "b", "owner": "x"}, updated); d != "" {
+		t.Errorf("unexpected updated tags: want (-), got (+)\n%s", d)
+	}
+
+	if d := cmp.Diff([]string{"cost-center"}, removed); d != "" {
+		t.Errorf("unexpected removed tags: want (-), got (+)\n%s", d)
+	}
+}
+
+func TestStackTagsWith(t *testing.T) {
+	tags := []*cloudformation.Tag{
+		{Key: aws.String(TagKeyEksctlClusterName), Value: aws.String("mycluster")},
+		{Key: aws.String("team"), Value: aws.String("a")},
+		{Key: aws.String("cost-center"), Value: aws.String("1")},
+	}
+
+	got := map[string]string{}
+	for _, t := range stackTagsWith(tags, map[string]string{"team": "b", "owner": "x"}, []string{"cost-center"}) {
+		got[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+
+	want := map[string]string{TagKeyEksctlClusterName: "mycluster", "team": "b", "owner": "x"}
+
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("unexpected stack tags: want (-), got (+)\n%s", d)
+	}
+}
+
+func TestIsEksctlStackOf(t *testing.T) {
+	s := &cloudformation.Stack{
+		StackName: aws.String("eksctl-mycluster-2-cluster"),
+		Tags:      []*cloudformation.Tag{{Key: aws.String(TagKeyEksctlClusterName), Value: aws.String("mycluster-2")}},
+	}
+
+	if isEksctlStackOf(s, "mycluster") {
+		t.Error("expected the stack of mycluster-2 not to be the one of mycluster")
+	}
+
+	if !isEksctlStackOf(s, "mycluster-2") {
+		t.Error("expected the stack to be the one of mycluster-2")
+	}
+}
+
+// fakeAutoScaling serves the autoscaling groups matching the `tag:<key>` filters, and records the tags created and deleted
+type fakeAutoScaling struct {
+	autoscalingiface.AutoScalingAPI
+
+	groups []*autoscaling.Group
+
+	created, deleted []string
+}
+
+func (f *fakeAutoScaling) DescribeAutoScalingGroupsPages(in *autoscaling.DescribeAutoScalingGroupsInput, fn func(*autoscaling.DescribeAutoScalingGroupsOutput, bool) bool) error {
+	var matched []*autoscaling.Group
+
+	for _, g := range f.groups {
+		ok := true
+
+		for _, filter := range in.Filters {
+			ok = ok && hasASGTag(g, strings.TrimPrefix(aws.StringValue(filter.Name), "tag:"), aws.StringValueSlice(filter.Values))
+		}
+
+		if ok {
+			matched = append(matched, g)
+		}
+	}
+
+	fn(&autoscaling.DescribeAutoScalingGroupsOutput{AutoScalingGroups: matched}, true)
+
+	return nil
+}
+
+func hasASGTag(g *autoscaling.Group, k string, values []string) bool {
+	for _, t := range g.Tags {
+		if aws.StringValue(t.Key) == k && containsString(values, aws.StringValue(t.Value)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *fakeAutoScaling) CreateOrUpdateTags(in *autoscaling.CreateOrUpdateTagsInput) (*autoscaling.CreateOrUpdateTagsOutput, error) {
+	for _, t := range in.Tags {
+		f.created = append(f.created, aws.StringValue(t.ResourceId)+"/"+aws.StringValue(t.Key))
+	}
+
+	return &autoscaling.CreateOrUpdateTagsOutput{}, nil
+}
+
+func (f *fakeAutoScaling) DeleteTags(in *autoscaling.DeleteTagsInput) (*autoscaling.DeleteTagsOutput, error) {
+	for _, t := range in.Tags {
+		f.deleted = append(f.deleted, aws.StringValue(t.ResourceId)+"/"+aws.StringValue(t.Key))
+	}
+
+	return &autoscaling.DeleteTagsOutput{}, nil
+}
+
+func asgWithTags(name string, tags map[string]string) *autoscaling.Group {
+	g := &autoscaling.Group{AutoScalingGroupName: aws.String(name)}
+
+	for k, v := range tags {
+		g.Tags = append(g.Tags, &autoscaling.TagDescription{Key: aws.String(k), Value: aws.String(v)})
+	}
+
+	return g
+}
+
+func TestUpdateAutoScalingGroupTags(t *testing.T) {
+	as := &fakeAutoScaling{
+		groups: []*autoscaling.Group{
+			// Managed nodegroups' autoscaling groups aren't tagged with kubernetes.io/cluster/<name>
+			asgWithTags("eks-ng1-1234", map[string]string{TagKeyEKSClusterName: "mycluster", "eks:nodegroup-name": "ng1"}),
+			asgWithTags("eksctl-mycluster-nodegroup-ng2-NodeGroup-ABCD", map[string]string{
+				TagKeyEksctlClusterName:           "mycluster",
+				"kubernetes.io/cluster/mycluster": "owned",
+			}),
+			asgWithTags("eks-ng1-5678", map[string]string{TagKeyEKSClusterName: "mycluster-2"}),
+		},
+	}
+
+	if err := updateAutoScalingGroupTags(as, "mycluster", map[string]string{"team": "b"}, []string{"cost-center"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantCreated := []string{"eks-ng1-1234/team", "eksctl-mycluster-nodegroup-ng2-NodeGroup-ABCD/team"}
+
+	if d := cmp.Diff(wantCreated, as.created); d != "" {
+		t.Errorf("unexpected tagged autoscaling groups: want (-), got (+)\n%s", d)
+	}
+
+	wantDeleted := []string{"eks-ng1-1234/cost-center", "eksctl-mycluster-nodegroup-ng2-NodeGroup-ABCD/cost-center"}
+
+	if d := cmp.Diff(wantDeleted, as.deleted); d != "" {
+		t.Errorf("unexpected untagged autoscaling groups: want (-), got (+)\n%s", d)
+	}
+}
+
+// fakeCloudFormation serves the stacks, and records the stacks described and updated
+type fakeCloudFormation struct {
+	cloudformationiface.CloudFormationAPI
+
+	stacks []*cloudformation.Stack
+
+	// upToDate are the stacks whose tags are already up to date
+	upToDate []string
+
+	described, updated []string
+}
+
+func (f *fakeCloudFormation) ListStacksPages(_ *cloudformation.ListStacksInput, fn func(*cloudformation.ListStacksOutput, bool) bool) error {
+	out := &cloudformation.ListStacksOutput{}
+
+	for _, s := range f.stacks {
+		out.StackSummaries = append(out.StackSummaries, &cloudformation.StackSummary{StackName: s.StackName, StackStatus: s.StackStatus})
+	}
+
+	fn(out, true)
+
+	return nil
+}
+
+func (f *fakeCloudFormation) DescribeStacks(in *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	f.described = append(f.described, aws.StringValue(in.StackName))
+
+	for _, s := range f.stacks {
+		if aws.StringValue(s.StackName) == aws.StringValue(in.StackName) {
+			return &cloudformation.DescribeStacksOutput{Stacks: []*cloudformation.Stack{s}}, nil
+		}
+	}
+
+	return nil, awserr.New("ValidationError", "Stack does not exist", nil)
+}
+
+func (f *fakeCloudFormation) UpdateStack(in *cloudformation.UpdateStackInput) (*cloudformation.UpdateStackOutput, error) {
+	name := aws.StringValue(in.StackName)
+
+	if containsString(f.upToDate, name) {
+		return nil, awserr.New("ValidationError", "No updates are to be performed.", nil)
+	}
+
+	f.updated = append(f.updated, name)
+
+	return &cloudformation.UpdateStackOutput{}, nil
+}
+
+func (f *fakeCloudFormation) WaitUntilStackUpdateCompleteWithContext(_ aws.Context, _ *cloudformation.DescribeStacksInput, _ ...request.WaiterOption) error {
+	return nil
+}
+
+func stackOf(name, clusterName, status string) *cloudformation.Stack {
+	return &cloudformation.Stack{
+		StackName:   aws.String(name),
+		StackStatus: aws.String(status),
+		Tags:        []*cloudformation.Tag{{Key: aws.String(TagKeyEksctlClusterName), Value: aws.String(clusterName)}},
+	}
+}
+
+func TestUpdateStackTags(t *testing.T) {
+	cfn := &fakeCloudFormation{
+		stacks: []*cloudformation.Stack{
+			stackOf("eksctl-mycluster-cluster", "mycluster", cloudformation.StackStatusUpdateComplete),
+			stackOf("eksctl-mycluster-nodegroup-ng1", "mycluster", cloudformation.StackStatusCreateComplete),
+			stackOf("eksctl-mycluster-nodegroup-ng2", "mycluster", cloudformation.StackStatusUpdateInProgress),
+			stackOf("eksctl-mycluster-addon-iamserviceaccount-kube-system-aws-node", "mycluster", cloudformation.StackStatusCreateComplete),
+			stackOf("eksctl-mycluster-2-cluster", "mycluster-2", cloudformation.StackStatusCreateComplete),
+			stackOf("eksctl-othercluster-cluster", "othercluster", cloudformation.StackStatusCreateComplete),
+		},
+		upToDate: []string{"eksctl-mycluster-addon-iamserviceaccount-kube-system-aws-node"},
+	}
+
+	err := updateStackTags(context.Background(), cfn, "mycluster", map[string]string{"team": "b"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "eksctl-mycluster-nodegroup-ng2: stack is in UPDATE_IN_PROGRESS state") {
+		t.Errorf("expected error for the stack being updated, got: %v", err)
+	}
+
+	for _, name := range cfn.described {
+		if !strings.HasPrefix(name, "eksctl-mycluster-") {
+			t.Errorf("unexpected stack described: %s", name)
+		}
+	}
+
+	want := []string{"eksctl-mycluster-cluster", "eksctl-mycluster-nodegroup-ng1"}
+
+	if d := cmp.Diff(want, cfn.updated); d != "" {
+		t.Errorf("unexpected updated stacks: want (-), got (+)\n%s", d)
+	}
+}
@@ -3,10 +3,15 @@ package cluster
 import (
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
 	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
 	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
 	"log"
+	"strings"
 )
 
 const (
@@ -53,14 +58,264 @@ func getTargetGroupARNs(sess *session.Session, clusterNamePrefixy string) ([]str
 	return arns, nil
 }
 
+// deleteTargetGroups deletes the target groups of the cluster, after removing the listener rules forwarding to them
+// and detaching them from autoscaling groups, both of which prevent target groups from being deleted.
+// Failures don't stop the rest of the target groups from being cleaned up, and are reported together.
 func deleteTargetGroups(set *ClusterSet) error {
-	elb := elbv2.New(AWSSessionFromCluster(set.Cluster))
+	sess := AWSSessionFromCluster(set.Cluster)
+
+	elb := elbv2.New(sess)
+	as := autoscaling.New(sess)
+
+	var failures []string
 
 	for _, tgARN := range set.Cluster.TargetGroupARNs {
 		log.Printf("Deleting target group %s for %s", tgARN, set.ClusterName)
 
-		if _, err := elb.DeleteTargetGroup(&elbv2.DeleteTargetGroupInput{TargetGroupArn: aws.String(tgARN)}); err != nil {
-			return fmt.Errorf("deleting target group %s for %s", tgARN, set.ClusterName)
+		if err := deleteTargetGroup(elb, as, tgARN); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", tgARN, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("deleting %d of %d target groups for %s failed. "+
+			"Remove the listener rules forwarding to them and delete them manually, or retry:\n%s",
+			len(failures), len(set.Cluster.TargetGroupARNs), set.ClusterName, strings.Join(failures, "\n"))
+	}
+
+	return nil
+}
+
+func deleteTargetGroup(elb elbv2iface.ELBV2API, as autoscalingiface.AutoScalingAPI, tgARN string) error {
+	res, err := elb.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{
+		TargetGroupArns: aws.StringSlice([]string{tgARN}),
+	})
+	if isTargetGroupNotFound(err) {
+		log.Printf("[DEBUG] target group %s has already been deleted", tgARN)
+
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("describing target group: %w", err)
+	}
+
+	for _, tg := range res.TargetGroups {
+		for _, lbARN := range tg.LoadBalancerArns {
+			if err := removeListenerRulesForwardingTo(elb, aws.StringValue(lbARN), tgARN); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := detachTargetGroupFromAutoScalingGroups(as, tgARN); err != nil {
+		return err
+	}
+
+	if _, err := elb.DeleteTargetGroup(&elbv2.DeleteTargetGroupInput{TargetGroupArn: aws.String(tgARN)}); err != nil && !isTargetGroupNotFound(err) {
+		return fmt.Errorf("deleting target group: %w", err)
+	}
+
+	return nil
+}
+
+func isTargetGroupNotFound(err error) bool {
+	aerr, ok := err.(awserr.Error)
+
+	return ok && aerr.Code() == elbv2.ErrCodeTargetGroupNotFoundException
+}
+
+// removeListenerRulesForwardingTo deletes the rules of the load balancer's listeners that forward only to the target group,
+// and repoints the rules that also forward to other target groups to the others.
+func removeListenerRulesForwardingTo(elb elbv2iface.ELBV2API, lbARN, tgARN string) error {
+	return forEachListenerRule(elb, lbARN, func(listenerARN string, r *elbv2.Rule) error {
+		actions, forwarded := actionsWithoutTargetGroup(r.Actions, tgARN)
+		if !forwarded {
+			return nil
+		}
+
+		ruleARN := aws.StringValue(r.RuleArn)
+
+		switch {
+		case actions != nil:
+			log.Printf("[DEBUG] repointing listener rule %s away from target group %s", ruleARN, tgARN)
+
+			if _, err := elb.ModifyRule(&elbv2.ModifyRuleInput{RuleArn: r.RuleArn, Actions: actions}); err != nil {
+				return fmt.Errorf("repointing listener rule %s to the other target groups: %w", ruleARN, err)
+			}
+		case aws.BoolValue(r.IsDefault):
+			return fmt.Errorf("the default action of listener %s forwards to the target group. Change it to forward elsewhere", listenerARN)
+		default:
+			log.Printf("[DEBUG] deleting listener rule %s forwarding to target group %s", ruleARN, tgARN)
+
+			if _, err := elb.DeleteRule(&elbv2.DeleteRuleInput{RuleArn: r.RuleArn}); err != nil {
+				return fmt.Errorf("deleting listener rule %s: %w", ruleARN, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// forEachListenerRule calls the function with every rule of every listener of the load balancer
+func forEachListenerRule(elb elbv2iface.ELBV2API, lbARN string, f func(listenerARN string, r *elbv2.Rule) error) error {
+	var listeners []*elbv2.Listener
+
+	if err := elb.DescribeListenersPages(&elbv2.DescribeListenersInput{LoadBalancerArn: aws.String(lbARN)}, func(res *elbv2.DescribeListenersOutput, _ bool) bool {
+		listeners = append(listeners, res.Listeners...)
+
+		return true
+	}); err != nil {
+		return fmt.Errorf("describing listeners of %s: %w", lbARN, err)
+	}
+
+	for _, l := range listeners {
+		listenerARN := aws.StringValue(l.ListenerArn)
+
+		var rules []*elbv2.Rule
+
+		var marker *string
+
+		for {
+			res, err := elb.DescribeRules(&elbv2.DescribeRulesInput{ListenerArn: l.ListenerArn, Marker: marker})
+			if err != nil {
+				return fmt.Errorf("describing rules of listener %s: %w", listenerARN, err)
+			}
+
+			rules = append(rules, res.Rules...)
+
+			marker = res.NextMarker
+
+			if marker == nil {
+				break
+			}
+		}
+
+		for _, r := range rules {
+			if err := f(listenerARN, r); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// forwardedTargetGroups returns the target groups the forward action forwards to
+func forwardedTargetGroups(a *elbv2.Action) []*elbv2.TargetGroupTuple {
+	if aws.StringValue(a.Type) != elbv2.ActionTypeEnumForward {
+		return nil
+	}
+
+	if a.ForwardConfig != nil && len(a.ForwardConfig.TargetGroups) > 0 {
+		return a.ForwardConfig.TargetGroups
+	}
+
+	if a.TargetGroupArn != nil {
+		return []*elbv2.TargetGroupTuple{{TargetGroupArn: a.TargetGroupArn}}
+	}
+
+	return nil
+}
+
+// actionsWithoutTargetGroup returns the rule actions with the target group removed from the forward action,
+// and whether the rule forwarded to the target group at all.
+// It returns nil actions when the target group was the only one forwarded to, in which case the rule needs to be deleted.
+// The remaining target groups are given equal weights when they had no weight, so that they receive the traffic.
+func actionsWithoutTargetGroup(actions []*elbv2.Action, tgARN string) ([]*elbv2.Action, bool) {
+	var (
+		result    []*elbv2.Action
+		forwarded bool
+		remaining bool
+	)
+
+	for _, a := range actions {
+		if aws.StringValue(a.Type) != elbv2.ActionTypeEnumForward {
+			result = append(result, a)
+
+			continue
+		}
+
+		tuples := forwardedTargetGroups(a)
+
+		var (
+			kept        []*elbv2.TargetGroupTuple
+			totalWeight int64
+		)
+
+		for _, t := range tuples {
+			if aws.StringValue(t.TargetGroupArn) == tgARN {
+				forwarded = true
+
+				continue
+			}
+
+			kept = append(kept, &elbv2.TargetGroupTuple{TargetGroupArn: t.TargetGroupArn, Weight: t.Weight})
+			totalWeight += aws.Int64Value(t.Weight)
+		}
+
+		if len(kept) == 0 {
+			continue
+		}
+
+		if totalWeight == 0 {
+			for _, t := range kept {
+				t.Weight = aws.Int64(1)
+			}
+		}
+
+		remaining = true
+
+		forward := &elbv2.Action{
+			Type:  a.Type,
+			Order: a.Order,
+			ForwardConfig: &elbv2.ForwardActionConfig{
+				TargetGroups: kept,
+			},
+		}
+
+		if a.ForwardConfig != nil {
+			forward.ForwardConfig.TargetGroupStickinessConfig = a.ForwardConfig.TargetGroupStickinessConfig
+		}
+
+		result = append(result, forward)
+	}
+
+	if !forwarded {
+		return actions, false
+	}
+
+	if !remaining {
+		return nil, true
+	}
+
+	return result, true
+}
+
+// detachTargetGroupFromAutoScalingGroups detaches the target group from the nodegroups' autoscaling groups
+func detachTargetGroupFromAutoScalingGroups(as autoscalingiface.AutoScalingAPI, tgARN string) error {
+	var asgNames []string
+
+	if err := as.DescribeAutoScalingGroupsPages(&autoscaling.DescribeAutoScalingGroupsInput{}, func(res *autoscaling.DescribeAutoScalingGroupsOutput, _ bool) bool {
+		for _, g := range res.AutoScalingGroups {
+			for _, arn := range g.TargetGroupARNs {
+				if aws.StringValue(arn) == tgARN {
+					asgNames = append(asgNames, aws.StringValue(g.AutoScalingGroupName))
+				}
+			}
+		}
+
+		return true
+	}); err != nil {
+		return fmt.Errorf("describing autoscaling groups: %w", err)
+	}
+
+	for _, name := range asgNames {
+		log.Printf("[DEBUG] detaching target group %s from autoscaling group %s", tgARN, name)
+
+		if _, err := as.DetachLoadBalancerTargetGroups(&autoscaling.DetachLoadBalancerTargetGroupsInput{
+			AutoScalingGroupName: aws.String(name),
+			TargetGroupARNs:      aws.StringSlice([]string{tgARN}),
+		}); err != nil {
+			return fmt.Errorf("detaching target group from autoscaling group %s: %w", name, err)
 		}
 	}
 
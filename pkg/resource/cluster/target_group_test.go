@@ -0,0 +1,138 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/google/go-cmp/cmp"
+)
+
+func forwardAction(weights map[string]int64) *elbv2.Action {
+	a := &elbv2.Action{
+		Type:          aws.String(elbv2.ActionTypeEnumForward),
+		ForwardConfig: &elbv2.ForwardActionConfig{},
+	}
+
+	for _, arn := range []string{"tg1", "tg2", "tg3"} {
+		if w, ok := weights[arn]; ok {
+			a.ForwardConfig.TargetGroups = append(a.ForwardConfig.TargetGroups, &elbv2.TargetGroupTuple{
+				TargetGroupArn: aws.String(arn),
+				Weight:         aws.Int64(w),
+			})
+		}
+	}
+
+	return a
+}
+
+func TestActionsWithoutTargetGroup(t *testing.T) {
+	testcases := []struct {
+		name          string
+		actions       []*elbv2.Action
+		wantActions   []*elbv2.Action
+		wantForwarded bool
+	}{
+		{
+			name:          "only target group",
+			actions:       []*elbv2.Action{{Type: aws.String(elbv2.ActionTypeEnumForward), TargetGroupArn: aws.String("tg1")}},
+			wantActions:   nil,
+			wantForwarded: true,
+		},
+		{
+			name:          "weighted target groups",
+			actions:       []*elbv2.Action{forwardAction(map[string]int64{"tg1": 80, "tg2": 20, "tg3": 0})},
+			wantActions:   []*elbv2.Action{forwardAction(map[string]int64{"tg2": 20, "tg3": 0})},
+			wantForwarded: true,
+		},
+		{
+			name:          "remaining target group without weight",
+			actions:       []*elbv2.Action{forwardAction(map[string]int64{"tg1": 100, "tg2": 0})},
+			wantActions:   []*elbv2.Action{forwardAction(map[string]int64{"tg2": 1})},
+			wantForwarded: true,
+		},
+		{
+			name:          "other target groups",
+			actions:       []*elbv2.Action{forwardAction(map[string]int64{"tg2": 100})},
+			wantActions:   []*elbv2.Action{forwardAction(map[string]int64{"tg2": 100})},
+			wantForwarded: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			actions, forwarded := actionsWithoutTargetGroup(tc.actions, "tg1")
+
+			if forwarded != tc.wantForwarded {
+				t.Errorf("unexpected forwarded: want %v, got %v", tc.wantForwarded, forwarded)
+			}
+
+			if d := cmp.Diff(tc.wantActions, actions); d != "" {
+				t.Errorf("unexpected actions: want (-), got (+)\n%s", d)
+			}
+		})
+	}
+}
+
+type fakeListenerRules struct {
+	elbv2iface.ELBV2API
+
+	rules    []*elbv2.Rule
+	deleted  []string
+	modified []string
+}
+
+func (f *fakeListenerRules) DescribeListenersPages(_ *elbv2.DescribeListenersInput, fn func(*elbv2.DescribeListenersOutput, bool) bool) error {
+	fn(&elbv2.DescribeListenersOutput{Listeners: []*elbv2.Listener{{ListenerArn: aws.String("listener1")}}}, true)
+
+	return nil
+}
+
+func (f *fakeListenerRules) DescribeRules(_ *elbv2.DescribeRulesInput) (*elbv2.DescribeRulesOutput, error) {
+	return &elbv2.DescribeRulesOutput{Rules: f.rules}, nil
+}
+
+func (f *fakeListenerRules) DeleteRule(i *elbv2.DeleteRuleInput) (*elbv2.DeleteRuleOutput, error) {
+	f.deleted = append(f.deleted, *i.RuleArn)
+
+	return &elbv2.DeleteRuleOutput{}, nil
+}
+
+func (f *fakeListenerRules) ModifyRule(i *elbv2.ModifyRuleInput) (*elbv2.ModifyRuleOutput, error) {
+	f.modified = append(f.modified, *i.RuleArn)
+
+	return &elbv2.ModifyRuleOutput{}, nil
+}
+
+func TestRemoveListenerRulesForwardingTo(t *testing.T) {
+	elb := &fakeListenerRules{
+		rules: []*elbv2.Rule{
+			{RuleArn: aws.String("rule1"), Actions: []*elbv2.Action{forwardAction(map[string]int64{"tg1": 100})}},
+			{RuleArn: aws.String("rule2"), Actions: []*elbv2.Action{forwardAction(map[string]int64{"tg1": 0, "tg2": 100})}},
+			{RuleArn: aws.String("rule3"), Actions: []*elbv2.Action{forwardAction(map[string]int64{"tg3": 100})}},
+		},
+	}
+
+	if err := removeListenerRulesForwardingTo(elb, "lb1", "tg1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if d := cmp.Diff([]string{"rule1"}, elb.deleted); d != "" {
+		t.Errorf("unexpected deleted rules: want (-), got (+)\n%s", d)
+	}
+
+	if d := cmp.Diff([]string{"rule2"}, elb.modified); d != "" {
+		t.Errorf("unexpected modified rules: want (-), got (+)\n%s", d)
+	}
+
+	elb = &fakeListenerRules{
+		rules: []*elbv2.Rule{
+			{RuleArn: aws.String("default"), IsDefault: aws.Bool(true), Actions: []*elbv2.Action{forwardAction(map[string]int64{"tg1": 100})}},
+		},
+	}
+
+	if err := removeListenerRulesForwardingTo(elb, "lb1", "tg1"); err == nil {
+		t.Error("expected error for the default action forwarding to the target group")
+	}
+}
@@ -12,7 +12,7 @@ import (
 	"time"
 )
 
-func graduallyShiftTraffic(set *ClusterSet, opts courier.CanaryOpts) error {
+func graduallyShiftTraffic(ctx context.Context, set *ClusterSet, opts courier.CanaryOpts) error {
 	cluster := set.Cluster
 
 	svc := elbv2.New(AWSSessionFromCluster(cluster))
@@ -30,7 +30,7 @@ func graduallyShiftTraffic(set *ClusterSet, opts courier.CanaryOpts) error {
 		}
 	}
 
-	return m.SwitchTargetGroup(listenerStatuses, opts)
+	return m.SwitchTargetGroup(ctx, listenerStatuses, opts)
 }
 
 type ALBRouter struct {
@@ -44,14 +44,14 @@ type CanaryConfig struct {
 	ClusterName string
 }
 
-func (m *ALBRouter) SwitchTargetGroup(listenerStatuses ListenerStatuses, opts courier.CanaryOpts) error {
+func (m *ALBRouter) SwitchTargetGroup(ctx context.Context, listenerStatuses ListenerStatuses, opts courier.CanaryOpts) error {
 	svc := m.ELBV2
 
 	if len(listenerStatuses) == 0 {
 		return nil
 	}
 
-	tCtx, cancel := context.WithCancel(context.Background())
+	tCtx, cancel := context.WithCancel(ctx)
 	g, gctx := errgroup.WithContext(tCtx)
 
 	wg := &sync.WaitGroup{}
@@ -59,8 +59,12 @@ func (m *ALBRouter) SwitchTargetGroup(listenerStatuses ListenerStatuses, opts co
 	for i := range listenerStatuses {
 		l := listenerStatuses[i]
 
+		wg.Add(1)
+
 		g.Go(func() error {
-			return courier.DoGradualTrafficShift(tCtx, svc, l, 1, opts)
+			defer wg.Done()
+
+			return courier.DoGradualTrafficShift(gctx, svc, l, 1, opts)
 		})
 	}
 
@@ -86,6 +90,7 @@ func (m *ALBRouter) SwitchTargetGroup(listenerStatuses ListenerStatuses, opts co
 		})
 	}
 
+	// Stop the analyzers once all the listeners finished shifting traffic
 	go func() {
 		defer cancel()
 
@@ -0,0 +1,192 @@
+package cluster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/api"
+)
+
+const (
+	// KeyUpdateCheckpoint records the operations completed by a failed update, so that the retried update can resume
+	// at the failed operation
+	KeyUpdateCheckpoint = "update_checkpoint"
+	// KeyUpdateTasks records the name, duration and error of each operation run by the last update
+	KeyUpdateTasks = "update_tasks"
+)
+
+func updateCheckpointSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Computed: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"config_hash": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"completed_operations": {
+					Type:     schema.TypeList,
+					Computed: true,
+					Elem: &schema.Schema{
+						Type: schema.TypeString,
+					},
+				},
+			},
+		},
+	}
+}
+
+func updateTasksSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Computed: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"duration": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"error": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+			},
+		},
+	}
+}
+
+// updateCheckpoint is the set of operations already completed for the cluster config identified by ConfigHash
+type updateCheckpoint struct {
+	ConfigHash string
+	Completed  []string
+}
+
+type taskResult struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+func clusterConfigHash(clusterConfig []byte) string {
+	sum := sha256.Sum256(clusterConfig)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// readUpdateCheckpoint returns the checkpoint for the cluster config.
+// The checkpoint left by a failed update is discarded when the cluster config has changed since then,
+// because the completed operations may not be valid for the new config.
+func readUpdateCheckpoint(d api.Getter, configHash string) *updateCheckpoint {
+	c := &updateCheckpoint{ConfigHash: configHash}
+
+	l, ok := d.Get(KeyUpdateCheckpoint).([]interface{})
+	if !ok || len(l) == 0 || l[0] == nil {
+		return c
+	}
+
+	m := l[0].(map[string]interface{})
+
+	if h, _ := m["config_hash"].(string); h != configHash {
+		log.Printf("[DEBUG] discarding update checkpoint for cluster config %s, as the cluster config has changed to %s", h, configHash)
+
+		return c
+	}
+
+	if ops, ok := m["completed_operations"].([]interface{}); ok {
+		for _, op := range ops {
+			c.Completed = append(c.Completed, op.(string))
+		}
+	}
+
+	return c
+}
+
+func (c *updateCheckpoint) IsCompleted(op Operation) bool {
+	for _, completed := range c.Completed {
+		if completed == op.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *updateCheckpoint) Complete(op Operation) {
+	c.Completed = append(c.Completed, op.String())
+}
+
+func (c *updateCheckpoint) toState() []interface{} {
+	if len(c.Completed) == 0 {
+		return []interface{}{}
+	}
+
+	var completed []interface{}
+
+	for _, op := range c.Completed {
+		completed = append(completed, op)
+	}
+
+	return []interface{}{
+		map[string]interface{}{
+			"config_hash":          c.ConfigHash,
+			"completed_operations": completed,
+		},
+	}
+}
+
+func taskResultsToState(results []taskResult) []interface{} {
+	tasks := []interface{}{}
+
+	for _, r := range results {
+		var errMsg string
+
+		if r.Err != nil {
+			errMsg = r.Err.Error()
+		}
+
+		tasks = append(tasks, map[string]interface{}{
+			"name":     r.Name,
+			"duration": r.Duration.Round(time.Second).String(),
+			"error":    errMsg,
+		})
+	}
+
+	return tasks
+}
+
+// saveUpdateProgress persists the checkpoint and the task results.
+//
+// On failure, it turns on the partial state mode so that only the progress is persisted.
+// Otherwise Terraform would persist the desired config as if the update succeeded, and the retried `terraform apply`
+// would have nothing to update.
+func saveUpdateProgress(d *schema.ResourceData, c *updateCheckpoint, results []taskResult, failed bool) error {
+	if failed {
+		d.Partial(true)
+
+		for _, k := range []string{KeyUpdateCheckpoint, KeyUpdateTasks, KeyKubeconfigPath, sdk.KeyOutput} {
+			d.SetPartial(k)
+		}
+	} else {
+		c = &updateCheckpoint{}
+	}
+
+	if err := d.Set(KeyUpdateCheckpoint, c.toState()); err != nil {
+		return fmt.Errorf("setting %s: %w", KeyUpdateCheckpoint, err)
+	}
+
+	if err := d.Set(KeyUpdateTasks, taskResultsToState(results)); err != nil {
+		return fmt.Errorf("setting %s: %w", KeyUpdateTasks, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package cluster
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func TestUpdateCheckpoint(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, ResourceCluster().Schema, map[string]interface{}{
+		KeyName: "mycluster",
+		KeySpec: "nodeGroups: []",
+	})
+	d.SetId("mycluster")
+
+	hash := clusterConfigHash([]byte("cluster.yaml"))
+
+	c := readUpdateCheckpoint(d, hash)
+
+	created := Operation{Name: OpCreateNodeGroup, Targets: []string{"ng2"}}
+	deleted := Operation{Name: OpDeleteNodeGroup, Targets: []string{"ng1"}}
+
+	c.Complete(created)
+
+	results := []taskResult{
+		{Name: created.String(), Duration: 3 * time.Minute},
+		{Name: deleted.String(), Duration: time.Second, Err: errors.New("timed out")},
+	}
+
+	if err := saveUpdateProgress(d, c, results, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if c := readUpdateCheckpoint(d, hash); !c.IsCompleted(created) || c.IsCompleted(deleted) {
+		t.Errorf("unexpected checkpoint: %+v", c)
+	}
+
+	if c := readUpdateCheckpoint(d, clusterConfigHash([]byte("changed"))); c.IsCompleted(created) {
+		t.Errorf("expected the checkpoint for another cluster config to be discarded, but got %+v", c)
+	}
+
+	tasks := d.Get(KeyUpdateTasks).([]interface{})
+	if len(tasks) != 2 {
+		t.Fatalf("unexpected number of tasks: %d", len(tasks))
+	}
+
+	if got := tasks[1].(map[string]interface{}); got["name"] != "delete nodegroup ng1" || got["duration"] != "1s" || got["error"] != "timed out" {
+		t.Errorf("unexpected task: %v", got)
+	}
+
+	// The desired config must not be persisted, so that the retried apply would run the update again
+	if v, ok := d.State().Attributes[KeySpec]; ok {
+		t.Errorf("expected %s not to be persisted, but got %q", KeySpec, v)
+	}
+
+	if v := d.State().Attributes[KeyUpdateCheckpoint+".0.completed_operations.0"]; v != "create nodegroup ng2" {
+		t.Errorf("expected the checkpoint to be persisted, but got %q", v)
+	}
+}
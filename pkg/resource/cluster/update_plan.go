@@ -0,0 +1,667 @@
+package cluster
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/api"
+)
+
+const (
+	KeyPlannedOperations     = "planned_operations"
+	KeyMaxParallelOperations = "max_parallel_operations"
+)
+
+// Operations that updateCluster may run, in the order of execution when run one by one.
+// See https://eksctl.io/usage/cluster-upgrade/ for why the cluster is upgraded before anything else.
+const (
+	OpUpgradeCluster                 = "upgrade cluster"
+	OpUpdateKubeProxy                = "utils update-kube-proxy"
+	OpUpdateAWSNode                  = "utils update-aws-node"
+	OpUpdateCoreDNS                  = "utils update-coredns"
+	OpUpdateClusterLogging           = "utils update-cluster-logging"
+	OpUpdateClusterEndpoints         = "utils update-cluster-endpoints"
+	OpSetPublicAccessCIDRs           = "utils set-public-access-cidrs"
+	OpEnableSecretsEncryption        = "utils enable-secrets-encryption"
+	OpUpdateAuthenticationMode       = "utils update-authentication-mode"
+	OpUpdateAddon                    = "update addon"
+	OpUpgradeNodeGroup               = "upgrade nodegroup"
+	OpCreateNodeGroup                = "create nodegroup"
+	OpScaleNodeGroup                 = "scale nodegroup"
+	OpAssociateIAMOIDCProvider       = "utils associate-iam-oidc-provider"
+	OpCreateIAMServiceAccount        = "create iamserviceaccount"
+	OpDeleteFargateProfile           = "delete fargateprofile"
+	OpCreateFargateProfile           = "create fargateprofile"
+	OpCreateAddon                    = "create addon"
+	OpDeleteAddon                    = "delete addon"
+	OpEnableRepo                     = "enable repo"
+	OpDrainNodeGroup                 = "drain nodegroup"
+	OpUpdateAccessEntry              = "update accessentry"
+	OpUpdateIAMIdentityMapping       = "update iamidentitymapping"
+	OpDeleteNodeGroup                = "delete nodegroup"
+	OpDeleteIAMServiceAccount        = "delete iamserviceaccount"
+	OpUpdateTags                     = "update tags"
+	OpUpdateSubnetTags               = "update subnet tags"
+	OpApplyKubernetesManifests       = "apply manifests"
+	OpAttachNodeGroupsToTargetGroups = "attach target groups"
+	OpCheckPodsReadiness             = "check pods readiness"
+	OpWriteKubeconfig                = "utils write-kubeconfig"
+)
+
+// Operations that updateClusterDeployment runs instead whenever it replaces the cluster
+const (
+	OpCreateCluster = "create cluster"
+	OpShiftTraffic  = "shift traffic"
+	OpDeleteCluster = "delete cluster"
+)
+
+// operationDependencies declares the operations that must complete before each operation.
+// Operations without dependencies on each other may run concurrently.
+var operationDependencies = map[string][]string{
+	OpUpdateKubeProxy: {OpUpgradeCluster},
+	OpUpdateAWSNode:   {OpUpgradeCluster},
+	OpUpdateCoreDNS:   {OpUpgradeCluster},
+	// EKS fails to start an update of the cluster config while another one is in progress, so they run one by one
+	OpUpdateClusterLogging:    {OpUpgradeCluster},
+	OpUpdateClusterEndpoints:  {OpUpgradeCluster, OpUpdateClusterLogging},
+	OpSetPublicAccessCIDRs:    {OpUpgradeCluster, OpUpdateClusterLogging, OpUpdateClusterEndpoints},
+	OpEnableSecretsEncryption: {OpUpgradeCluster, OpUpdateClusterLogging, OpUpdateClusterEndpoints, OpSetPublicAccessCIDRs},
+	OpUpdateAuthenticationMode: {
+		OpUpgradeCluster, OpUpdateClusterLogging, OpUpdateClusterEndpoints, OpSetPublicAccessCIDRs, OpEnableSecretsEncryption,
+	},
+	// Add-ons are updated after the control plane, and may use IAM roles for service accounts
+	OpCreateAddon: {OpUpgradeCluster, OpAssociateIAMOIDCProvider},
+	OpUpdateAddon: {OpUpgradeCluster, OpAssociateIAMOIDCProvider, OpUpdateAddon},
+	OpDeleteAddon: {OpUpgradeCluster},
+	// Managed nodegroups are upgraded after the control plane and the add-ons of the same version
+	OpUpgradeNodeGroup: {OpUpgradeCluster, OpUpdateKubeProxy, OpUpdateAWSNode, OpUpdateCoreDNS, OpUpdateAddon},
+	// Nodes of the new nodegroups are created with the upgraded version of the control plane and the add-ons
+	OpCreateNodeGroup:          {OpUpgradeCluster, OpUpdateKubeProxy, OpUpdateAWSNode, OpUpdateCoreDNS, OpCreateAddon, OpUpdateAddon},
+	OpScaleNodeGroup:           {OpUpgradeCluster, OpUpgradeNodeGroup},
+	OpAssociateIAMOIDCProvider: {OpUpgradeCluster},
+	OpCreateIAMServiceAccount:  {OpUpgradeCluster, OpAssociateIAMOIDCProvider},
+	OpDeleteFargateProfile:     {OpUpgradeCluster},
+	// Fargate profiles whose settings have changed are deleted before being created again with the same names
+	OpCreateFargateProfile: {OpUpgradeCluster, OpDeleteFargateProfile},
+	OpEnableRepo:           {OpUpgradeCluster, OpCreateNodeGroup},
+	OpDrainNodeGroup:       {OpCreateNodeGroup},
+	// `eksctl create nodegroup` and `eksctl delete nodegroup` also modify the aws-auth configmap
+	// Principals migrating from aws-auth to access entries are granted the access by the new access entries first
+	OpUpdateIAMIdentityMapping:       {OpUpgradeCluster, OpCreateNodeGroup, OpUpdateAccessEntry},
+	OpAttachNodeGroupsToTargetGroups: {OpCreateNodeGroup},
+	// Pods are evicted from the nodegroups being deleted only after the new nodegroups are ready to serve traffic
+	OpDeleteNodeGroup:         {OpCreateNodeGroup, OpDrainNodeGroup, OpUpdateIAMIdentityMapping, OpAttachNodeGroupsToTargetGroups},
+	OpDeleteIAMServiceAccount: {OpCreateIAMServiceAccount},
+	// Manifests may create load balancers, which are placed in the subnets discovered by the tags
+	OpApplyKubernetesManifests: {OpUpgradeCluster, OpCreateNodeGroup, OpCreateIAMServiceAccount, OpCreateFargateProfile, OpUpdateSubnetTags},
+	OpWriteKubeconfig:          {OpUpgradeCluster},
+	// Access entries can be created only after the cluster is switched to API_AND_CONFIG_MAP or API
+	OpUpdateAccessEntry: {OpUpgradeCluster, OpUpdateAuthenticationMode},
+	// CloudFormation fails to update the tags of stacks being updated by the other operations
+	OpUpdateTags: {
+		OpUpgradeCluster, OpCreateAddon, OpUpdateAddon, OpDeleteAddon, OpUpgradeNodeGroup, OpCreateNodeGroup, OpScaleNodeGroup,
+		OpAssociateIAMOIDCProvider, OpCreateIAMServiceAccount, OpDeleteFargateProfile, OpCreateFargateProfile, OpDeleteNodeGroup, OpDeleteIAMServiceAccount,
+	},
+}
+
+// dependenciesOf returns the operations, in the form of Operation.String(), that must complete before the operation.
+//
+// Operations of a Kubernetes version upgrade step wait for all the operations of the previous steps,
+// and never for the ones of the later steps or the ones outside the upgrade.
+// Pods readiness is checked only after all the other operations complete.
+func dependenciesOf(op Operation, ops []Operation) []string {
+	var deps []string
+
+	for _, o := range ops {
+		if o.String() == op.String() {
+			continue
+		}
+
+		var dep bool
+
+		switch {
+		case op.Name == OpCheckPodsReadiness:
+			dep = true
+		case op.Version != "" && o.Version != "":
+			c := compareVersions(o.Version, op.Version)
+			dep = c < 0 || c == 0 && containsString(operationDependencies[op.Name], o.Name)
+		case op.Version != "":
+			// Upgrade steps never wait for the other operations, which in turn may wait for the upgrade
+			dep = false
+		default:
+			dep = containsString(operationDependencies[op.Name], o.Name)
+		}
+
+		if dep {
+			deps = append(deps, o.String())
+		}
+	}
+
+	return deps
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Operation is a single step of a cluster update.
+// Targets are the names of the nodegroups, service accounts, etc. the operation applies to, if any.
+// Version is the Kubernetes version of the upgrade step the operation belongs to, if any.
+type Operation struct {
+	Name    string
+	Targets []string
+	Version string
+}
+
+func (o Operation) String() string {
+	s := o.Name
+
+	if len(o.Targets) > 0 {
+		s += " " + strings.Join(o.Targets, ",")
+	}
+
+	if o.Version != "" {
+		s += " " + o.Version
+	}
+
+	return s
+}
+
+// ChangeGetter is implemented by both schema.ResourceData and schema.ResourceDiff,
+// so that an update can be planned the same way in CustomizeDiff and Update.
+type ChangeGetter interface {
+	api.UniqueResourceGetter
+
+	GetChange(string) (interface{}, interface{})
+	HasChange(string) bool
+}
+
+// plannedKeys are the attributes whose changes may result in running any operation.
+var plannedKeys = []string{
+	KeyAPIVersion,
+	KeyVersion,
+	KeyVPCID,
+	KeySpec,
+	KeyNodeGroupDrift,
+	KeyNodeGroup,
+	KeyManagedNodeGroup,
+	KeyImmutableNodeGroups,
+	KeyAddon,
+	KeyTags,
+	KeyManifests,
+	KeyPodsReadinessCheck,
+	KeyALBAttachment,
+	KeyDrainNodeGroups,
+	KeyIAMIdentityMapping,
+	KeyAccessEntry,
+	KeyKubeconfigPath,
+}
+
+type oldValueGetter struct {
+	d ChangeGetter
+}
+
+func (g *oldValueGetter) Get(k string) interface{} {
+	o, _ := g.d.GetChange(k)
+
+	return o
+}
+
+func (g *oldValueGetter) Id() string {
+	return g.d.Id()
+}
+
+// planClusterUpdate diffs the effective cluster configs before and after the change,
+// and returns the operations needed to bring the cluster up to date, along with the rendered cluster config.
+func (m *Manager) planClusterUpdate(d ChangeGetter) ([]Operation, []byte, error) {
+	oldCluster, err := ReadCluster(&oldValueGetter{d: d})
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading previous cluster: %w", err)
+	}
+
+	oldConfig, _, err := m.renderClusterConfig(&oldValueGetter{d: d}, oldCluster, m.getClusterName(oldCluster, d.Id()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("rendering previous cluster config: %w", err)
+	}
+
+	newCluster, err := ReadCluster(d)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newConfig, clusterConfig, err := m.renderClusterConfig(d, newCluster, m.getClusterName(newCluster, d.Id()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("rendering cluster config: %w", err)
+	}
+
+	ops, err := planOperations(oldConfig, newConfig, newCluster, d)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ops, clusterConfig, nil
+}
+
+func planOperations(old, new *EksctlClusterConfig, cluster *Cluster, d ChangeGetter) ([]Operation, error) {
+	var ops []Operation
+
+	add := func(name string, targets ...string) {
+		ops = append(ops, Operation{Name: name, Targets: targets})
+	}
+
+	oldNodeGroups, newNodeGroups := nodeGroupNames(old), nodeGroupNames(new)
+
+	// EKS upgrades the control plane by one minor version at a time.
+	// So every intermediate version is upgraded to, along with the add-ons and optionally managed nodegroups.
+	steps, err := versionSteps(metadataField(old, "version"), metadataField(new, "version"))
+	if err != nil {
+		return nil, err
+	}
+
+	upgradeNodeGroups, _ := d.Get(KeyUpgradeNodeGroups).(bool)
+
+	newAddons := addons(new)
+	upgradedAddons := unpinnedAddons(old, new)
+
+	for _, v := range steps {
+		ops = append(ops, Operation{Name: OpUpgradeCluster, Version: v})
+
+		for _, name := range []string{OpUpdateKubeProxy, OpUpdateAWSNode, OpUpdateCoreDNS} {
+			// Components managed as EKS add-ons are updated by `eksctl update addon` instead
+			if _, ok := newAddons[legacyAddonComponents[name]]; !ok {
+				ops = append(ops, Operation{Name: name, Version: v})
+			}
+		}
+
+		if len(upgradedAddons) > 0 {
+			ops = append(ops, Operation{Name: OpUpdateAddon, Targets: upgradedAddons, Version: v})
+		}
+
+		if upgraded := intersection(managedNodeGroupNames(old), managedNodeGroupNames(new)); upgradeNodeGroups && len(upgraded) > 0 {
+			ops = append(ops, Operation{Name: OpUpgradeNodeGroup, Targets: upgraded, Version: v})
+		}
+	}
+
+	controlPlaneOps, err := controlPlaneOperations(old, new)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range controlPlaneOps {
+		add(name)
+	}
+
+	// Nodegroups deleted or scaled out-of-band are recreated or scaled back, unless they're also changed in spec
+	driftDeleted, driftScaled := driftedNodeGroups(d)
+
+	createdNodeGroups := difference(newNodeGroups, oldNodeGroups)
+	if recreated := difference(intersection(driftDeleted, intersection(oldNodeGroups, newNodeGroups)), createdNodeGroups); len(recreated) > 0 {
+		createdNodeGroups = append(createdNodeGroups, recreated...)
+		sort.Strings(createdNodeGroups)
+	}
+
+	if len(createdNodeGroups) > 0 {
+		add(OpCreateNodeGroup, createdNodeGroups...)
+	}
+
+	scaled := scaledNodeGroups(old, new)
+	if rescaled := difference(intersection(driftScaled, intersection(oldNodeGroups, newNodeGroups)), scaled); len(rescaled) > 0 {
+		scaled = append(scaled, rescaled...)
+		sort.Strings(scaled)
+	}
+
+	if len(scaled) > 0 {
+		add(OpScaleNodeGroup, scaled...)
+	}
+
+	oldOIDC, newOIDC := old.IAM.WithOIDC, new.IAM.WithOIDC
+	if newOIDC && !oldOIDC {
+		add(OpAssociateIAMOIDCProvider)
+	}
+
+	oldServiceAccounts, newServiceAccounts := serviceAccountNames(old), serviceAccountNames(new)
+	if newOIDC {
+		var created []string
+		if oldOIDC {
+			created = difference(newServiceAccounts, oldServiceAccounts)
+		} else {
+			created = newServiceAccounts
+		}
+
+		if len(created) > 0 {
+			add(OpCreateIAMServiceAccount, created...)
+		}
+	}
+
+	recreatedProfiles := recreatedFargateProfiles(old, new)
+
+	if deleted := append(difference(fargateProfileNames(old), fargateProfileNames(new)), recreatedProfiles...); len(deleted) > 0 {
+		sort.Strings(deleted)
+		add(OpDeleteFargateProfile, deleted...)
+	}
+
+	if created := append(difference(fargateProfileNames(new), fargateProfileNames(old)), recreatedProfiles...); len(created) > 0 {
+		sort.Strings(created)
+		add(OpCreateFargateProfile, created...)
+	}
+
+	if created := difference(addonNames(new), addonNames(old)); len(created) > 0 {
+		add(OpCreateAddon, created...)
+	}
+
+	if updated := updatedAddons(old, new); len(updated) > 0 {
+		add(OpUpdateAddon, updated...)
+	}
+
+	if deleted := difference(addonNames(old), addonNames(new)); len(deleted) > 0 {
+		add(OpDeleteAddon, deleted...)
+	}
+
+	if !reflect.DeepEqual(old.Git, new.Git) && len(new.Git) > 0 {
+		add(OpEnableRepo)
+	}
+
+	if d.HasChange(KeyDrainNodeGroups) {
+		add(OpDrainNodeGroup, drainNodeGroupChanges(d)...)
+	}
+
+	if d.HasChange(KeyAccessEntry) {
+		add(OpUpdateAccessEntry)
+	}
+
+	if d.HasChange(KeyIAMIdentityMapping) {
+		add(OpUpdateIAMIdentityMapping)
+	}
+
+	if deleted := difference(oldNodeGroups, newNodeGroups); len(deleted) > 0 {
+		add(OpDeleteNodeGroup, deleted...)
+	}
+
+	if newOIDC {
+		if deleted := difference(oldServiceAccounts, newServiceAccounts); len(deleted) > 0 {
+			add(OpDeleteIAMServiceAccount, deleted...)
+		}
+	}
+
+	if d.HasChange(KeyTags) {
+		add(OpUpdateTags)
+	}
+
+	if changed := changedSubnets(old, new); len(changed) > 0 {
+		add(OpUpdateSubnetTags, changed...)
+	}
+
+	if len(cluster.Manifests) > 0 && d.HasChange(KeyManifests) {
+		add(OpApplyKubernetesManifests)
+	}
+
+	if len(cluster.ALBAttachments) > 0 && (len(createdNodeGroups) > 0 || d.HasChange(KeyALBAttachment)) {
+		add(OpAttachNodeGroupsToTargetGroups)
+	}
+
+	// Pods may have been rescheduled or replaced by any preceding operation
+	if len(cluster.CheckPodsReadinessConfigs) > 0 && (len(ops) > 0 || d.HasChange(KeyPodsReadinessCheck)) {
+		add(OpCheckPodsReadiness)
+	}
+
+	if len(ops) > 0 || d.HasChange(KeyKubeconfigPath) {
+		add(OpWriteKubeconfig)
+	}
+
+	return ops, nil
+}
+
+// setPlannedOperations exposes the operations updateCluster is going to run as `planned_operations`,
+// so that they can be reviewed in `terraform plan`.
+func (m *Manager) setPlannedOperations(d *schema.ResourceDiff) error {
+	if err := planNodeGroupDriftRevert(d); err != nil {
+		return err
+	}
+
+	var changed bool
+
+	for _, k := range plannedKeys {
+		if !d.NewValueKnown(k) {
+			return d.SetNewComputed(KeyPlannedOperations)
+		}
+
+		if d.HasChange(k) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return d.SetNew(KeyPlannedOperations, []interface{}{})
+	}
+
+	ops, clusterConfig, err := m.planClusterUpdate(d)
+	if err != nil {
+		return err
+	}
+
+	// Operations completed by the last failed update aren't going to be run again
+	checkpoint := readUpdateCheckpoint(d, clusterConfigHash(clusterConfig))
+
+	var remaining []Operation
+
+	for _, op := range ops {
+		if !checkpoint.IsCompleted(op) {
+			remaining = append(remaining, op)
+		}
+
+		// EKS bumps the platform version along with the Kubernetes version
+		if op.Name == OpUpgradeCluster {
+			if err := d.SetNewComputed(KeyPlatformVersion); err != nil {
+				return err
+			}
+		}
+	}
+
+	return d.SetNew(KeyPlannedOperations, operationNames(remaining))
+}
+
+func operationNames(ops []Operation) []interface{} {
+	names := []interface{}{}
+
+	for _, o := range ops {
+		names = append(names, o.String())
+	}
+
+	return names
+}
+
+func metadataField(c *EksctlClusterConfig, k string) string {
+	if md, ok := c.Rest["metadata"].(map[string]interface{}); ok {
+		if v, ok := md[k]; ok && v != nil {
+			return fmt.Sprintf("%v", v)
+		}
+	}
+
+	return ""
+}
+
+// nodeGroupNames returns the names of both unmanaged and managed nodegroups,
+// as `eksctl create nodegroup` and `eksctl delete nodegroup` handle the both.
+func nodeGroupNames(c *EksctlClusterConfig) []string {
+	var names []string
+
+	for _, ng := range c.NodeGroups {
+		names = append(names, ng.Name)
+	}
+
+	names = append(names, managedNodeGroupNames(c)...)
+
+	sort.Strings(names)
+
+	return names
+}
+
+func managedNodeGroupNames(c *EksctlClusterConfig) []string {
+	names := namesInList(c.Rest["managedNodeGroups"], "name")
+
+	sort.Strings(names)
+
+	return names
+}
+
+// nodeGroupCapacities returns desiredCapacity, minSize and maxSize of each nodegroup, if specified
+func nodeGroupCapacities(c *EksctlClusterConfig) map[string]map[string]string {
+	capacities := map[string]map[string]string{}
+
+	collect := func(name string, ng map[string]interface{}) {
+		caps := map[string]string{}
+
+		for _, k := range nodeGroupCapacityKeys {
+			if v, ok := ng[k]; ok && v != nil {
+				caps[k] = fmt.Sprintf("%v", v)
+			}
+		}
+
+		capacities[name] = caps
+	}
+
+	for _, ng := range c.NodeGroups {
+		collect(ng.Name, ng.Rest)
+	}
+
+	items, _ := c.Rest["managedNodeGroups"].([]interface{})
+	for _, item := range items {
+		if ng, ok := item.(map[string]interface{}); ok {
+			if name, ok := ng["name"].(string); ok {
+				collect(name, ng)
+			}
+		}
+	}
+
+	return capacities
+}
+
+// scaledNodeGroups returns the names of existing nodegroups whose capacities have changed
+func scaledNodeGroups(old, new *EksctlClusterConfig) []string {
+	oldCapacities := nodeGroupCapacities(old)
+
+	var names []string
+
+	for name, caps := range nodeGroupCapacities(new) {
+		oldCaps, ok := oldCapacities[name]
+		if !ok {
+			continue
+		}
+
+		if !reflect.DeepEqual(oldCaps, caps) && len(caps) > 0 {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func fargateProfileNames(c *EksctlClusterConfig) []string {
+	names := namesInList(c.Rest["fargateProfiles"], "name")
+
+	sort.Strings(names)
+
+	return names
+}
+
+// serviceAccountNames returns `<namespace>/<name>` of every iam.serviceAccounts entry
+func serviceAccountNames(c *EksctlClusterConfig) []string {
+	var names []string
+
+	items, _ := c.IAM.Rest["serviceAccounts"].([]interface{})
+
+	for _, item := range items {
+		sa, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		md, _ := sa["metadata"].(map[string]interface{})
+
+		name, _ := md["name"].(string)
+		if name == "" {
+			continue
+		}
+
+		ns, _ := md["namespace"].(string)
+		if ns == "" {
+			ns = "default"
+		}
+
+		names = append(names, ns+"/"+name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func namesInList(v interface{}, key string) []string {
+	var names []string
+
+	items, _ := v.([]interface{})
+
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			if name, ok := m[key].(string); ok && name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
+// difference returns the items in a that aren't in b
+func difference(a, b []string) []string {
+	seen := map[string]bool{}
+
+	for _, s := range b {
+		seen[s] = true
+	}
+
+	var r []string
+
+	for _, s := range a {
+		if !seen[s] {
+			r = append(r, s)
+		}
+	}
+
+	return r
+}
+
+// intersection returns the items in a that are also in b
+func intersection(a, b []string) []string {
+	return difference(a, difference(a, b))
+}
+
+func drainNodeGroupChanges(d ChangeGetter) []string {
+	o, n := d.GetChange(KeyDrainNodeGroups)
+
+	oldDrains, _ := o.(map[string]interface{})
+	newDrains, _ := n.(map[string]interface{})
+
+	var names []string
+
+	for k, v := range newDrains {
+		if ov, ok := oldDrains[k]; !ok || ov != v {
+			names = append(names, k)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,499 @@
+package cluster
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeChangeGetter struct {
+	old, new map[string]interface{}
+}
+
+func newFakeChangeGetter(old, new map[string]interface{}) *fakeChangeGetter {
+	withDefaults := func(m map[string]interface{}) map[string]interface{} {
+		r := map[string]interface{}{}
+
+		for _, k := range []string{KeyBin, KeyEksctlVersion, KeyKubectlBin, KeyName, KeyRegion, KeyProfile, KeySpec, KeyAPIVersion, KeyVersion, KeyVPCID, KeyKubeconfigPath} {
+			r[k] = ""
+		}
+
+		r[KeyName] = "mycluster"
+		r[KeyRegion] = "us-east-2"
+
+		for k, v := range m {
+			r[k] = v
+		}
+
+		return r
+	}
+
+	return &fakeChangeGetter{old: withDefaults(old), new: withDefaults(new)}
+}
+
+func (g *fakeChangeGetter) Id() string {
+	return "id"
+}
+
+func (g *fakeChangeGetter) Get(k string) interface{} {
+	return g.new[k]
+}
+
+func (g *fakeChangeGetter) GetChange(k string) (interface{}, interface{}) {
+	return g.old[k], g.new[k]
+}
+
+func (g *fakeChangeGetter) HasChange(k string) bool {
+	return !reflect.DeepEqual(g.old[k], g.new[k])
+}
+
+func TestPlanClusterUpdate(t *testing.T) {
+	spec := `
+iam:
+  withOIDC: true
+  serviceAccounts:
+  - metadata:
+      name: foo
+      namespace: kube-system
+nodeGroups:
+- name: ng1
+`
+
+	testcases := []struct {
+		name string
+		old  map[string]interface{}
+		new  map[string]interface{}
+		want []string
+		// wantPrefixes is compared to the operations instead of want, when the operations contain hashes
+		wantPrefixes []string
+	}{
+		{
+			name: "no change",
+			old:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17"},
+			new:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17"},
+			want: []string{},
+		},
+		{
+			name: "iam identity mapping only",
+			old:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17", KeyIAMIdentityMapping: "a"},
+			new:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17", KeyIAMIdentityMapping: "b"},
+			want: []string{OpUpdateIAMIdentityMapping, OpWriteKubeconfig},
+		},
+		{
+			name: "migration from iam identity mappings to access entries",
+			old:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17", KeyIAMIdentityMapping: "a"},
+			new: map[string]interface{}{KeySpec: spec + `
+accessConfig:
+  authenticationMode: API_AND_CONFIG_MAP
+`, KeyVersion: "1.17", KeyIAMIdentityMapping: "b", KeyAccessEntry: "a"},
+			want: []string{OpUpdateAuthenticationMode, OpUpdateAccessEntry, OpUpdateIAMIdentityMapping, OpWriteKubeconfig},
+		},
+		{
+			name: "version upgrade",
+			old:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17"},
+			new:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.18"},
+			want: []string{"upgrade cluster 1.18", "utils update-kube-proxy 1.18", "utils update-aws-node 1.18", "utils update-coredns 1.18", OpWriteKubeconfig},
+		},
+		{
+			name: "multi-step version upgrade",
+			old:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17"},
+			new:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.19"},
+			want: []string{
+				"upgrade cluster 1.18", "utils update-kube-proxy 1.18", "utils update-aws-node 1.18", "utils update-coredns 1.18",
+				"upgrade cluster 1.19", "utils update-kube-proxy 1.19", "utils update-aws-node 1.19", "utils update-coredns 1.19",
+				OpWriteKubeconfig,
+			},
+		},
+		{
+			name: "version upgrade with managed nodegroups",
+			old: map[string]interface{}{KeySpec: `
+managedNodeGroups:
+- name: mng1
+`, KeyVersion: "1.17"},
+			new: map[string]interface{}{KeySpec: `
+managedNodeGroups:
+- name: mng1
+- name: mng2
+`, KeyVersion: "1.19", KeyUpgradeNodeGroups: true},
+			want: []string{
+				"upgrade cluster 1.18", "utils update-kube-proxy 1.18", "utils update-aws-node 1.18", "utils update-coredns 1.18", "upgrade nodegroup mng1 1.18",
+				"upgrade cluster 1.19", "utils update-kube-proxy 1.19", "utils update-aws-node 1.19", "utils update-coredns 1.19", "upgrade nodegroup mng1 1.19",
+				"create nodegroup mng2",
+				OpWriteKubeconfig,
+			},
+		},
+		{
+			name: "tags",
+			old:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17", KeyTags: map[string]interface{}{"team": "a"}},
+			new:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17", KeyTags: map[string]interface{}{"team": "b"}},
+			want: []string{OpUpdateTags, OpWriteKubeconfig},
+		},
+		{
+			name: "subnets",
+			old: map[string]interface{}{KeyVPCID: "vpc-1", KeySpec: `
+vpc:
+  subnets:
+    public:
+      us-east-2a: {id: subnet-1}
+    private:
+      us-east-2a: {id: subnet-3}
+`},
+			new: map[string]interface{}{KeyVPCID: "vpc-1", KeySpec: `
+vpc:
+  subnets:
+    public:
+      us-east-2a: {id: subnet-1}
+      us-east-2b: {id: subnet-2}
+`},
+			want: []string{"update subnet tags subnet-2,subnet-3", OpWriteKubeconfig},
+		},
+		{
+			name: "control plane settings",
+			old:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17"},
+			new: map[string]interface{}{KeySpec: spec + `
+cloudWatch:
+  clusterLogging:
+    enableTypes: ["audit", "authenticator"]
+vpc:
+  clusterEndpoints:
+    privateAccess: true
+    publicAccess: true
+  publicAccessCIDRs: ["1.1.1.1/32"]
+secretsEncryption:
+  keyARN: arn:aws:kms:us-east-2:000000000000:key/abc
+`, KeyVersion: "1.17"},
+			want: []string{OpUpdateClusterLogging, OpUpdateClusterEndpoints, OpSetPublicAccessCIDRs, OpEnableSecretsEncryption, OpWriteKubeconfig},
+		},
+		{
+			name: "nodegroup replacement",
+			old:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17"},
+			new: map[string]interface{}{KeySpec: `
+iam:
+  withOIDC: true
+  serviceAccounts:
+  - metadata:
+      name: foo
+      namespace: kube-system
+nodeGroups:
+- name: ng2
+managedNodeGroups:
+- name: mng1
+`, KeyVersion: "1.17"},
+			want: []string{"create nodegroup mng1,ng2", "delete nodegroup ng1", OpWriteKubeconfig},
+		},
+		{
+			name: "service account and fargate profile",
+			old:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17"},
+			new: map[string]interface{}{KeySpec: `
+iam:
+  withOIDC: true
+  serviceAccounts:
+  - metadata:
+      name: bar
+fargateProfiles:
+- name: fp1
+nodeGroups:
+- name: ng1
+`, KeyVersion: "1.17"},
+			want: []string{"create iamserviceaccount default/bar", "create fargateprofile fp1", "delete iamserviceaccount kube-system/foo", OpWriteKubeconfig},
+		},
+		{
+			name: "oidc enabled",
+			old: map[string]interface{}{KeySpec: `
+nodeGroups:
+- name: ng1
+`, KeyVersion: "1.17"},
+			new:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17"},
+			want: []string{OpAssociateIAMOIDCProvider, "create iamserviceaccount kube-system/foo", OpWriteKubeconfig},
+		},
+		{
+			name: "nodegroup scaled",
+			old:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17"},
+			new: map[string]interface{}{KeySpec: `
+iam:
+  withOIDC: true
+  serviceAccounts:
+  - metadata:
+      name: foo
+      namespace: kube-system
+nodeGroups:
+- name: ng1
+  desiredCapacity: 3
+`, KeyVersion: "1.17"},
+			want: []string{"scale nodegroup ng1", OpWriteKubeconfig},
+		},
+		{
+			name: "node_group blocks",
+			old: map[string]interface{}{KeySpec: spec, KeyVersion: "1.17", KeyNodeGroup: []interface{}{
+				testNodeGroupBlock("ng2", 1),
+			}},
+			new: map[string]interface{}{KeySpec: spec, KeyVersion: "1.17", KeyNodeGroup: []interface{}{
+				testNodeGroupBlock("ng2", 2),
+				testNodeGroupBlock("ng3", 1),
+			}},
+			want: []string{"create nodegroup ng3", "scale nodegroup ng2", OpWriteKubeconfig},
+		},
+		{
+			name: "immutable nodegroup replacement",
+			old: map[string]interface{}{KeySpec: `
+nodeGroups:
+- name: ng1
+  instanceType: m5.large
+`, KeyVersion: "1.17", KeyImmutableNodeGroups: true},
+			new: map[string]interface{}{KeySpec: `
+nodeGroups:
+- name: ng1
+  instanceType: m5.xlarge
+`, KeyVersion: "1.17", KeyImmutableNodeGroups: true},
+			wantPrefixes: []string{"create nodegroup ng1-", "delete nodegroup ng1-", OpWriteKubeconfig},
+		},
+		{
+			name: "fargate profile removed and changed",
+			old: map[string]interface{}{KeySpec: `
+fargateProfiles:
+- name: fp1
+  selectors:
+  - namespace: default
+- name: fp2
+  selectors:
+  - namespace: kube-system
+- name: fp3
+  selectors:
+  - namespace: dev
+`, KeyVersion: "1.17"},
+			new: map[string]interface{}{KeySpec: `
+fargateProfiles:
+- name: fp1
+  selectors:
+  - namespace: default
+- name: fp3
+  selectors:
+  - namespace: prod
+- name: fp4
+  selectors:
+  - namespace: staging
+`, KeyVersion: "1.17"},
+			want: []string{"delete fargateprofile fp2,fp3", "create fargateprofile fp3,fp4", OpWriteKubeconfig},
+		},
+		{
+			name: "addons",
+			old: map[string]interface{}{KeySpec: `
+addons:
+- name: vpc-cni
+  version: v1.8.0-eksbuild.1
+- name: aws-ebs-csi-driver
+`, KeyVersion: "1.17"},
+			new: map[string]interface{}{KeySpec: `
+addons:
+- name: vpc-cni
+  version: v1.9.0-eksbuild.1
+`, KeyVersion: "1.17", KeyAddon: []interface{}{
+				map[string]interface{}{
+					"name":                     "coredns",
+					"version":                  "latest",
+					"service_account_role_arn": "",
+					"configuration_values":     `{"replicaCount": 3}`,
+					"resolve_conflicts":        "overwrite",
+				},
+			}},
+			want: []string{"create addon coredns", "update addon vpc-cni", "delete addon aws-ebs-csi-driver", OpWriteKubeconfig},
+		},
+		{
+			name: "version upgrade with addons",
+			old: map[string]interface{}{KeySpec: `
+addons:
+- name: coredns
+  version: latest
+- name: vpc-cni
+  version: v1.8.0-eksbuild.1
+`, KeyVersion: "1.17"},
+			new: map[string]interface{}{KeySpec: `
+addons:
+- name: coredns
+  version: latest
+- name: vpc-cni
+  version: v1.8.0-eksbuild.1
+`, KeyVersion: "1.18"},
+			want: []string{"upgrade cluster 1.18", "utils update-kube-proxy 1.18", "update addon coredns 1.18", OpWriteKubeconfig},
+		},
+		{
+			name: "version upgrade with unversioned addons",
+			old: map[string]interface{}{KeySpec: `
+addons:
+- name: kube-proxy
+- name: vpc-cni
+  version: v1.8.0-eksbuild.1
+`, KeyVersion: "1.17"},
+			new: map[string]interface{}{KeySpec: `
+addons:
+- name: kube-proxy
+- name: vpc-cni
+  version: v1.8.0-eksbuild.1
+`, KeyVersion: "1.18"},
+			want: []string{"upgrade cluster 1.18", "utils update-coredns 1.18", "update addon kube-proxy 1.18", OpWriteKubeconfig},
+		},
+		{
+			name: "out-of-band drift",
+			old: map[string]interface{}{KeySpec: `
+nodeGroups:
+- name: ng1
+  desiredCapacity: 1
+- name: ng2
+`, KeyVersion: "1.17", KeyNodeGroupDrift: []interface{}{
+				"ng1: desiredCapacity changed out-of-band from 1 to 3",
+				"ng2: deleted out-of-band",
+			}},
+			new: map[string]interface{}{KeySpec: `
+nodeGroups:
+- name: ng1
+  desiredCapacity: 1
+- name: ng2
+`, KeyVersion: "1.17", KeyNodeGroupDrift: []interface{}{}},
+			want: []string{"create nodegroup ng2", "scale nodegroup ng1", OpWriteKubeconfig},
+		},
+		{
+			name: "drain",
+			old:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17", KeyDrainNodeGroups: map[string]interface{}{"ng1": false}},
+			new:  map[string]interface{}{KeySpec: spec, KeyVersion: "1.17", KeyDrainNodeGroups: map[string]interface{}{"ng1": true}},
+			want: []string{"drain nodegroup ng1", OpWriteKubeconfig},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Manager{DisableClusterNameSuffix: true}
+
+			ops, _, err := m.planClusterUpdate(newFakeChangeGetter(tc.old, tc.new))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := []string{}
+			for _, n := range operationNames(ops) {
+				got = append(got, n.(string))
+			}
+
+			if tc.wantPrefixes != nil {
+				for i := range got {
+					if i < len(tc.wantPrefixes) && strings.HasPrefix(got[i], tc.wantPrefixes[i]) {
+						got[i] = tc.wantPrefixes[i]
+					}
+				}
+
+				tc.want = tc.wantPrefixes
+			}
+
+			if d := cmp.Diff(tc.want, got); d != "" {
+				t.Errorf("unexpected operations: want (-), got (+)\n%s", d)
+			}
+		})
+	}
+}
+
+func TestPlanClusterUpdate_Downgrade(t *testing.T) {
+	m := &Manager{DisableClusterNameSuffix: true}
+
+	d := newFakeChangeGetter(map[string]interface{}{KeyVersion: "1.18"}, map[string]interface{}{KeyVersion: "1.17"})
+
+	if _, _, err := m.planClusterUpdate(d); err == nil {
+		t.Fatal("expected error for downgrading the cluster")
+	}
+}
+
+func TestPlanClusterUpdate_SecretsEncryptionKeyChange(t *testing.T) {
+	m := &Manager{DisableClusterNameSuffix: true}
+
+	d := newFakeChangeGetter(
+		map[string]interface{}{KeySpec: "secretsEncryption:\n  keyARN: arn:aws:kms:us-east-2:000000000000:key/a\n"},
+		map[string]interface{}{KeySpec: "secretsEncryption:\n  keyARN: arn:aws:kms:us-east-2:000000000000:key/b\n"},
+	)
+
+	if _, _, err := m.planClusterUpdate(d); err == nil {
+		t.Fatal("expected error for changing the secrets encryption key")
+	}
+}
+
+func TestDependenciesOf_VersionSteps(t *testing.T) {
+	ops := []Operation{
+		{Name: OpUpgradeCluster, Version: "1.18"},
+		{Name: OpUpdateCoreDNS, Version: "1.18"},
+		{Name: OpUpgradeCluster, Version: "1.19"},
+		{Name: OpUpdateCoreDNS, Version: "1.19"},
+		{Name: OpCreateNodeGroup, Targets: []string{"ng2"}},
+	}
+
+	testcases := []struct {
+		op   Operation
+		want []string
+	}{
+		{op: ops[0]},
+		{op: ops[1], want: []string{"upgrade cluster 1.18"}},
+		{op: ops[2], want: []string{"upgrade cluster 1.18", "utils update-coredns 1.18"}},
+		{op: ops[3], want: []string{"upgrade cluster 1.18", "utils update-coredns 1.18", "upgrade cluster 1.19"}},
+		{op: ops[4], want: []string{"upgrade cluster 1.18", "utils update-coredns 1.18", "upgrade cluster 1.19", "utils update-coredns 1.19"}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.op.String(), func(t *testing.T) {
+			if d := cmp.Diff(tc.want, dependenciesOf(tc.op, ops)); d != "" {
+				t.Errorf("unexpected dependencies: want (-), got (+)\n%s", d)
+			}
+		})
+	}
+}
+
+func TestDependenciesOf_Addons(t *testing.T) {
+	ops := []Operation{
+		{Name: OpUpgradeCluster, Version: "1.18"},
+		{Name: OpUpdateAddon, Targets: []string{"coredns"}, Version: "1.18"},
+		{Name: OpUpdateAddon, Targets: []string{"coredns"}},
+	}
+
+	if d := cmp.Diff([]string{"upgrade cluster 1.18"}, dependenciesOf(ops[1], ops)); d != "" {
+		t.Errorf("unexpected dependencies of the upgrade step: want (-), got (+)\n%s", d)
+	}
+
+	if d := cmp.Diff([]string{"upgrade cluster 1.18", "update addon coredns 1.18"}, dependenciesOf(ops[2], ops)); d != "" {
+		t.Errorf("unexpected dependencies of the add-on update: want (-), got (+)\n%s", d)
+	}
+}
+
+func TestPlanClusterUpdate_CreateNodeGroupArgs(t *testing.T) {
+	m := &Manager{DisableClusterNameSuffix: true}
+
+	old := map[string]interface{}{KeySpec: "nodeGroups:\n- name: ng1\n", KeyVersion: "1.17"}
+	new := map[string]interface{}{KeySpec: "nodeGroups:\n- name: ng1\n- name: ng2\n", KeyVersion: "1.17"}
+
+	ops, _, err := m.planClusterUpdate(newFakeChangeGetter(old, new))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var planned bool
+
+	for _, op := range ops {
+		if op.Name != OpCreateNodeGroup {
+			continue
+		}
+
+		planned = true
+
+		if d := cmp.Diff([]string{"--timeout", "90m"}, eksctlCreateExtraArgs[op.Name]); d != "" {
+			t.Errorf("unexpected args for %s: want (-), got (+)\n%s", op, d)
+		}
+	}
+
+	if !planned {
+		t.Fatalf("expected %s to be planned, but got %v", OpCreateNodeGroup, operationNames(ops))
+	}
+
+	for name, args := range eksctlCreateExtraArgs {
+		for _, a := range args {
+			if strings.ContainsAny(a, " \t") {
+				t.Errorf("%s: argument %q must be split into separate elements", name, a)
+			}
+		}
+	}
+}
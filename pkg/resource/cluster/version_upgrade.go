@@ -0,0 +1,138 @@
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeyUpgradeNodeGroups enables upgrading managed nodegroups along with the control plane
+const KeyUpgradeNodeGroups = "upgrade_node_groups"
+
+// versionSteps returns the Kubernetes versions the cluster needs to be upgraded to one by one, to go from `from` to `to`.
+// Downgrades are rejected, as EKS doesn't support them.
+func versionSteps(from, to string) ([]string, error) {
+	if from == "" || to == "" || from == to {
+		return nil, nil
+	}
+
+	fromMajor, fromMinor, err := parseVersion(from)
+	if err != nil {
+		return nil, err
+	}
+
+	toMajor, toMinor, err := parseVersion(to)
+	if err != nil {
+		return nil, err
+	}
+
+	if fromMajor != toMajor {
+		return nil, fmt.Errorf("upgrading Kubernetes from %s to %s is not supported: major versions differ", from, to)
+	}
+
+	if toMinor < fromMinor {
+		return nil, fmt.Errorf("downgrading Kubernetes from %s to %s is not supported by EKS. Set %q to %s or later", from, to, KeyVersion, from)
+	}
+
+	var steps []string
+
+	for minor := fromMinor + 1; minor <= toMinor; minor++ {
+		steps = append(steps, fmt.Sprintf("%d.%d", toMajor, minor))
+	}
+
+	return steps, nil
+}
+
+// compareVersions returns -1, 0 or 1 when the Kubernetes version a is older than, the same as, or newer than b.
+// Unparsable versions are compared as strings.
+func compareVersions(a, b string) int {
+	aMajor, aMinor, aErr := parseVersion(a)
+	bMajor, bMinor, bErr := parseVersion(b)
+
+	if aErr != nil || bErr != nil {
+		return strings.Compare(a, b)
+	}
+
+	if aMajor != bMajor {
+		return compareInts(aMajor, bMajor)
+	}
+
+	return compareInts(aMinor, bMinor)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// parseVersion parses the major and minor parts of a Kubernetes version like `1.18` or `1.18.9`
+func parseVersion(v string) (int, int, error) {
+	parts := strings.Split(strings.TrimPrefix(v, "v"), ".")
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("invalid Kubernetes version %q: expected MAJOR.MINOR", v)
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Kubernetes version %q: %w", v, err)
+	}
+
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Kubernetes version %q: %w", v, err)
+	}
+
+	return major, minor, nil
+}
+
+// clusterConfigWithVersion returns the cluster.yaml with metadata.version replaced,
+// so that `eksctl upgrade cluster -f` upgrades the control plane to the intermediate version.
+func clusterConfigWithVersion(clusterConfig []byte, version string) ([]byte, error) {
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(clusterConfig, &doc); err != nil {
+		return nil, fmt.Errorf("parsing cluster.yaml: %w", err)
+	}
+
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("parsing cluster.yaml: expected a mapping at the top-level")
+	}
+
+	root := doc.Content[0]
+
+	md := mappingValue(root, "metadata")
+	if md == nil || md.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("parsing cluster.yaml: missing metadata")
+	}
+
+	if v := mappingValue(md, "version"); v != nil {
+		v.Value = version
+		v.Tag = "!!str"
+		v.Style = yaml.DoubleQuotedStyle
+	} else {
+		md.Content = append(md.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: version, Style: yaml.DoubleQuotedStyle},
+		)
+	}
+
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("encoding cluster.yaml: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
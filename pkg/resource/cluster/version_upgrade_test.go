@@ -0,0 +1,74 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestVersionSteps(t *testing.T) {
+	testcases := []struct {
+		from, to string
+		want     []string
+		wantErr  bool
+	}{
+		{from: "1.17", to: "1.17"},
+		{from: "1.17", to: "1.18", want: []string{"1.18"}},
+		{from: "1.17", to: "1.20", want: []string{"1.18", "1.19", "1.20"}},
+		{from: "1.18.9", to: "1.19", want: []string{"1.19"}},
+		{from: "1.18", to: "1.17", wantErr: true},
+		{from: "1.18", to: "foo", wantErr: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.from+"->"+tc.to, func(t *testing.T) {
+			got, err := versionSteps(tc.from, tc.to)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if d := cmp.Diff(tc.want, got); d != "" {
+				t.Errorf("unexpected steps: want (-), got (+)\n%s", d)
+			}
+		})
+	}
+}
+
+func TestClusterConfigWithVersion(t *testing.T) {
+	config := `apiVersion: eksctl.io/v1alpha5
+kind: ClusterConfig
+metadata:
+  name: mycluster
+  region: us-east-2
+  version: "1.17"
+nodeGroups:
+  - name: ng1
+`
+
+	want := `apiVersion: eksctl.io/v1alpha5
+kind: ClusterConfig
+metadata:
+  name: mycluster
+  region: us-east-2
+  version: "1.18"
+nodeGroups:
+  - name: ng1
+`
+
+	got, err := clusterConfigWithVersion([]byte(config), "1.18")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if d := cmp.Diff(want, string(got)); d != "" {
+		t.Errorf("unexpected cluster config: want (-), got (+)\n%s", d)
+	}
+}
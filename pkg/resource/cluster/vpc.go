@@ -2,91 +2,244 @@ package cluster
 
 import (
 	"fmt"
+	"log"
+	"sort"
+	"strings"
+
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
 	"golang.org/x/xerrors"
-	"log"
 )
 
+const (
+	// TagKeyRoleELB marks the subnets for internet-facing load balancers created by Kubernetes
+	TagKeyRoleELB = "kubernetes.io/role/elb"
+
+	// TagKeyRoleInternalELB marks the subnets for internal load balancers created by Kubernetes
+	TagKeyRoleInternalELB = "kubernetes.io/role/internal-elb"
+)
+
+// vpcResources are the VPC and subnets of a cluster, tagged so that Kubernetes can discover them
+type vpcResources struct {
+	VPCID            string
+	PublicSubnetIDs  []string
+	PrivateSubnetIDs []string
+
+	// Owned is true when the VPC is created and deleted by eksctl along with the cluster
+	Owned bool
+}
+
+func (r vpcResources) ids() []*string {
+	var ids []*string
+
+	for _, id := range append(append([]string{}, r.PublicSubnetIDs...), r.PrivateSubnetIDs...) {
+		ids = append(ids, aws.String(id))
+	}
+
+	if r.VPCID != "" {
+		ids = append(ids, aws.String(r.VPCID))
+	}
+
+	return ids
+}
+
+func clusterTagKey(clusterName ClusterName) string {
+	return fmt.Sprintf("kubernetes.io/cluster/%s", clusterName)
+}
+
 func createVPCResourceTags(cluster *Cluster, clusterName ClusterName) error {
 	if cluster.VPCID == "" {
-		log.Printf("Skipped VPC resource tagging due to that VPC and subnets management are handled to eksctl")
+		log.Printf("[DEBUG] deferring VPC resource tagging until eksctl creates the VPC and subnets")
 
 		return nil
 	}
 
-	ec2session := ec2.New(AWSSessionFromCluster(cluster))
+	return tagVPCResources(ec2.New(AWSSessionFromCluster(cluster)), getVpcResources(cluster), clusterName)
+}
 
-	tagKey := fmt.Sprintf("kubernetes.io/cluster/%s", clusterName)
-	tagValue := "shared"
+// createEksctlManagedVPCResourceTags tags the VPC and subnets created by eksctl, which are known only after the cluster is created
+func createEksctlManagedVPCResourceTags(ctx *sdk.Context, cluster *Cluster, clusterName ClusterName) error {
+	if cluster.VPCID != "" {
+		return nil
+	}
 
-	resources := getVpcResources(cluster)
+	stackName := fmt.Sprintf("eksctl-%s-cluster", clusterName)
 
-	tag := ec2.Tag{
-		Key:   aws.String(tagKey),
-		Value: aws.String(tagValue),
+	res, err := cloudformation.New(ctx.Session()).DescribeStacks(&cloudformation.DescribeStacksInput{StackName: aws.String(stackName)})
+	if err != nil {
+		return fmt.Errorf("describing stack %s: %w", stackName, err)
 	}
 
-	if _, err := ec2session.CreateTags(&ec2.CreateTagsInput{
-		Resources: resources,
-		Tags: []*ec2.Tag{
-			&tag,
-		},
-	}); err != nil {
-		log.Printf("ec2.CreateTags failed: Resources=%+v Tags=%v Error=%v", resources, tag, err)
-
-		return xerrors.Errorf("calling ec2.CreateTags: %w", err)
+	if len(res.Stacks) == 0 {
+		return fmt.Errorf("describing stack %s: stack not found", stackName)
 	}
 
-	return nil
+	return tagVPCResources(ec2.New(ctx.Session()), vpcResourcesFromStackOutputs(res.Stacks[0].Outputs), clusterName)
 }
 
-func getVpcResources(cluster *Cluster) []*string {
-	var resources []*string
+// vpcResourcesFromStackOutputs reads the VPC and subnets from the outputs of the cluster stack created by eksctl
+func vpcResourcesFromStackOutputs(outputs []*cloudformation.Output) vpcResources {
+	r := vpcResources{Owned: true}
 
-	for _, subnetID := range cluster.PublicSubnetIDs {
-		resources = append(resources, aws.String(subnetID))
-	}
+	split := func(v string) []string {
+		var ids []string
+
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
 
-	for _, subnetID := range cluster.PrivateSubnetIDs {
-		resources = append(resources, aws.String(subnetID))
+		return ids
 	}
 
-	if cluster.VPCID == "" {
-		log.Printf("vpc id isnt configured via vpc_id attribute: tagging of ekslct-managed vpc is not supported yet")
-	} else {
-		resources = append(resources, aws.String(cluster.VPCID))
+	for _, o := range outputs {
+		v := aws.StringValue(o.OutputValue)
+
+		switch aws.StringValue(o.OutputKey) {
+		case "VPC":
+			r.VPCID = v
+		case "SubnetsPublic":
+			r.PublicSubnetIDs = split(v)
+		case "SubnetsPrivate":
+			r.PrivateSubnetIDs = split(v)
+		}
 	}
-	return resources
+
+	return r
 }
 
-func deleteVPCResourceTags(cluster *Cluster, clusterName ClusterName) error {
-	if cluster.VPCID == "" {
-		log.Printf("Skipped VPC resource de-tagging due to that VPC and subnets management are handled to eksctl")
+// tagVPCResources adds the cluster tag to the VPC and all the subnets, and the role tags to the public and private subnets respectively
+func tagVPCResources(svc ec2iface.EC2API, r vpcResources, clusterName ClusterName) error {
+	tagValue := "shared"
+	if r.Owned {
+		tagValue = "owned"
+	}
+
+	create := func(ids []*string, k, v string) error {
+		if len(ids) == 0 {
+			return nil
+		}
+
+		tag := ec2.Tag{Key: aws.String(k), Value: aws.String(v)}
+
+		if _, err := svc.CreateTags(&ec2.CreateTagsInput{
+			Resources: ids,
+			Tags: []*ec2.Tag{
+				&tag,
+			},
+		}); err != nil {
+			log.Printf("ec2.CreateTags failed: Resources=%+v Tags=%v Error=%v", ids, tag, err)
+
+			return xerrors.Errorf("calling ec2.CreateTags: %w", err)
+		}
 
 		return nil
 	}
 
-	ec2session := ec2.New(AWSSessionFromCluster(cluster))
+	if err := create(r.ids(), clusterTagKey(clusterName), tagValue); err != nil {
+		return err
+	}
 
-	tagKey := fmt.Sprintf("kubernetes.io/cluster/%s", clusterName)
+	if err := create(aws.StringSlice(r.PublicSubnetIDs), TagKeyRoleELB, "1"); err != nil {
+		return err
+	}
 
-	resources := getVpcResources(cluster)
+	return create(aws.StringSlice(r.PrivateSubnetIDs), TagKeyRoleInternalELB, "1")
+}
+
+// untagVPCResources removes the cluster tag from the resources.
+// The role tags are left as-is, as they may be used by other clusters sharing the subnets.
+func untagVPCResources(svc ec2iface.EC2API, ids []*string, clusterName ClusterName) error {
+	if len(ids) == 0 {
+		return nil
+	}
 
 	tag := ec2.Tag{
-		Key: aws.String(tagKey),
+		Key: aws.String(clusterTagKey(clusterName)),
 	}
 
-	if _, err := ec2session.DeleteTags(&ec2.DeleteTagsInput{
-		Resources: resources,
+	if _, err := svc.DeleteTags(&ec2.DeleteTagsInput{
+		Resources: ids,
 		Tags: []*ec2.Tag{
 			&tag,
 		},
 	}); err != nil {
-		log.Printf("ec2.DeleteTags failed: Resources=%+v, Tags=%v", resources, tag)
+		log.Printf("ec2.DeleteTags failed: Resources=%+v, Tags=%v", ids, tag)
 
 		return xerrors.Errorf("calling ec2.DeleteTags: %w", err)
 	}
 
 	return nil
 }
+
+func getVpcResources(cluster *Cluster) vpcResources {
+	return vpcResources{
+		VPCID:            cluster.VPCID,
+		PublicSubnetIDs:  cluster.PublicSubnetIDs,
+		PrivateSubnetIDs: cluster.PrivateSubnetIDs,
+	}
+}
+
+// subnetIDs returns the IDs of the public and private subnets in the cluster.yaml, sorted
+func subnetIDs(subnets map[string]Subnet) []string {
+	var ids []string
+
+	for _, s := range subnets {
+		if s.ID != "" {
+			ids = append(ids, s.ID)
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
+
+// changedSubnets returns the IDs of the subnets added to or removed from the cluster.yaml of a cluster in an existing VPC
+func changedSubnets(old, new *EksctlClusterConfig) []string {
+	if new.VPC.ID == "" {
+		return nil
+	}
+
+	all := func(c *EksctlClusterConfig) []string {
+		return append(subnetIDs(c.VPC.Subnets.Public), subnetIDs(c.VPC.Subnets.Private)...)
+	}
+
+	oldIDs, newIDs := all(old), all(new)
+
+	changed := append(difference(newIDs, oldIDs), difference(oldIDs, newIDs)...)
+
+	sort.Strings(changed)
+
+	return changed
+}
+
+// updateSubnetTags tags the subnets added to the cluster.yaml, and untags the subnets removed from it
+func updateSubnetTags(svc ec2iface.EC2API, c *EksctlClusterConfig, clusterName ClusterName, changed []string) error {
+	added := vpcResources{
+		PublicSubnetIDs:  intersection(subnetIDs(c.VPC.Subnets.Public), changed),
+		PrivateSubnetIDs: intersection(subnetIDs(c.VPC.Subnets.Private), changed),
+	}
+
+	removed := difference(changed, append(append([]string{}, added.PublicSubnetIDs...), added.PrivateSubnetIDs...))
+
+	if err := tagVPCResources(svc, added, clusterName); err != nil {
+		return err
+	}
+
+	return untagVPCResources(svc, aws.StringSlice(removed), clusterName)
+}
+
+func deleteVPCResourceTags(cluster *Cluster, clusterName ClusterName) error {
+	if cluster.VPCID == "" {
+		log.Printf("Skipped VPC resource de-tagging, as the VPC and subnets created by eksctl are deleted along with the cluster")
+
+		return nil
+	}
+
+	return untagVPCResources(ec2.New(AWSSessionFromCluster(cluster)), getVpcResources(cluster).ids(), clusterName)
+}
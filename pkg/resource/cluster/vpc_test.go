@@ -0,0 +1,88 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestVPCResourcesFromStackOutputs(t *testing.T) {
+	outputs := []*cloudformation.Output{
+		{OutputKey: aws.String("VPC"), OutputValue: aws.String("vpc-1")},
+		{OutputKey: aws.String("SubnetsPublic"), OutputValue: aws.String("subnet-1,subnet-2")},
+		{OutputKey: aws.String("SubnetsPrivate"), OutputValue: aws.String("subnet-3")},
+		{OutputKey: aws.String("SecurityGroup"), OutputValue: aws.String("sg-1")},
+	}
+
+	want := vpcResources{
+		VPCID:            "vpc-1",
+		PublicSubnetIDs:  []string{"subnet-1", "subnet-2"},
+		PrivateSubnetIDs: []string{"subnet-3"},
+		Owned:            true,
+	}
+
+	if d := cmp.Diff(want, vpcResourcesFromStackOutputs(outputs)); d != "" {
+		t.Errorf("unexpected vpc resources: want (-), got (+)\n%s", d)
+	}
+}
+
+type fakeTags struct {
+	ec2iface.EC2API
+
+	// tags are the `<resource>:<key>=<value>` created, and `-<resource>:<key>` deleted
+	tags []string
+}
+
+func (f *fakeTags) CreateTags(in *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	for _, r := range in.Resources {
+		for _, t := range in.Tags {
+			f.tags = append(f.tags, aws.StringValue(r)+":"+aws.StringValue(t.Key)+"="+aws.StringValue(t.Value))
+		}
+	}
+
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+func (f *fakeTags) DeleteTags(in *ec2.DeleteTagsInput) (*ec2.DeleteTagsOutput, error) {
+	for _, r := range in.Resources {
+		for _, t := range in.Tags {
+			f.tags = append(f.tags, "-"+aws.StringValue(r)+":"+aws.StringValue(t.Key))
+		}
+	}
+
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
+func TestUpdateSubnetTags(t *testing.T) {
+	c := &EksctlClusterConfig{
+		VPC: VPC{
+			ID: "vpc-1",
+			Subnets: Subnets{
+				Public:  map[string]Subnet{"us-east-2a": {ID: "subnet-1"}, "us-east-2b": {ID: "subnet-2"}},
+				Private: map[string]Subnet{"us-east-2a": {ID: "subnet-3"}},
+			},
+		},
+	}
+
+	f := &fakeTags{}
+
+	if err := updateSubnetTags(f, c, "mycluster", []string{"subnet-2", "subnet-3", "subnet-9"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"subnet-2:kubernetes.io/cluster/mycluster=shared",
+		"subnet-3:kubernetes.io/cluster/mycluster=shared",
+		"subnet-2:kubernetes.io/role/elb=1",
+		"subnet-3:kubernetes.io/role/internal-elb=1",
+		"-subnet-9:kubernetes.io/cluster/mycluster",
+	}
+
+	if d := cmp.Diff(want, f.tags); d != "" {
+		t.Errorf("unexpected tags: want (-), got (+)\n%s", d)
+	}
+}
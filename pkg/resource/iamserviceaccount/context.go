@@ -1,11 +1,13 @@
 package iamserviceaccount
 
 import (
+	"context"
+
 	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
 )
 
-func mustContext(a *IAMServiceAccount) *sdk.Context {
+func mustContext(runCtx context.Context, a *IAMServiceAccount) *sdk.Context {
 	sess, creds := sdk.AWSCredsFromValues(a.Region, a.Profile, a.AssumeRoleConfig)
 
-	return &sdk.Context{Sess: sess, Creds: creds}
+	return &sdk.Context{Sess: sess, Creds: creds, Ctx: runCtx}
 }
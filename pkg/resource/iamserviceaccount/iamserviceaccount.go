@@ -5,6 +5,7 @@ import (
 	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk"
 	"github.com/tuxmonteiro/terraform-provider-eksctl/pkg/sdk/tfsdk"
 	"os/exec"
+	"time"
 )
 
 const KeyNamespace = "namespace"
@@ -20,7 +21,10 @@ func Resource() *schema.Resource {
 		Create: func(d *schema.ResourceData, meta interface{}) error {
 			a := ReadIAMServiceAccount(d)
 
-			ctx := mustContext(a)
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutCreate))
+			defer cancel()
+
+			ctx := mustContext(runCtx, a)
 
 			args := []string{
 				"create",
@@ -47,7 +51,10 @@ func Resource() *schema.Resource {
 		Delete: func(d *schema.ResourceData, meta interface{}) error {
 			a := ReadIAMServiceAccount(d)
 
-			ctx := mustContext(a)
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutDelete))
+			defer cancel()
+
+			ctx := mustContext(runCtx, a)
 
 			args := []string{
 				"delete",
@@ -65,6 +72,10 @@ func Resource() *schema.Resource {
 		Update: func(data *schema.ResourceData, i interface{}) error {
 			return nil
 		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(20 * time.Minute),
+			Delete: schema.DefaultTimeout(20 * time.Minute),
+		},
 		Schema: map[string]*schema.Schema{
 			KeyNamespace: {
 				Type:     schema.TypeString,
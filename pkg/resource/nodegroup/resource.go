@@ -10,6 +10,7 @@ import (
 	"os/exec"
 	"runtime/debug"
 	"strings"
+	"time"
 )
 
 type Op uint8
@@ -222,7 +223,10 @@ func Resource() *schema.Resource {
 				}
 			}()
 
-			ctx := mustContext(d)
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutCreate))
+			defer cancel()
+
+			ctx := mustContext(runCtx, d)
 
 			args := []string{
 				"create",
@@ -252,7 +256,10 @@ func Resource() *schema.Resource {
 				}
 			}()
 
-			ctx := mustContext(d)
+			runCtx, cancel := sdk.ContextWithTimeout(meta, d.Timeout(schema.TimeoutDelete))
+			defer cancel()
+
+			ctx := mustContext(runCtx, d)
 
 			args := []string{
 				"delete",
@@ -273,6 +280,10 @@ func Resource() *schema.Resource {
 		Update: func(d *schema.ResourceData, meta interface{}) error {
 			return nil
 		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(90 * time.Minute),
+			Delete: schema.DefaultTimeout(60 * time.Minute),
+		},
 		Schema: sc,
 	}
 }
@@ -1,6 +1,7 @@
 package sdk
 
 import (
+	"context"
 	"os"
 	"os/exec"
 	"strings"
@@ -13,12 +14,25 @@ import (
 type Context struct {
 	Creds *sts.Credentials
 	Sess  *session.Session
+
+	// Ctx interrupts the commands run within the context on timeouts and when Terraform is interrupted.
+	// Commands run to completion when it's nil.
+	Ctx context.Context
 }
 
 func (e *Context) Run(cmd *exec.Cmd) (*CommandResult, error) {
 	e.setEnv(cmd)
 
-	return Run(cmd)
+	return RunContext(e.Context(), cmd)
+}
+
+// Context returns Ctx, or the background context when it's nil, for use with AWS API calls and waiters
+func (e *Context) Context() context.Context {
+	if e.Ctx == nil {
+		return context.Background()
+	}
+
+	return e.Ctx
 }
 
 func (e *Context) setEnv(cmd *exec.Cmd) {
@@ -0,0 +1,21 @@
+//go:build windows
+// +build windows
+
+package sdk
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup is a no-op, as Windows has no process groups to signal.
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// terminateProcessGroup kills the command, as Windows doesn't support SIGTERM
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -2,6 +2,7 @@ package sdk
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
@@ -14,9 +15,20 @@ import (
 	"os/exec"
 	"strings"
 	"syscall"
+	"time"
 )
 
+// TerminationGracePeriod is how long an interrupted command and its children are given to exit after SIGTERM, before SIGKILL
+var TerminationGracePeriod = 30 * time.Second
+
 func Run(cmd *exec.Cmd) (*CommandResult, error) {
+	return RunContext(context.Background(), cmd)
+}
+
+// RunContext runs the command until it exits, or the context is done.
+// On the latter, the command's whole process group is terminated, so that no child process like kubectl is left running,
+// and the returned error tells which command was interrupted and why.
+func RunContext(ctx context.Context, cmd *exec.Cmd) (*CommandResult, error) {
 	const maxBufSize = 8 * 1024
 
 	// Setup the command
@@ -42,8 +54,8 @@ func Run(cmd *exec.Cmd) (*CommandResult, error) {
 
 	log.Printf("[DEBUG] starting command %q", cmdToLog)
 
-	// Execute the command to completion
-	runErr := cmd.Run()
+	// Execute the command to completion, or the interruption
+	interrupted, runErr := runInProcessGroup(ctx, cmd, cmdToLog)
 
 	logDebug("closing pipe writer", strings.Join(cmd.Args, " "))
 
@@ -62,6 +74,10 @@ func Run(cmd *exec.Cmd) (*CommandResult, error) {
 
 	out := output.String()
 	log.Printf("[DEBUG] command %q finished with output: \"%s\"", cmdToLog, out)
+
+	if interrupted {
+		return nil, fmt.Errorf("command %q was interrupted: %w\n%s", cmdToLog, ctx.Err(), out)
+	}
 	var exitStatus int
 	if runErr != nil {
 		switch ee := runErr.(type) {
@@ -86,6 +102,48 @@ func Run(cmd *exec.Cmd) (*CommandResult, error) {
 	return res, nil
 }
 
+// runInProcessGroup runs the command in its own process group.
+// When the context is done before the command exits, the process group is sent SIGTERM, and then SIGKILL after TerminationGracePeriod.
+func runInProcessGroup(ctx context.Context, cmd *exec.Cmd, cmdToLog string) (bool, error) {
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return false, err
+	}
+
+	waitCh := make(chan error, 1)
+
+	go func() {
+		waitCh <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-waitCh:
+		return false, err
+	case <-ctx.Done():
+	}
+
+	log.Printf("[WARN] terminating command %q: %v", cmdToLog, ctx.Err())
+
+	if err := terminateProcessGroup(cmd); err != nil {
+		log.Printf("[WARN] failed to terminate command %q: %v", cmdToLog, err)
+	}
+
+	select {
+	case err := <-waitCh:
+		return true, err
+	case <-time.After(TerminationGracePeriod):
+	}
+
+	log.Printf("[WARN] killing command %q, as it didn't exit within %s after SIGTERM", cmdToLog, TerminationGracePeriod)
+
+	if err := killProcessGroup(cmd); err != nil {
+		log.Printf("[WARN] failed to kill command %q: %v", cmdToLog, err)
+	}
+
+	return true, <-waitCh
+}
+
 func Hash(data interface{}) string {
 	bs, err := json.Marshal(data)
 	if err != nil {
@@ -1,9 +1,13 @@
 package sdk
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os/exec"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -34,3 +38,30 @@ func TestRun(t *testing.T) {
 		})
 	}
 }
+
+func TestRunContextInterrupted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// The child sleep is in the same process group as bash, so it's terminated along with bash
+	cmd := exec.Command("bash", "-c", "echo started; sleep 30 & wait")
+
+	start := time.Now()
+
+	_, err := RunContext(ctx, cmd)
+	if err == nil {
+		t.Fatal("expected error for the interrupted command")
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the error to wrap context.DeadlineExceeded, but got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "was interrupted") || !strings.Contains(err.Error(), "sleep 30") {
+		t.Errorf("expected the error to name the interrupted command, but got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the command to be terminated, but it took %s", elapsed)
+	}
+}
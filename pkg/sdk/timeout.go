@@ -0,0 +1,29 @@
+package sdk
+
+import (
+	"context"
+	"time"
+)
+
+// StopContexter is implemented by the provider meta, so that resources can stop their work when Terraform is interrupted
+type StopContexter interface {
+	// StopContext returns the context that is canceled when Terraform asks the provider to stop
+	StopContext() context.Context
+}
+
+// ContextWithTimeout returns the context to run an operation of a resource within.
+// It's canceled when the timeout elapses, or when Terraform is interrupted if the meta is a StopContexter.
+// A non-positive timeout means no timeout.
+func ContextWithTimeout(meta interface{}, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx := context.Background()
+
+	if s, ok := meta.(StopContexter); ok {
+		ctx = s.StopContext()
+	}
+
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}